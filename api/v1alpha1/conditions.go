@@ -0,0 +1,92 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/kustomize/kstatus/status"
+)
+
+// The standard conditions following the kstatus conventions
+const (
+	// ConditionReady is True when the resource is fully reconciled and working
+	ConditionReady status.ConditionType = "Ready"
+	// ConditionReconciling is True when the controller is still working on the latest spec
+	ConditionReconciling status.ConditionType = "Reconciling"
+	// ConditionStalled is True when the controller can not progress without an external change
+	ConditionStalled status.ConditionType = "Stalled"
+)
+
+// FindCondition returns the condition with the given type or nil if it does not exist
+func FindCondition(conditions []Condition, conditionType status.ConditionType) *Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// IsConditionTrue checks if the condition with the given type exists and is True
+func IsConditionTrue(conditions []Condition, conditionType status.ConditionType) bool {
+	cond := FindCondition(conditions, conditionType)
+
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
+
+// SetCondition adds the condition into the list or replaces the existing one with the same type.
+// The LastTransitionTime is only changed when the status of the condition changes.
+func SetCondition(conditions []Condition, cond Condition) []Condition {
+	existing := FindCondition(conditions, cond.Type)
+	if existing == nil {
+		if cond.LastTransitionTime.IsZero() {
+			cond.LastTransitionTime = metav1.Now()
+		}
+
+		return append(conditions, cond)
+	}
+
+	if existing.Status == cond.Status {
+		cond.LastTransitionTime = existing.LastTransitionTime
+	} else if cond.LastTransitionTime.IsZero() {
+		cond.LastTransitionTime = metav1.Now()
+	}
+
+	*existing = cond
+
+	return conditions
+}
+
+// MergeConditions replaces the previous conditions with the current ones.
+// The LastTransitionTime of the conditions whose status is not changed is kept.
+func MergeConditions(previous []Condition, current []Condition) []Condition {
+	merged := make([]Condition, 0, len(current))
+	for _, cond := range current {
+		if prev := FindCondition(previous, cond.Type); prev != nil && prev.Status == cond.Status {
+			cond.LastTransitionTime = prev.LastTransitionTime
+		} else if cond.LastTransitionTime.IsZero() {
+			cond.LastTransitionTime = metav1.Now()
+		}
+
+		merged = append(merged, cond)
+	}
+
+	return merged
+}
@@ -0,0 +1,97 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	cases := []struct {
+		name        string
+		conditions  []Condition
+		cond        Condition
+		wantLen     int
+		keepOldTime bool
+	}{
+		{
+			name:       "add new condition",
+			conditions: nil,
+			cond:       Condition{Type: ConditionReady, Status: corev1.ConditionTrue},
+			wantLen:    1,
+		},
+		{
+			name: "status not changed",
+			conditions: []Condition{
+				{Type: ConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: past},
+			},
+			cond:        Condition{Type: ConditionReady, Status: corev1.ConditionTrue, Reason: "Other"},
+			wantLen:     1,
+			keepOldTime: true,
+		},
+		{
+			name: "status changed",
+			conditions: []Condition{
+				{Type: ConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: past},
+				{Type: ConditionStalled, Status: corev1.ConditionFalse, LastTransitionTime: past},
+			},
+			cond:    Condition{Type: ConditionReady, Status: corev1.ConditionFalse},
+			wantLen: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conditions := SetCondition(c.conditions, c.cond)
+			require.Len(t, conditions, c.wantLen)
+
+			cond := FindCondition(conditions, c.cond.Type)
+			require.NotNil(t, cond)
+			require.Equal(t, c.cond.Status, cond.Status)
+			require.Equal(t, c.cond.Reason, cond.Reason)
+			require.False(t, cond.LastTransitionTime.IsZero())
+			require.Equal(t, c.keepOldTime, cond.LastTransitionTime.Equal(&past))
+		})
+	}
+}
+
+func TestMergeConditions(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	previous := []Condition{
+		{Type: ConditionReady, Status: corev1.ConditionTrue, LastTransitionTime: past},
+		{Type: ConditionStalled, Status: corev1.ConditionFalse, LastTransitionTime: past},
+		{Type: "core", Status: corev1.ConditionTrue, LastTransitionTime: past},
+	}
+
+	merged := MergeConditions(previous, []Condition{
+		{Type: ConditionReady, Status: corev1.ConditionTrue},
+		{Type: ConditionStalled, Status: corev1.ConditionTrue},
+	})
+
+	require.Len(t, merged, 2)
+	require.True(t, merged[0].LastTransitionTime.Equal(&past))
+	require.False(t, merged[1].LastTransitionTime.Equal(&past))
+	require.True(t, IsConditionTrue(merged, ConditionStalled))
+	require.Nil(t, FindCondition(merged, "core"))
+}
@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:categories="goharbor",shortName="hs"
+// +kubebuilder:printcolumn:name="Harbor Server",type=string,JSONPath=`.spec.serverURL`,description="The public URL to the Harbor server",priority=0
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`,description="The status of the Harbor server",priority=0
+// +kubebuilder:printcolumn:name="Active Server",type=string,JSONPath=`.status.activeServerURL`,description="The endpoint of the Harbor server the operator is talking to",priority=5
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.serverInfo.version`,description="The detected version of the Harbor server",priority=5
+// HarborServer is the namespaced counterpart of HarborServerConfiguration.
+// It can be created by the namespace owners, the secrets it refers must be in the same namespace
+// and it is only used by the namespace annotations, the ConfigMap rules and the PullSecretBindings of its namespace.
+// The default flag and the namespace selector are ignored.
+type HarborServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HarborServerConfigurationSpec   `json:"spec,omitempty"`
+	Status HarborServerConfigurationStatus `json:"status,omitempty"`
+}
+
+// ToServerConfiguration returns the harbor server configuration view of the harbor server,
+// so the clients and the health checks of the harbor server configurations can be reused.
+// The referred secrets are always looked up in the namespace of the harbor server.
+func (in *HarborServer) ToServerConfiguration() *HarborServerConfiguration {
+	hsc := &HarborServerConfiguration{
+		TypeMeta:   in.TypeMeta,
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Spec:       *in.Spec.DeepCopy(),
+		Status:     *in.Status.DeepCopy(),
+	}
+
+	spec := &hsc.Spec
+	spec.Default = false
+	spec.NamespaceSelector = nil
+	if spec.AccessCredential != nil {
+		spec.AccessCredential.Namespace = in.Namespace
+	}
+	if spec.CABundleRef != nil {
+		spec.CABundleRef.Namespace = in.Namespace
+	}
+	if spec.ClientCertificate != nil {
+		spec.ClientCertificate.Namespace = in.Namespace
+	}
+	if spec.Proxy != nil && spec.Proxy.Credential != nil {
+		spec.Proxy.Credential.Namespace = in.Namespace
+	}
+	for i := range spec.ProxyCaches {
+		if spec.ProxyCaches[i].Credential != nil {
+			spec.ProxyCaches[i].Credential.Namespace = in.Namespace
+		}
+	}
+
+	return hsc
+}
+
+// +kubebuilder:object:root=true
+
+// HarborServerList contains a list of HarborServer
+type HarborServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HarborServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HarborServer{}, &HarborServerList{})
+}
@@ -0,0 +1,50 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHarborServer_ToServerConfiguration(t *testing.T) {
+	hs := &HarborServer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "harbor"},
+		Spec: HarborServerConfigurationSpec{
+			ServerURL:         "harbor.example.com",
+			Default:           true,
+			AccessCredential:  &AccessCredential{Namespace: "kube-system", AccessSecretRef: "admin"},
+			CABundleRef:       &CABundleReference{Kind: CABundleRefKindSecret, Namespace: "kube-system", Name: "ca"},
+			ClientCertificate: &ClientCertificate{Namespace: "kube-system", SecretRef: "tls"},
+			Proxy:             &Proxy{Credential: &ProxyCredential{Namespace: "kube-system", SecretRef: "proxy"}},
+			ProxyCaches:       []ProxyCache{{Name: "dockerhub", Credential: &RegistryCredential{Namespace: "kube-system", SecretRef: "dockerhub"}}},
+		},
+	}
+
+	hsc := hs.ToServerConfiguration()
+	require.Equal(t, "team-a/harbor", hsc.Key())
+	require.False(t, hsc.Spec.Default)
+	require.Equal(t, "team-a", hsc.Spec.AccessCredential.Namespace)
+	require.Equal(t, "team-a", hsc.Spec.CABundleRef.Namespace)
+	require.Equal(t, "team-a", hsc.Spec.ClientCertificate.Namespace)
+	require.Equal(t, "team-a", hsc.Spec.Proxy.Credential.Namespace)
+	require.Equal(t, "team-a", hsc.Spec.ProxyCaches[0].Credential.Namespace)
+	// The harbor server is not changed
+	require.Equal(t, "kube-system", hs.Spec.AccessCredential.Namespace)
+}
@@ -17,7 +17,14 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"sigs.k8s.io/kustomize/kstatus/status"
@@ -35,10 +42,27 @@ type HarborServerConfigurationSpec struct {
 	// +kubebuilder:validation:Pattern="(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$|^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)+([A-Za-z]|[A-Za-z][A-Za-z0-9\\-]*[A-Za-z0-9])"
 	ServerURL string `json:"serverURL"`
 
-	// Indicate if the Harbor server is an insecure registry
+	// SecondaryServerURLs are the ordered endpoints of the replicated Harbor servers.
+	// The operator fails over to the first healthy one when the primary serverURL is unhealthy.
+	// The Harbor servers should share the same access credential.
+	// +kubebuilder:validation:Optional
+	SecondaryServerURLs []ServerURL `json:"secondaryServerURLs,omitempty"`
+
+	// Indicate if the Harbor server is an insecure registry.
+	// The certificate of the Harbor server will not be verified if it is set to true.
 	// +kubebuilder:validation:Optional
 	InSecure bool `json:"inSecure,omitempty"`
 
+	// CABundle is the PEM encoded CA bundle used to verify the certificate of the Harbor server.
+	// The system trust store is used if neither caBundle nor caBundleRef is set.
+	// +kubebuilder:validation:Optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// CABundleRef refers a Secret or ConfigMap that keeps the PEM encoded CA bundle.
+	// Only one of caBundle and caBundleRef can be set.
+	// +kubebuilder:validation:Optional
+	CABundleRef *CABundleReference `json:"caBundleRef,omitempty"`
+
 	// Default indicates the harbor configuration manages namespaces.
 	// Value in goharbor.io/harbor annotation will be considered with high priority.
 	// At most, one HarborServerConfiguration can be the default, multiple defaults will be rejected.
@@ -48,15 +72,91 @@ type HarborServerConfigurationSpec struct {
 	// +kubebuilder:validation:Required
 	AccessCredential *AccessCredential `json:"accessCredential"`
 
-	// The version of the Harbor server
-	// +kubebuilder:validation:Required
+	// ManagedCredential lets the operator create a system level robot account with the access credential,
+	// which is only used as the bootstrap credential. The operator talks to the Harbor server with the robot account
+	// and rotates its secret periodically. The access credential is used again only when the permissions required
+	// by the configuration are changed. It requires Harbor v2.2 or later.
+	// +kubebuilder:validation:Optional
+	ManagedCredential *ManagedCredential `json:"managedCredential,omitempty"`
+
+	// RobotCredential decides the lifetime of the robot accounts of the pull secrets, they never expire if not set.
+	// It can be overridden by the pull secret bindings.
+	// +kubebuilder:validation:Optional
+	RobotCredential *RobotCredentialPolicy `json:"robotCredential,omitempty"`
+
+	// ClientCertificate refers a kubernetes.io/tls secret whose certificate and key are presented to the
+	// Harbor server (or the ingress in front of it) for mutual TLS authentication.
+	// +kubebuilder:validation:Optional
+	ClientCertificate *ClientCertificate `json:"clientCertificate,omitempty"`
+
+	// Proxy used to reach the Harbor server.
+	// The proxy settings of the operator environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY) are used if it is not set.
+	// +kubebuilder:validation:Optional
+	Proxy *Proxy `json:"proxy,omitempty"`
+
+	// RateLimit limits the API calls sent to the Harbor server by the operator.
+	// The default limits are applied if it is not set.
+	// +kubebuilder:validation:Optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// CircuitBreaker stops calling the Harbor server for a while after consecutive failures.
+	// The default settings are applied if it is not set.
+	// +kubebuilder:validation:Optional
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// The declared version of the Harbor server.
+	// The version of the Harbor server is detected automatically, a VersionMismatch condition is raised if they are different.
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Pattern="(0|[1-9]\\d*)\\.(0|[1-9]\\d*)\\.(0|[1-9]\\d*)(?:-((?:0|[1-9]\\d*|\\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\\.(?:0|[1-9]\\d*|\\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?(?:\\+([0-9a-zA-Z-]+(?:\\.[0-9a-zA-Z-]+)*))?"
-	Version string `json:"version"`
+	Version string `json:"version,omitempty"`
 
 	// Rules configures the container image rewrite rules for transparent proxy caching with Harbor.
 	// +kubebuilder:validation:Optional
 	Rules []string `json:"rules,omitempty"`
 
+	// ProxyCaches declares the upstream registries cached by the proxy-cache projects of the Harbor server.
+	// The registry endpoints and proxy-cache projects are provisioned by the operator and their rewrite rules are generated.
+	// +kubebuilder:validation:Optional
+	ProxyCaches []ProxyCache `json:"proxyCaches,omitempty"`
+
+	// DeletionPolicy decides how the pull secret bindings referring the configuration are handled when it is deleted, default to Orphan.
+	// Block keeps the configuration until it is not referred by any bindings or namespaces,
+	// Orphan leaves the bindings, pull secrets and robot accounts as they are,
+	// Cascade revokes the robot accounts and removes the pull secrets and bindings.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Block;Orphan;Cascade
+	// +kubebuilder:default=Orphan
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// ProjectNameTemplate is the Go template of the names of the projects created for the namespaces annotated with project "*".
+	// The template is rendered with .Cluster (the cluster name of the operator), .Namespace, .Labels (the namespace labels)
+	// and .Hash (a short hash of the cluster and namespace names). The rendered name is lowercased, the characters not allowed
+	// by Harbor are replaced with "-" and it is truncated with a hash suffix if too long. Default to "{{ .Namespace }}-{{ .Hash }}".
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength=1024
+	ProjectNameTemplate string `json:"projectNameTemplate,omitempty"`
+
+	// ProjectSettings are the default settings of the projects of the namespaces, they can be overridden by the namespace annotations.
+	// The settings are kept in sync with the projects, the changes made in Harbor are reverted.
+	// +kubebuilder:validation:Optional
+	ProjectSettings *ProjectSettings `json:"projectSettings,omitempty"`
+
+	// ProjectMemberRoles maps the ClusterRoles (e.g: admin) or the Roles (e.g: Role/deployer) referred by the RoleBindings in the namespaces
+	// to the Harbor project roles (projectAdmin, maintainer, developer or guest). The users and groups bound to the mapped roles
+	// are kept as the members of the project of the namespace, the members added by the operator and no longer bound are removed.
+	// It can be overridden by the goharbor.io/project-member-roles annotation of the namespace.
+	// +kubebuilder:validation:Optional
+	ProjectMemberRoles map[string]string `json:"projectMemberRoles,omitempty"`
+
+	// NamespaceDeletionPolicy decides how the project of the namespace is handled when the namespace is deleted, default to Retain.
+	// Retain leaves the project and robot accounts as they are, DeleteRobotsOnly revokes the robot accounts of the namespace,
+	// DeleteProjectIfEmpty also deletes the project if it is empty, Archive also makes the project read-only and labels its artifacts as archived.
+	// It can be overridden by the goharbor.io/namespace-deletion-policy annotation of the namespace.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Retain;DeleteRobotsOnly;DeleteProjectIfEmpty;Archive
+	// +kubebuilder:default=Retain
+	NamespaceDeletionPolicy string `json:"namespaceDeletionPolicy,omitempty"`
+
 	// NamespaceSelector decides whether to apply the HSC on a namespace based
 	// on whether the namespace matches the selector.
 	// See
@@ -68,8 +168,112 @@ type HarborServerConfigurationSpec struct {
 	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
 }
 
+// DefaultRotationInterval is the default rotation interval of the managed robot secret
+const DefaultRotationInterval = 30 * 24 * time.Hour
+
+// DefaultRobotRotationWindow is the default period before the expiry in which the robot account of the pull secret is replaced
+const DefaultRobotRotationWindow = 24 * time.Hour
+
+// MinRobotTTL is the minimum TTL of the robot accounts of the pull secrets, so they can be rotated before the expiry
+const MinRobotTTL = time.Hour
+
+// MinRobotRotationWindow is the minimum rotation window of the robot accounts of the pull secrets,
+// it covers a couple of the periodic reconciles of the bindings
+const MinRobotRotationWindow = 15 * time.Minute
+
+// DefaultRobotGracePeriod is the default period the replaced robot account of the pull secret is kept before it is revoked
+const DefaultRobotGracePeriod = time.Hour
+
+const (
+	// MemberRoleKindClusterRole is the kind of the ClusterRoles mapped by ProjectMemberRoles
+	MemberRoleKindClusterRole = "ClusterRole"
+	// MemberRoleKindRole is the kind of the Roles mapped by ProjectMemberRoles
+	MemberRoleKindRole = "Role"
+)
+
+// ParseMemberRole parses the role mapped by ProjectMemberRoles into its kind and name.
+// The role without the kind is a ClusterRole, a Role has to be explicitly prefixed with Role/ as it can be created in the namespace.
+func ParseMemberRole(role string) (string, string, error) {
+	kind, name := MemberRoleKindClusterRole, role
+	if i := strings.Index(role, "/"); i >= 0 {
+		kind, name = role[:i], role[i+1:]
+	}
+
+	if kind != MemberRoleKindClusterRole && kind != MemberRoleKindRole {
+		return "", "", fmt.Errorf("role %q is not a Role or ClusterRole", role)
+	}
+
+	if len(name) == 0 {
+		return "", "", fmt.Errorf("role %q has no name", role)
+	}
+
+	return kind, name, nil
+}
+
+// DefaultProjectNameTemplate is the default template of the names of the projects created for the namespaces
+const DefaultProjectNameTemplate = "{{ .Namespace }}-{{ .Hash }}"
+
+// GetProjectNameTemplate returns the template of the names of the projects created for the namespaces
+func (in *HarborServerConfigurationSpec) GetProjectNameTemplate() string {
+	if len(strings.TrimSpace(in.ProjectNameTemplate)) == 0 {
+		return DefaultProjectNameTemplate
+	}
+
+	return in.ProjectNameTemplate
+}
+
+const (
+	// DeletionPolicyBlock keeps the configuration until it is not referred by any bindings or namespaces
+	DeletionPolicyBlock = "Block"
+	// DeletionPolicyOrphan deletes the configuration and leaves the bindings, pull secrets and robot accounts as they are
+	DeletionPolicyOrphan = "Orphan"
+	// DeletionPolicyCascade revokes the robot accounts and removes the pull secrets and bindings before deleting the configuration
+	DeletionPolicyCascade = "Cascade"
+)
+
+const (
+	// NamespaceDeletionPolicyRetain leaves the project and robot accounts of the deleted namespace as they are
+	NamespaceDeletionPolicyRetain = "Retain"
+	// NamespaceDeletionPolicyDeleteRobotsOnly revokes the robot accounts of the deleted namespace
+	NamespaceDeletionPolicyDeleteRobotsOnly = "DeleteRobotsOnly"
+	// NamespaceDeletionPolicyDeleteProjectIfEmpty revokes the robot accounts and deletes the project of the deleted namespace if it is empty
+	NamespaceDeletionPolicyDeleteProjectIfEmpty = "DeleteProjectIfEmpty"
+	// NamespaceDeletionPolicyArchive revokes the robot accounts, makes the project of the deleted namespace read-only with the guest members,
+	// an immutability rule of all the tags and the storage quota limited to the used storage, then labels all its artifacts as archived
+	NamespaceDeletionPolicyArchive = "Archive"
+)
+
+// NamespaceDeletionPolicies are the valid namespace deletion policies
+var NamespaceDeletionPolicies = []string{
+	NamespaceDeletionPolicyRetain,
+	NamespaceDeletionPolicyDeleteRobotsOnly,
+	NamespaceDeletionPolicyDeleteProjectIfEmpty,
+	NamespaceDeletionPolicyArchive,
+}
+
+const (
+	// AccessCredentialTypeBasic is the basic auth of a Harbor (admin) user.
+	// The secret keeps the username in `accessKey` and the password in `accessSecret`.
+	AccessCredentialTypeBasic = "basic"
+	// AccessCredentialTypeRobot is a system level robot account.
+	// The secret keeps the full robot name (e.g: robot$automation) in `robotName` and its secret in `robotSecret`.
+	AccessCredentialTypeRobot = "robot"
+	// AccessCredentialTypeOIDC is the CLI secret of an OIDC user.
+	// The secret keeps the username in `username` and the CLI secret in `cliSecret`.
+	AccessCredentialTypeOIDC = "oidc"
+	// AccessCredentialTypeBearer is a static bearer token.
+	// The secret keeps the token in `token`.
+	AccessCredentialTypeBearer = "bearer"
+)
+
 // AccessCredential is a namespaced credential to keep the access key and secret for the harbor server configuration
 type AccessCredential struct {
+	// Type of the credential kept in the secret, default to basic
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=basic;robot;oidc;bearer
+	// +kubebuilder:default=basic
+	Type string `json:"type,omitempty"`
+
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
 	Namespace string `json:"namespace"`
@@ -79,6 +283,355 @@ type AccessCredential struct {
 	AccessSecretRef string `json:"accessSecretRef"`
 }
 
+// ProjectSettings are the settings of the Harbor project, the unset ones are not managed
+type ProjectSettings struct {
+	// Public makes the project public
+	// +kubebuilder:validation:Optional
+	Public *bool `json:"public,omitempty"`
+
+	// AutoScan scans the images automatically when they are pushed
+	// +kubebuilder:validation:Optional
+	AutoScan *bool `json:"autoScan,omitempty"`
+
+	// Severity is the threshold of the vulnerabilities preventing the images from being pulled
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=none;low;medium;high;critical
+	Severity string `json:"severity,omitempty"`
+
+	// PreventVulnerableImages prevents the vulnerable images from being pulled
+	// +kubebuilder:validation:Optional
+	PreventVulnerableImages *bool `json:"preventVulnerableImages,omitempty"`
+
+	// EnableContentTrust only allows the signed images to be pulled
+	// +kubebuilder:validation:Optional
+	EnableContentTrust *bool `json:"enableContentTrust,omitempty"`
+
+	// CVEAllowlist is the CVE IDs ignored by the vulnerability prevention, the system allowlist is used if it is not set
+	// +kubebuilder:validation:Optional
+	CVEAllowlist []string `json:"cveAllowlist,omitempty"`
+
+	// StorageLimit of the project, it is kept in sync with the quota of the project
+	// +kubebuilder:validation:Optional
+	StorageLimit *resource.Quantity `json:"storageLimit,omitempty"`
+}
+
+// ServerURL is the host of a Harbor server with an optional port, e.g: harbor.example.com or 10.0.0.1:8443
+// +kubebuilder:validation:Pattern="^((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\\.){3}(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)(:[0-9]{1,5})?$|^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\\-]*[a-zA-Z0-9])\\.)+([A-Za-z]|[A-Za-z][A-Za-z0-9\\-]*[A-Za-z0-9])(:[0-9]{1,5})?$"
+type ServerURL string
+
+// ProxyCache is an upstream registry cached by a proxy-cache project of the Harbor server
+type ProxyCache struct {
+	// Name of the registry endpoint created in Harbor
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="^[a-z0-9]+(?:[._-][a-z0-9]+)*$"
+	Name string `json:"name"`
+
+	// Type of the upstream registry
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=harbor;docker-hub;docker-registry;aws-ecr;azure-acr;google-gcr;quay;gitlab;jfrog-artifactory;github-ghcr
+	Type string `json:"type"`
+
+	// URL of the upstream registry, e.g: https://registry-1.docker.io
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="^https?://.+"
+	URL string `json:"url"`
+
+	// Insecure skips verifying the certificate of the upstream registry
+	// +kubebuilder:validation:Optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// Credential refers the secret keeping the access key and secret of the upstream registry, it is accessed anonymously if not set
+	// +kubebuilder:validation:Optional
+	Credential *RegistryCredential `json:"credential,omitempty"`
+
+	// Project is the name of the proxy-cache project, default to the name of the proxy cache
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^[a-z0-9]+(?:[._-][a-z0-9]+)*$"
+	Project string `json:"project,omitempty"`
+
+	// Public makes the proxy-cache project public, so the images can be pulled without the pull secrets, default to true
+	// +kubebuilder:validation:Optional
+	Public *bool `json:"public,omitempty"`
+
+	// Registry is the regular expression of the image registries rewritten to the proxy-cache project,
+	// default to the host of the URL (docker.io for docker-hub)
+	// +kubebuilder:validation:Optional
+	Registry string `json:"registry,omitempty"`
+}
+
+// RegistryCredential is a namespaced secret keeping the credential of the upstream registry in `accessKey` and `accessSecret`
+type RegistryCredential struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	SecretRef string `json:"secretRef"`
+}
+
+// GetProject returns the name of the proxy-cache project
+func (in *ProxyCache) GetProject() string {
+	if len(in.Project) == 0 {
+		return in.Name
+	}
+
+	return in.Project
+}
+
+// IsPublic checks if the proxy-cache project is public
+func (in *ProxyCache) IsPublic() bool {
+	return in.Public == nil || *in.Public
+}
+
+// Rule returns the rewrite rule of the images of the upstream registry
+func (in *ProxyCache) Rule() string {
+	registry := in.Registry
+	if len(registry) == 0 {
+		host := in.URL
+		if u, err := url.Parse(in.URL); err == nil && len(u.Host) > 0 {
+			host = u.Host
+		}
+		if in.Type == "docker-hub" {
+			host = "docker.io"
+		}
+		registry = "^" + regexp.QuoteMeta(host) + "$"
+	}
+
+	return registry + "," + in.GetProject()
+}
+
+// RewriteRules returns the declared rewrite rules followed by the ones of the provisioned proxy caches
+func (in *HarborServerConfiguration) RewriteRules() []string {
+	rules := append([]string{}, in.Spec.Rules...)
+	for _, pc := range in.Status.ProxyCaches {
+		if len(pc.Rule) > 0 && !containsString(rules, pc.Rule) {
+			rules = append(rules, pc.Rule)
+		}
+	}
+
+	return rules
+}
+
+// ManagedCredential is the system level robot account managed by the operator
+type ManagedCredential struct {
+	// SecretRef is the name of the secret keeping the robot account, it is created in the namespace of the access credential
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	SecretRef string `json:"secretRef"`
+
+	// RotationInterval of the robot secret, default to 720h
+	// +kubebuilder:validation:Optional
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
+}
+
+// GetRotationInterval returns the rotation interval of the robot secret
+func (in *ManagedCredential) GetRotationInterval() time.Duration {
+	if in.RotationInterval == nil || in.RotationInterval.Duration <= 0 {
+		return DefaultRotationInterval
+	}
+
+	return in.RotationInterval.Duration
+}
+
+// RobotCredentialPolicy decides the lifetime of the robot accounts of the pull secrets
+type RobotCredentialPolicy struct {
+	// TTL of the robot accounts, they never expire if not set. It is at least 1h
+	// +kubebuilder:validation:Optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// RotationWindow is the period before the expiry in which the robot account is replaced by a new one,
+	// default to 24h, at least 15m and at most half of the TTL
+	// +kubebuilder:validation:Optional
+	RotationWindow *metav1.Duration `json:"rotationWindow,omitempty"`
+
+	// GracePeriod is how long the replaced robot account is kept before it is revoked,
+	// so the images being pulled with the old credential are not broken, default to 1h
+	// +kubebuilder:validation:Optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+}
+
+// GetTTL returns the TTL of the robot accounts, zero means they never expire.
+// The TTL shorter than MinRobotTTL is raised to it, e.g: the one of the binding which is not validated by the webhook.
+func (in *RobotCredentialPolicy) GetTTL() time.Duration {
+	if in.TTL == nil || in.TTL.Duration <= 0 {
+		return 0
+	}
+
+	if in.TTL.Duration < MinRobotTTL {
+		return MinRobotTTL
+	}
+
+	return in.TTL.Duration
+}
+
+// GetRotationWindow returns the period before the expiry in which the robot account is replaced
+func (in *RobotCredentialPolicy) GetRotationWindow() time.Duration {
+	window := DefaultRobotRotationWindow
+	if in.RotationWindow != nil && in.RotationWindow.Duration > 0 {
+		window = in.RotationWindow.Duration
+	}
+
+	if window < MinRobotRotationWindow {
+		window = MinRobotRotationWindow
+	}
+
+	// Leave the new robot account most of its lifetime
+	if ttl := in.GetTTL(); ttl > 0 && window > ttl/2 {
+		window = ttl / 2
+	}
+
+	return window
+}
+
+// GetGracePeriod returns how long the replaced robot account is kept before it is revoked
+func (in *RobotCredentialPolicy) GetGracePeriod() time.Duration {
+	if in.GracePeriod == nil || in.GracePeriod.Duration < 0 {
+		return DefaultRobotGracePeriod
+	}
+
+	return in.GracePeriod.Duration
+}
+
+// ServerURLs returns the primary server URL followed by the secondary ones
+func (s *HarborServerConfigurationSpec) ServerURLs() []string {
+	urls := []string{s.ServerURL}
+	for _, u := range s.SecondaryServerURLs {
+		if !containsString(urls, string(u)) {
+			urls = append(urls, string(u))
+		}
+	}
+
+	return urls
+}
+
+// ActiveServerURL returns the server URL the operator talks to.
+// It is the primary server URL if the active one is not detected yet or it is not one of the endpoints anymore.
+func (in *HarborServerConfiguration) ActiveServerURL() string {
+	if active := in.Status.ActiveServerURL; len(active) > 0 && containsString(in.Spec.ServerURLs(), active) {
+		return active
+	}
+
+	return in.Spec.ServerURL
+}
+
+// Key identifies the configuration among the harbor server configurations and the namespaced harbor servers.
+// It is the name of the configuration, or the namespace/name of the configuration viewed from a harbor server.
+func (in *HarborServerConfiguration) Key() string {
+	if len(in.Namespace) == 0 {
+		return in.Name
+	}
+
+	return in.Namespace + "/" + in.Name
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RateLimit of the API calls sent to the Harbor server
+type RateLimit struct {
+	// QPS is the sustained number of API calls per second, default to 10
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	QPS int32 `json:"qps,omitempty"`
+
+	// Burst is the max number of API calls sent at once, default to 20
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	Burst int32 `json:"burst,omitempty"`
+
+	// MaxInFlight is the max number of concurrent API calls, default to 10
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	MaxInFlight int32 `json:"maxInFlight,omitempty"`
+}
+
+// CircuitBreaker of the API calls sent to the Harbor server
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failed API calls opening the circuit, default to 5
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// OpenSeconds is how long the circuit keeps open before calling the Harbor server again, default to 30
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	OpenSeconds int32 `json:"openSeconds,omitempty"`
+}
+
+// ClientCertificate is a namespaced kubernetes.io/tls secret keeping the client certificate and key
+type ClientCertificate struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	SecretRef string `json:"secretRef"`
+}
+
+// Proxy settings for reaching the Harbor server
+type Proxy struct {
+	// URL of the HTTP(S) proxy, e.g: http://proxy.example.com:3128.
+	// The Harbor server is reached directly without any proxy if it is empty.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^https?://.+"
+	URL string `json:"url,omitempty"`
+
+	// NoProxy is the list of hosts, domains (e.g: .example.com), IPs or CIDRs reached directly
+	// +kubebuilder:validation:Optional
+	NoProxy []string `json:"noProxy,omitempty"`
+
+	// Credential refers a kubernetes.io/basic-auth secret keeping the username and password of the proxy
+	// +kubebuilder:validation:Optional
+	Credential *ProxyCredential `json:"credential,omitempty"`
+}
+
+// ProxyCredential is a namespaced kubernetes.io/basic-auth secret keeping the proxy credential
+type ProxyCredential struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	SecretRef string `json:"secretRef"`
+}
+
+const (
+	// CABundleRefKindSecret indicates the CA bundle is kept in a Secret
+	CABundleRefKindSecret = "Secret"
+	// CABundleRefKindConfigMap indicates the CA bundle is kept in a ConfigMap
+	CABundleRefKindConfigMap = "ConfigMap"
+)
+
+// CABundleReference refers a key of a namespaced Secret or ConfigMap that keeps the CA bundle of the harbor server
+type CABundleReference struct {
+	// Kind of the referred object
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	Kind string `json:"kind"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	Namespace string `json:"namespace"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern="[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*"
+	Name string `json:"name"`
+
+	// Key of the CA bundle in the referred object, default to "ca.crt"
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+}
+
 // HarborServerConfigurationStatus defines the observed state of HarborServerConfiguration
 type HarborServerConfigurationStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
@@ -91,6 +644,124 @@ type HarborServerConfigurationStatus struct {
 	// +listType:map
 	// +listMapKey:type
 	Conditions []Condition `json:"conditions"`
+
+	// ObservedGeneration is the most recent generation observed by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ServerInfo is the info detected from the Harbor server
+	// +kubebuilder:validation:Optional
+	ServerInfo *ServerInfo `json:"serverInfo,omitempty"`
+
+	// ManagedCredential is the robot account managed by the operator
+	// +kubebuilder:validation:Optional
+	ManagedCredential *ManagedCredentialStatus `json:"managedCredential,omitempty"`
+
+	// ActiveServerURL is the endpoint the operator is talking to, it is the first healthy one of the endpoints
+	// +kubebuilder:validation:Optional
+	ActiveServerURL string `json:"activeServerURL,omitempty"`
+
+	// Endpoints is the health of each endpoint
+	// +kubebuilder:validation:Optional
+	Endpoints []EndpointStatus `json:"endpoints,omitempty"`
+
+	// ProxyCaches are the provisioned proxy caches
+	// +kubebuilder:validation:Optional
+	ProxyCaches []ProxyCacheStatus `json:"proxyCaches,omitempty"`
+}
+
+// ProxyCacheStatus is the registry endpoint and proxy-cache project provisioned for a proxy cache
+type ProxyCacheStatus struct {
+	// Name of the proxy cache
+	Name string `json:"name"`
+
+	// RegistryID is the ID of the registry endpoint
+	RegistryID int64 `json:"registryID"`
+
+	// CredentialHash is the hash of the credential of the registry endpoint, the changed credential is updated to the endpoint
+	// +kubebuilder:validation:Optional
+	CredentialHash string `json:"credentialHash,omitempty"`
+
+	// ProjectID is the ID of the proxy-cache project
+	ProjectID int64 `json:"projectID"`
+
+	// Rule is the rewrite rule generated for the proxy-cache project
+	Rule string `json:"rule"`
+}
+
+// ManagedCredentialStatus is the status of the robot account managed by the operator
+type ManagedCredentialStatus struct {
+	// RobotID is the ID of the robot account
+	RobotID int64 `json:"robotID"`
+
+	// RobotName is the full name of the robot account
+	RobotName string `json:"robotName"`
+
+	// LastRotationTime is the last time the robot secret was created or rotated
+	// +kubebuilder:validation:Optional
+	LastRotationTime metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// PermissionsHash is the hash of the permissions the robot account is granted,
+	// the access credential is only used again to grant the permissions when they are changed
+	// +kubebuilder:validation:Optional
+	PermissionsHash string `json:"permissionsHash,omitempty"`
+}
+
+// EndpointStatus is the health of an endpoint of the Harbor server
+type EndpointStatus struct {
+	// ServerURL of the endpoint
+	ServerURL string `json:"serverURL"`
+
+	// Healthy indicates if the endpoint is healthy
+	Healthy bool `json:"healthy"`
+
+	// Message describes why the endpoint is unhealthy
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+
+	// LastProbeTime is the last time the endpoint was probed
+	// +kubebuilder:validation:Optional
+	LastProbeTime metav1.Time `json:"lastProbeTime,omitempty"`
+}
+
+// ServerInfo defines the info detected from the Harbor server
+type ServerInfo struct {
+	// Version of the Harbor server, e.g: v2.1.2-2b6a5a2e
+	Version string `json:"version,omitempty"`
+
+	// AuthMode of the Harbor server, e.g: db_auth, ldap_auth or oidc_auth
+	AuthMode string `json:"authMode,omitempty"`
+
+	// RegistryURL against which the docker command should be issued
+	RegistryURL string `json:"registryURL,omitempty"`
+
+	// WithNotary indicates if the Harbor server is deployed with notary
+	WithNotary bool `json:"withNotary,omitempty"`
+
+	// WithChartmuseum indicates if the Harbor server is deployed with chartmuseum
+	WithChartmuseum bool `json:"withChartmuseum,omitempty"`
+
+	// ReadOnly indicates if the Harbor server is in read only mode.
+	// It is not set if the credential has no permission to read the system configurations.
+	ReadOnly *bool `json:"readOnly,omitempty"`
+
+	// Capabilities of the Harbor server detected from the version and the deployed components
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// HasCapability checks if the Harbor server has the capability, it is assumed to be true if the capabilities are not detected yet
+func (in *ServerInfo) HasCapability(capability string) bool {
+	if in == nil || len(in.Capabilities) == 0 {
+		return true
+	}
+
+	for _, c := range in.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Condition defines the general format for conditions on Kubernetes resources.
@@ -112,6 +783,14 @@ type Condition struct {
 	// +kubebuilder:validation:Optional
 	// Message Human readable reason string
 	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastTransitionTime the last time the condition transitioned from one status to another
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration the generation of the resource the condition was set based upon
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -121,7 +800,8 @@ type Condition struct {
 // +kubebuilder:resource:categories="goharbor",shortName="hsc",scope="Cluster"
 // +kubebuilder:printcolumn:name="Harbor Server",type=string,JSONPath=`.spec.serverURL`,description="The public URL to the Harbor server",priority=0
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`,description="The status of the Harbor server",priority=0
-// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.version`,description="The version of the Harbor server",priority=5
+// +kubebuilder:printcolumn:name="Active Server",type=string,JSONPath=`.status.activeServerURL`,description="The endpoint of the Harbor server the operator is talking to",priority=5
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.serverInfo.version`,description="The detected version of the Harbor server",priority=5
 // HarborServerConfiguration is the Schema for the harborserverconfigurations API
 type HarborServerConfiguration struct {
 	metav1.TypeMeta   `json:",inline"`
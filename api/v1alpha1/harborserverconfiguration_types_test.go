@@ -0,0 +1,137 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProxyCache_Rule(t *testing.T) {
+	cases := []struct {
+		name  string
+		cache ProxyCache
+		want  string
+	}{
+		{
+			name:  "docker hub",
+			cache: ProxyCache{Name: "dockerhub", Type: "docker-hub", URL: "https://registry-1.docker.io"},
+			want:  `^docker\.io$,dockerhub`,
+		},
+		{
+			name:  "host of url",
+			cache: ProxyCache{Name: "quay", Type: "quay", URL: "https://quay.io", Project: "quay-cache"},
+			want:  `^quay\.io$,quay-cache`,
+		},
+		{
+			name:  "custom registry",
+			cache: ProxyCache{Name: "gcr", Type: "google-gcr", URL: "https://gcr.io", Registry: `^(.+\.)?gcr\.io$`},
+			want:  `^(.+\.)?gcr\.io$,gcr`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.cache.Rule())
+		})
+	}
+}
+
+func TestHarborServerConfiguration_RewriteRules(t *testing.T) {
+	hsc := &HarborServerConfiguration{
+		Spec: HarborServerConfigurationSpec{Rules: []string{`^docker\.io$,library`}},
+		Status: HarborServerConfigurationStatus{ProxyCaches: []ProxyCacheStatus{
+			{Name: "dockerhub", Rule: `^docker\.io$,library`},
+			{Name: "quay", Rule: `^quay\.io$,quay`},
+		}},
+	}
+
+	require.Equal(t, []string{`^docker\.io$,library`, `^quay\.io$,quay`}, hsc.RewriteRules())
+}
+
+func TestRobotCredentialPolicy_GetRotationWindow(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RobotCredentialPolicy
+		want   time.Duration
+	}{
+		{
+			name:   "default",
+			policy: RobotCredentialPolicy{},
+			want:   DefaultRobotRotationWindow,
+		},
+		{
+			name:   "custom",
+			policy: RobotCredentialPolicy{TTL: &metav1.Duration{Duration: 30 * 24 * time.Hour}, RotationWindow: &metav1.Duration{Duration: 72 * time.Hour}},
+			want:   72 * time.Hour,
+		},
+		{
+			name:   "capped by ttl",
+			policy: RobotCredentialPolicy{TTL: &metav1.Duration{Duration: 12 * time.Hour}},
+			want:   6 * time.Hour,
+		},
+		{
+			name:   "capped by minimum ttl",
+			policy: RobotCredentialPolicy{TTL: &metav1.Duration{Duration: time.Minute}},
+			want:   MinRobotTTL / 2,
+		},
+		{
+			name:   "raised to minimum",
+			policy: RobotCredentialPolicy{RotationWindow: &metav1.Duration{Duration: time.Minute}},
+			want:   MinRobotRotationWindow,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.policy.GetRotationWindow())
+		})
+	}
+}
+
+func TestRobotCredentialPolicy_GetTTL(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy RobotCredentialPolicy
+		want   time.Duration
+	}{
+		{
+			name:   "never expire",
+			policy: RobotCredentialPolicy{},
+			want:   0,
+		},
+		{
+			name:   "custom",
+			policy: RobotCredentialPolicy{TTL: &metav1.Duration{Duration: 72 * time.Hour}},
+			want:   72 * time.Hour,
+		},
+		{
+			name:   "raised to minimum",
+			policy: RobotCredentialPolicy{TTL: &metav1.Duration{Duration: time.Minute}},
+			want:   MinRobotTTL,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.want, tc.policy.GetTTL())
+		})
+	}
+}
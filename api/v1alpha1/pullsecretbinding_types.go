@@ -28,7 +28,7 @@ type PullSecretBindingSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// RobotID points to the robot account id used for secret binding
+	// RobotID points to the robot account id used for secret binding, it is replaced by the robot account created by the binding when it is bound
 	// +kubebuilder:validation:Required
 	RobotID string `json:"robotId"`
 
@@ -41,6 +41,85 @@ type PullSecretBindingSpec struct {
 
 	// Indicate which service account binds the pull secret
 	ServiceAccount string `json:"serviceAccount"`
+
+	// PullProjects are the names of the additional projects the images can be pulled from with the pull secret,
+	// a pull-only robot account is created in each of them
+	// +kubebuilder:validation:Optional
+	PullProjects []string `json:"pullProjects,omitempty"`
+
+	// RobotCredential overrides the lifetime of the robot accounts decided by the harbor server configuration
+	// +kubebuilder:validation:Optional
+	RobotCredential *RobotCredentialPolicy `json:"robotCredential,omitempty"`
+
+	// Permissions of the robot account on the project, default to pull only.
+	// push, helm-chart-pull and scan are granted in addition only if they are listed.
+	// +kubebuilder:validation:Optional
+	Permissions []RobotPermission `json:"permissions,omitempty"`
+}
+
+// RobotPermission is a permission of the robot account of the pull secret on the project
+// +kubebuilder:validation:Enum=pull;push;helm-chart-pull;scan
+type RobotPermission string
+
+const (
+	// RobotPermissionPull allows pulling the images of the project, it is always granted
+	RobotPermissionPull RobotPermission = "pull"
+	// RobotPermissionPush allows pushing the images to the project
+	RobotPermissionPush RobotPermission = "push"
+	// RobotPermissionHelmChartPull allows pulling the helm charts of the project
+	RobotPermissionHelmChartPull RobotPermission = "helm-chart-pull"
+	// RobotPermissionScan allows scanning the images of the project
+	RobotPermissionScan RobotPermission = "scan"
+)
+
+// GetPermissions returns the permissions of the robot account, pull is always included
+func (in *PullSecretBindingSpec) GetPermissions() []RobotPermission {
+	permissions := []RobotPermission{RobotPermissionPull}
+	for _, p := range in.Permissions {
+		if !containsPermission(permissions, p) {
+			permissions = append(permissions, p)
+		}
+	}
+
+	return permissions
+}
+
+func containsPermission(permissions []RobotPermission, p RobotPermission) bool {
+	for _, item := range permissions {
+		if item == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PullRobot is the pull-only robot account created in an additional project
+type PullRobot struct {
+	// Project is the name of the additional project
+	Project string `json:"project"`
+
+	// ProjectID is the ID of the additional project
+	ProjectID int64 `json:"projectId"`
+
+	// RobotID is the ID of the robot account
+	RobotID int64 `json:"robotId"`
+
+	// ExpiresAt is the expiry of the robot account, it never expires if not set
+	// +kubebuilder:validation:Optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// RetiredRobot is a replaced robot account waiting to be revoked
+type RetiredRobot struct {
+	// ProjectID is the ID of the project of the robot account
+	ProjectID int64 `json:"projectId"`
+
+	// RobotID is the ID of the robot account
+	RobotID int64 `json:"robotId"`
+
+	// RevokeAfter is the time after which the robot account is revoked
+	RevokeAfter metav1.Time `json:"revokeAfter"`
 }
 
 // PullSecretBindingStatus defines the observed state of PullSecretBinding
@@ -55,6 +134,38 @@ type PullSecretBindingStatus struct {
 	// +listType:map
 	// +listMapKey:type
 	Conditions []Condition `json:"conditions"`
+
+	// ObservedGeneration is the most recent generation observed by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// PullRobots are the robot accounts created in the additional projects, their credentials are merged into the pull secret
+	// +kubebuilder:validation:Optional
+	PullRobots []PullRobot `json:"pullRobots,omitempty"`
+
+	// RobotExpiresAt is the expiry of the robot account of the pull secret, it never expires if not set
+	// +kubebuilder:validation:Optional
+	RobotExpiresAt *metav1.Time `json:"robotExpiresAt,omitempty"`
+
+	// RetiredRobots are the replaced robot accounts kept until their grace period is over
+	// +kubebuilder:validation:Optional
+	RetiredRobots []RetiredRobot `json:"retiredRobots,omitempty"`
+
+	// Repairs are the recent repairs of the binding after its project or robot account drifted in harbor, the latest is the last
+	// +kubebuilder:validation:Optional
+	Repairs []BindingRepair `json:"repairs,omitempty"`
+}
+
+// BindingRepair records a repair of the binding
+type BindingRepair struct {
+	// Time when the binding was repaired
+	Time metav1.Time `json:"time"`
+
+	// Reason is the drift repaired: `ProjectNotFound`, `RobotNotFound`, `RobotDisabled` or `SecretNotFound`
+	Reason string `json:"reason"`
+
+	// Message describes the repair
+	Message string `json:"message"`
 }
 
 // +kubebuilder:object:root=true
@@ -65,6 +176,7 @@ type PullSecretBindingStatus struct {
 // +kubebuilder:printcolumn:name="Harbor Server",type=string,JSONPath=`.spec.harborServerConfig`,description="The Harbor server configuration CR reference",priority=0
 // +kubebuilder:printcolumn:name="Service Account",type=string,JSONPath=`.spec.serviceAccount`,description="The service account binding the pull secret",priority=0
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.status`,description="The status of the Harbor server",priority=0
+// +kubebuilder:printcolumn:name="Robot Expires",type=date,JSONPath=`.status.robotExpiresAt`,description="The expiry of the robot account of the pull secret",priority=1
 
 // PullSecretBinding is the Schema for the pullsecretbindings API
 type PullSecretBinding struct {
@@ -41,9 +41,71 @@ func (in *AccessCredential) DeepCopy() *AccessCredential {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BindingRepair) DeepCopyInto(out *BindingRepair) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BindingRepair.
+func (in *BindingRepair) DeepCopy() *BindingRepair {
+	if in == nil {
+		return nil
+	}
+	out := new(BindingRepair)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CABundleReference) DeepCopyInto(out *CABundleReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CABundleReference.
+func (in *CABundleReference) DeepCopy() *CABundleReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CABundleReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreaker) DeepCopyInto(out *CircuitBreaker) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreaker.
+func (in *CircuitBreaker) DeepCopy() *CircuitBreaker {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreaker)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClientCertificate) DeepCopyInto(out *ClientCertificate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientCertificate.
+func (in *ClientCertificate) DeepCopy() *ClientCertificate {
+	if in == nil {
+		return nil
+	}
+	out := new(ClientCertificate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Condition) DeepCopyInto(out *Condition) {
 	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
@@ -56,6 +118,49 @@ func (in *Condition) DeepCopy() *Condition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EndpointStatus) DeepCopyInto(out *EndpointStatus) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EndpointStatus.
+func (in *EndpointStatus) DeepCopy() *EndpointStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EndpointStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborServer) DeepCopyInto(out *HarborServer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborServer.
+func (in *HarborServer) DeepCopy() *HarborServer {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarborServer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarborServerConfiguration) DeepCopyInto(out *HarborServerConfiguration) {
 	*out = *in
@@ -118,16 +223,75 @@ func (in *HarborServerConfigurationList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarborServerConfigurationSpec) DeepCopyInto(out *HarborServerConfigurationSpec) {
 	*out = *in
+	if in.SecondaryServerURLs != nil {
+		in, out := &in.SecondaryServerURLs, &out.SecondaryServerURLs
+		*out = make([]ServerURL, len(*in))
+		copy(*out, *in)
+	}
+	if in.CABundleRef != nil {
+		in, out := &in.CABundleRef, &out.CABundleRef
+		*out = new(CABundleReference)
+		**out = **in
+	}
 	if in.AccessCredential != nil {
 		in, out := &in.AccessCredential, &out.AccessCredential
 		*out = new(AccessCredential)
 		**out = **in
 	}
+	if in.ManagedCredential != nil {
+		in, out := &in.ManagedCredential, &out.ManagedCredential
+		*out = new(ManagedCredential)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RobotCredential != nil {
+		in, out := &in.RobotCredential, &out.RobotCredential
+		*out = new(RobotCredentialPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientCertificate != nil {
+		in, out := &in.ClientCertificate, &out.ClientCertificate
+		*out = new(ClientCertificate)
+		**out = **in
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(Proxy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		**out = **in
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreaker)
+		**out = **in
+	}
 	if in.Rules != nil {
 		in, out := &in.Rules, &out.Rules
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ProxyCaches != nil {
+		in, out := &in.ProxyCaches, &out.ProxyCaches
+		*out = make([]ProxyCache, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProjectSettings != nil {
+		in, out := &in.ProjectSettings, &out.ProjectSettings
+		*out = new(ProjectSettings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ProjectMemberRoles != nil {
+		in, out := &in.ProjectMemberRoles, &out.ProjectMemberRoles
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.NamespaceSelector != nil {
 		in, out := &in.NamespaceSelector, &out.NamespaceSelector
 		*out = new(v1.LabelSelector)
@@ -151,6 +315,30 @@ func (in *HarborServerConfigurationStatus) DeepCopyInto(out *HarborServerConfigu
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServerInfo != nil {
+		in, out := &in.ServerInfo, &out.ServerInfo
+		*out = new(ServerInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ManagedCredential != nil {
+		in, out := &in.ManagedCredential, &out.ManagedCredential
+		*out = new(ManagedCredentialStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]EndpointStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ProxyCaches != nil {
+		in, out := &in.ProxyCaches, &out.ProxyCaches
+		*out = make([]ProxyCacheStatus, len(*in))
 		copy(*out, *in)
 	}
 }
@@ -165,12 +353,224 @@ func (in *HarborServerConfigurationStatus) DeepCopy() *HarborServerConfiguration
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarborServerList) DeepCopyInto(out *HarborServerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HarborServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarborServerList.
+func (in *HarborServerList) DeepCopy() *HarborServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(HarborServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarborServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCredential) DeepCopyInto(out *ManagedCredential) {
+	*out = *in
+	if in.RotationInterval != nil {
+		in, out := &in.RotationInterval, &out.RotationInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCredential.
+func (in *ManagedCredential) DeepCopy() *ManagedCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedCredentialStatus) DeepCopyInto(out *ManagedCredentialStatus) {
+	*out = *in
+	in.LastRotationTime.DeepCopyInto(&out.LastRotationTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedCredentialStatus.
+func (in *ManagedCredentialStatus) DeepCopy() *ManagedCredentialStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedCredentialStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProjectSettings) DeepCopyInto(out *ProjectSettings) {
+	*out = *in
+	if in.Public != nil {
+		in, out := &in.Public, &out.Public
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AutoScan != nil {
+		in, out := &in.AutoScan, &out.AutoScan
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PreventVulnerableImages != nil {
+		in, out := &in.PreventVulnerableImages, &out.PreventVulnerableImages
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnableContentTrust != nil {
+		in, out := &in.EnableContentTrust, &out.EnableContentTrust
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CVEAllowlist != nil {
+		in, out := &in.CVEAllowlist, &out.CVEAllowlist
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StorageLimit != nil {
+		in, out := &in.StorageLimit, &out.StorageLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProjectSettings.
+func (in *ProjectSettings) DeepCopy() *ProjectSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(ProjectSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Proxy) DeepCopyInto(out *Proxy) {
+	*out = *in
+	if in.NoProxy != nil {
+		in, out := &in.NoProxy, &out.NoProxy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Credential != nil {
+		in, out := &in.Credential, &out.Credential
+		*out = new(ProxyCredential)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Proxy.
+func (in *Proxy) DeepCopy() *Proxy {
+	if in == nil {
+		return nil
+	}
+	out := new(Proxy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyCache) DeepCopyInto(out *ProxyCache) {
+	*out = *in
+	if in.Credential != nil {
+		in, out := &in.Credential, &out.Credential
+		*out = new(RegistryCredential)
+		**out = **in
+	}
+	if in.Public != nil {
+		in, out := &in.Public, &out.Public
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyCache.
+func (in *ProxyCache) DeepCopy() *ProxyCache {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyCacheStatus) DeepCopyInto(out *ProxyCacheStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyCacheStatus.
+func (in *ProxyCacheStatus) DeepCopy() *ProxyCacheStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyCacheStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProxyCredential) DeepCopyInto(out *ProxyCredential) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProxyCredential.
+func (in *ProxyCredential) DeepCopy() *ProxyCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(ProxyCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullRobot) DeepCopyInto(out *PullRobot) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullRobot.
+func (in *PullRobot) DeepCopy() *PullRobot {
+	if in == nil {
+		return nil
+	}
+	out := new(PullRobot)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PullSecretBinding) DeepCopyInto(out *PullSecretBinding) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -227,6 +627,21 @@ func (in *PullSecretBindingList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PullSecretBindingSpec) DeepCopyInto(out *PullSecretBindingSpec) {
 	*out = *in
+	if in.PullProjects != nil {
+		in, out := &in.PullProjects, &out.PullProjects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RobotCredential != nil {
+		in, out := &in.RobotCredential, &out.RobotCredential
+		*out = new(RobotCredentialPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Permissions != nil {
+		in, out := &in.Permissions, &out.Permissions
+		*out = make([]RobotPermission, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullSecretBindingSpec.
@@ -245,7 +660,34 @@ func (in *PullSecretBindingStatus) DeepCopyInto(out *PullSecretBindingStatus) {
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]Condition, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PullRobots != nil {
+		in, out := &in.PullRobots, &out.PullRobots
+		*out = make([]PullRobot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RobotExpiresAt != nil {
+		in, out := &in.RobotExpiresAt, &out.RobotExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RetiredRobots != nil {
+		in, out := &in.RetiredRobots, &out.RetiredRobots
+		*out = make([]RetiredRobot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Repairs != nil {
+		in, out := &in.Repairs, &out.Repairs
+		*out = make([]BindingRepair, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
@@ -258,3 +700,104 @@ func (in *PullSecretBindingStatus) DeepCopy() *PullSecretBindingStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryCredential) DeepCopyInto(out *RegistryCredential) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryCredential.
+func (in *RegistryCredential) DeepCopy() *RegistryCredential {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryCredential)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetiredRobot) DeepCopyInto(out *RetiredRobot) {
+	*out = *in
+	in.RevokeAfter.DeepCopyInto(&out.RevokeAfter)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetiredRobot.
+func (in *RetiredRobot) DeepCopy() *RetiredRobot {
+	if in == nil {
+		return nil
+	}
+	out := new(RetiredRobot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RobotCredentialPolicy) DeepCopyInto(out *RobotCredentialPolicy) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RotationWindow != nil {
+		in, out := &in.RotationWindow, &out.RotationWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RobotCredentialPolicy.
+func (in *RobotCredentialPolicy) DeepCopy() *RobotCredentialPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RobotCredentialPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerInfo) DeepCopyInto(out *ServerInfo) {
+	*out = *in
+	if in.ReadOnly != nil {
+		in, out := &in.ReadOnly, &out.ReadOnly
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Capabilities != nil {
+		in, out := &in.Capabilities, &out.Capabilities
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerInfo.
+func (in *ServerInfo) DeepCopy() *ServerInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerInfo)
+	in.DeepCopyInto(out)
+	return out
+}
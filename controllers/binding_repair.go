@@ -0,0 +1,315 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxBindingRepairs is the max number of the repairs kept in the status of the binding
+	maxBindingRepairs = 10
+
+	driftProjectNotFound = "ProjectNotFound"
+	driftRobotNotFound   = "RobotNotFound"
+	driftRobotDisabled   = "RobotDisabled"
+	driftSecretNotFound  = "SecretNotFound"
+)
+
+// detectDrift checks the project, robot account and pull secret of the bound binding still work,
+// it returns the reason of the drift or empty if the binding is fine. The robot account read from harbor is returned
+// if the binding is fine, so it is not read again in the same cycle.
+// The name of the bound project is recorded on the binding, so the project can be recreated with the name once it is deleted.
+func (r *PullSecretBindingReconciler) detectDrift(ctx context.Context, harbor *harborClient.Clients, bd *goharborv1alpha1.PullSecretBinding) (string, *model.Robot, error) {
+	projID := parseIntID(bd.Spec.ProjectID)
+
+	projects, err := harbor.Projects()
+	if err != nil {
+		return "", nil, err
+	}
+
+	p, err := projects.GetProjectByID(projID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return driftProjectNotFound, nil, nil
+		}
+
+		return "", nil, err
+	}
+
+	if bd.Annotations[utils.AnnotationBoundProject] != p.Name {
+		setAnnotation(bd, utils.AnnotationBoundProject, p.Name)
+		if err := r.update(ctx, bd); err != nil {
+			return "", nil, fmt.Errorf("record bound project of binding error: %w", err)
+		}
+	}
+
+	robot, err := getBoundRobot(harbor, bd)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return driftRobotNotFound, nil, nil
+		}
+
+		return "", nil, err
+	}
+
+	if robot.Disabled {
+		return driftRobotDisabled, nil, nil
+	}
+
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		if apierr.IsNotFound(err) {
+			return driftSecretNotFound, nil, nil
+		}
+
+		return "", nil, fmt.Errorf("get registry secret error: %w", err)
+	}
+
+	return "", robot, nil
+}
+
+// getBoundRobot reads the robot account of the binding from harbor, with Harbor v2.2 or later its access is read in the same request
+func getBoundRobot(harbor *harborClient.Clients, bd *goharborv1alpha1.PullSecretBinding) (*model.Robot, error) {
+	robots, err := harbor.Robots()
+	if err != nil {
+		return nil, err
+	}
+
+	if harbor.HasCapability(model.CapabilitySystemRobot) {
+		return robots.GetRobotAccountWithAccess(parseIntID(bd.Spec.RobotID))
+	}
+
+	return robots.GetRobotAccount(parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID))
+}
+
+// repairBinding recreates the project of the binding if it was deleted, replaces the robot account with a new one,
+// regenerates the pull secret with its credential and binds the secret to the service account again
+func (r *PullSecretBindingReconciler) repairBinding(ctx context.Context, log logr.Logger, harbor *harborClient.Clients, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding, sa *corev1.ServiceAccount, reason string) error {
+	oldProjID, oldRobotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID)
+	projID := oldProjID
+
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: bd.Namespace}, ns); err != nil {
+		return fmt.Errorf("get namespace error: %w", err)
+	}
+
+	if reason == driftProjectNotFound {
+		proj := boundProject(bd)
+		if proj == "" {
+			return fmt.Errorf("project %d does not exist and the project name of binding %s is unknown", oldProjID, bd.Name)
+		}
+
+		settings, err := projectSettings(hsc, ns)
+		if err != nil {
+			return err
+		}
+
+		projects, err := harbor.Projects()
+		if err != nil {
+			return err
+		}
+
+		if projID, err = projects.EnsureProject(proj, settings); err != nil {
+			return fmt.Errorf("recreate project %s error: %w", proj, err)
+		}
+	}
+
+	robots, err := harbor.Robots()
+	if err != nil {
+		return err
+	}
+
+	robot, err := robots.CreateRobotAccount(projID, model.RobotAccess(bd.Spec.GetPermissions()), robotCredentialPolicy(hsc, bd).GetTTL())
+	if err != nil {
+		return fmt.Errorf("create robot account error: %w", err)
+	}
+
+	regsec, recreated, err := r.regenerateRegSec(ctx, hsc, bd, robot)
+	if err != nil {
+		if err := robots.DeleteRobotAccount(projID, robot.ID); err != nil {
+			log.Error(err, "failed to delete the unused robot account", "projectID", projID, "robotID", robot.ID)
+		}
+
+		return err
+	}
+
+	// The recreated pull secret has a new name, the one of the deleted secret is dropped
+	if err := r.bindRegSec(ctx, sa, regsec.Name, bd.Annotations[utils.AnnotationRobotSecretRef]); err != nil {
+		return err
+	}
+
+	bd.Spec.ProjectID = strconv.FormatInt(projID, 10)
+	bd.Spec.RobotID = strconv.FormatInt(robot.ID, 10)
+	recordRobotPermissions(bd)
+	setAnnotation(bd, utils.AnnotationRobotSecretRef, regsec.Name)
+	if err := r.update(ctx, bd); err != nil {
+		return fmt.Errorf("update binding error: %w", err)
+	}
+
+	// The credentials of the additional projects are lost with the deleted secret, their robot accounts are created again
+	if recreated {
+		if err := revokePullRobots(robots, bd); err != nil {
+			log.Error(err, "failed to delete the pull robot accounts")
+		}
+		bd.Status.PullRobots = nil
+	}
+
+	// The robot account of the deleted project was deleted with it
+	if reason != driftProjectNotFound && reason != driftRobotNotFound {
+		r.deleteReplacedRobot(ctx, log, robots, bd, oldProjID, oldRobotID)
+	}
+
+	// The deprecated robot annotation of the namespace keeps referring a live robot account, so it is revoked with the project
+	r.replaceNamespaceRobot(ctx, log, ns, bd, oldRobotID)
+
+	r.recordRepair(ctx, bd, reason, fmt.Sprintf("robot account %d of project %d is replaced by robot account %d of project %d, pull secret %s is regenerated",
+		oldRobotID, oldProjID, robot.ID, projID, regsec.Name))
+
+	return nil
+}
+
+// boundProject returns the name of the project the binding is bound to,
+// the project declared on the binding takes precedence over the one recorded by the operator
+func boundProject(bd *goharborv1alpha1.PullSecretBinding) string {
+	if proj := bd.Annotations[utils.AnnotationProject]; proj != "" {
+		return proj
+	}
+
+	return bd.Annotations[utils.AnnotationBoundProject]
+}
+
+// deleteReplacedRobot deletes the robot account replaced by the repair unless the other bindings of the namespace still use it,
+// the shared robot account is replaced when they are repaired or rotated
+func (r *PullSecretBindingReconciler) deleteReplacedRobot(ctx context.Context, log logr.Logger, robots rest.RobotClient, bd *goharborv1alpha1.PullSecretBinding, projID, robotID int64) {
+	bindings := &goharborv1alpha1.PullSecretBindingList{}
+	if err := r.Client.List(ctx, bindings, client.InNamespace(bd.Namespace)); err != nil {
+		log.Error(err, "failed to list bindings, keep the replaced robot account", "projectID", projID, "robotID", robotID)
+		return
+	}
+
+	if robotInUse(bindings, bd, robotID) {
+		log.Info("replaced robot account is still used by other bindings", "robotID", robotID)
+		return
+	}
+
+	if err := robots.DeleteRobotAccount(projID, robotID); err != nil {
+		log.Error(err, "failed to delete the replaced robot account", "projectID", projID, "robotID", robotID)
+	}
+}
+
+// regenerateRegSec replaces the credential of the robot account in the pull secret, the credentials of the additional projects are kept.
+// The pull secret is created again if it was deleted, it returns true in this case.
+func (r *PullSecretBindingReconciler) regenerateRegSec(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding, robot *model.Robot) (*corev1.Secret, bool, error) {
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		if !apierr.IsNotFound(err) {
+			return nil, false, fmt.Errorf("get registry secret error: %w", err)
+		}
+
+		regsec, err = r.createRegSec(ctx, bd.Namespace, hsc.Spec.ServerURLs(), robot, bd)
+		if err != nil {
+			return nil, false, fmt.Errorf("create registry secret error: %w", err)
+		}
+
+		return regsec, true, nil
+	}
+
+	auths, err := secret.Decode(regsec.Data[datakey])
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, registry := range hsc.Spec.ServerURLs() {
+		auths.Auths[registry] = &secret.Auth{
+			Username: robot.Name,
+			Password: robot.Token,
+			Email:    fmt.Sprintf("%s@goharbor.io", robot.Name),
+		}
+	}
+
+	regsec.Data[datakey] = auths.Encode()
+	if err := r.Client.Update(ctx, regsec, &client.UpdateOptions{}); err != nil {
+		return nil, false, fmt.Errorf("update registry secret error: %w", err)
+	}
+
+	return regsec, false, nil
+}
+
+// bindRegSec adds the pull secret to the image pull secrets of the service account if it is not there,
+// the previous pull secret of the binding replaced by it is removed from the image pull secrets
+func (r *PullSecretBindingReconciler) bindRegSec(ctx context.Context, sa *corev1.ServiceAccount, name, previous string) error {
+	changed := len(previous) > 0 && previous != name && removePullSecretRef(sa, previous)
+
+	bound := false
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name == name {
+			bound = true
+			break
+		}
+	}
+
+	if !bound {
+		sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := r.Client.Update(ctx, sa, &client.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update service account error: %w", err)
+	}
+
+	return nil
+}
+
+// recordRepair appends the repair to the status of the binding and records it in an event
+func (r *PullSecretBindingReconciler) recordRepair(ctx context.Context, bd *goharborv1alpha1.PullSecretBinding, reason, message string) {
+	bd.Status.Repairs = append(bd.Status.Repairs, goharborv1alpha1.BindingRepair{
+		Time:    metav1.Now(),
+		Reason:  reason,
+		Message: message,
+	})
+	if len(bd.Status.Repairs) > maxBindingRepairs {
+		bd.Status.Repairs = bd.Status.Repairs[len(bd.Status.Repairs)-maxBindingRepairs:]
+	}
+
+	if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+		r.Log.Error(err, "update repairs of binding error", "binding", bd.Name, "namespace", bd.Namespace)
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Event(bd, corev1.EventTypeWarning, reason, message)
+	}
+}
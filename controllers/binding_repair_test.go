@@ -0,0 +1,403 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// driftedHarbor is a fake harbor server holding project 12 with robot account 3, the project or robot account may be missing
+type driftedHarbor struct {
+	// project is the name of project 12, empty if the project is deleted
+	project string
+	// robot is the state of robot account 3: ok, missing or disabled
+	robot string
+	// calls are the changes made to the harbor server
+	calls []string
+}
+
+func (h *driftedHarbor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var body interface{}
+	switch {
+	case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/12":
+		if h.project == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body = map[string]interface{}{"project_id": 12, "name": h.project}
+	case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects":
+		// The deleted project is not found by name either
+		body = []interface{}{}
+	case req.Method == http.MethodPost && req.URL.Path == "/api/v2.0/projects":
+		p := map[string]interface{}{}
+		_ = json.NewDecoder(req.Body).Decode(&p)
+		h.calls = append(h.calls, fmt.Sprintf("%s %s %s", req.Method, req.URL.Path, p["project_name"]))
+		w.Header().Set("Location", "/api/v2.0/projects/15")
+		w.WriteHeader(http.StatusCreated)
+		return
+	case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/12/robots/3":
+		if h.robot == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body = map[string]interface{}{"id": 3, "name": "robot$team", "disabled": h.robot == "disabled"}
+	case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/robots/3":
+		// The robot account and its access are read at once from Harbor v2.2 or later
+		if h.robot == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body = map[string]interface{}{"id": 3, "name": "robot$team", "disable": h.robot == "disabled", "permissions": []interface{}{}}
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/robots"):
+		h.calls = append(h.calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", req.URL.Path+"/35")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": "robot$repaired", "token": "repaired-secret"})
+		return
+	default:
+		h.calls = append(h.calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", "1")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestDetectDrift(t *testing.T) {
+	type testcase struct {
+		description string
+		project     string
+		robot       string
+		noSecret    bool
+		expected    string
+	}
+	tests := []testcase{
+		{
+			description: "bound binding is fine",
+			project:     "team",
+			robot:       "ok",
+		},
+		{
+			description: "project is deleted",
+			robot:       "ok",
+			expected:    driftProjectNotFound,
+		},
+		{
+			description: "robot account is deleted",
+			project:     "team",
+			robot:       "missing",
+			expected:    driftRobotNotFound,
+		},
+		{
+			description: "robot account is disabled",
+			project:     "team",
+			robot:       "disabled",
+			expected:    driftRobotDisabled,
+		},
+		{
+			description: "pull secret is deleted",
+			project:     "team",
+			robot:       "ok",
+			noSecret:    true,
+			expected:    driftSecretNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			h := &driftedHarbor{project: tc.project, robot: tc.robot}
+			harbor, server := newTestClients(t, h)
+			defer server.Close()
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret-team"}
+			objects := []runtime.Object{&bd}
+			if !tc.noSecret {
+				objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "regsecret-team"}})
+			}
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), objects...)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+
+			reason, robot, err := r.detectDrift(context.Background(), harbor, &bd)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, reason)
+			require.Empty(t, h.calls)
+			// The robot account of the fine binding is passed on, so it is not read again in the cycle
+			require.Equal(t, tc.expected == "", robot != nil)
+
+			// The name of the existing project is recorded for the repair
+			got := &goharborv1alpha1.PullSecretBinding{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: bd.Name}, got))
+			require.Equal(t, tc.project, got.Annotations[utils.AnnotationBoundProject])
+		})
+	}
+}
+
+func TestRepairBinding(t *testing.T) {
+	type testcase struct {
+		description string
+		reason      string
+		annotations map[string]string
+		noSecret    bool
+		calls       []string
+		projectID   string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "deleted project is recreated with the name of the bound project",
+			reason:      driftProjectNotFound,
+			annotations: map[string]string{utils.AnnotationBoundProject: "team-prod"},
+			calls:       []string{"POST /api/v2.0/projects team-prod", "POST /api/v2.0/projects/15/robots"},
+			projectID:   "15",
+		},
+		{
+			description: "deleted project is recreated with the name declared on the binding",
+			reason:      driftProjectNotFound,
+			annotations: map[string]string{utils.AnnotationProject: "declared", utils.AnnotationBoundProject: "team-prod"},
+			calls:       []string{"POST /api/v2.0/projects declared", "POST /api/v2.0/projects/15/robots"},
+			projectID:   "15",
+		},
+		{
+			description: "deleted project with unknown name is not recreated",
+			reason:      driftProjectNotFound,
+			calls:       []string{},
+			projectID:   "12",
+			expectedErr: true,
+		},
+		{
+			description: "disabled robot account is replaced",
+			reason:      driftRobotDisabled,
+			calls:       []string{"POST /api/v2.0/projects/12/robots", "DELETE /api/v2.0/projects/12/robots/3"},
+			projectID:   "12",
+		},
+		{
+			description: "deleted pull secret is recreated",
+			reason:      driftSecretNotFound,
+			noSecret:    true,
+			calls:       []string{"POST /api/v2.0/projects/12/robots", "DELETE /api/v2.0/projects/12/robots/3"},
+			projectID:   "12",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			h := &driftedHarbor{calls: []string{}}
+			harbor, server := newTestClients(t, h)
+			defer server.Close()
+
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "team",
+				Annotations: map[string]string{utils.AnnotationProject: "team", utils.AnnotationRobot: "3"},
+			}}
+			sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "default"}}
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret-team"}
+			for k, v := range tc.annotations {
+				bd.Annotations[k] = v
+			}
+			objects := []runtime.Object{ns, sa, &bd}
+			if !tc.noSecret {
+				auths := &secret.Object{Auths: map[string]*secret.Auth{"harbor.local": {Username: "robot$team", Password: "team-secret"}}}
+				objects = append(objects, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "regsecret-team"},
+					Data:       map[string][]byte{datakey: auths.Encode()},
+				})
+			}
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), objects...)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+			hsc := &goharborv1alpha1.HarborServerConfiguration{}
+			hsc.Spec.ServerURL = "harbor.local"
+
+			err := r.repairBinding(context.Background(), logf.Log, harbor, hsc, &bd, sa, tc.reason)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.calls, h.calls)
+
+			got := &goharborv1alpha1.PullSecretBinding{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: bd.Name}, got))
+			require.Equal(t, tc.projectID, got.Spec.ProjectID)
+			if tc.expectedErr {
+				require.Equal(t, "3", got.Spec.RobotID)
+				require.Empty(t, got.Status.Repairs)
+				return
+			}
+
+			require.Equal(t, "35", got.Spec.RobotID)
+			require.Len(t, got.Status.Repairs, 1)
+			require.Equal(t, tc.reason, got.Status.Repairs[0].Reason)
+
+			// The pull secret carries the credential of the new robot account and is bound to the service account
+			regsec := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: got.Annotations[utils.AnnotationRobotSecretRef]}, regsec))
+			auths, err := secret.Decode(regsec.Data[datakey])
+			require.NoError(t, err)
+			require.Equal(t, "robot$repaired", auths.Auths["harbor.local"].Username)
+
+			gotSA := &corev1.ServiceAccount{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "default"}, gotSA))
+			require.Contains(t, gotSA.ImagePullSecrets, corev1.LocalObjectReference{Name: regsec.Name})
+
+			// The deprecated robot annotation of the namespace refers the new robot account
+			gotNS := &corev1.Namespace{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "team"}, gotNS))
+			require.Equal(t, "35", gotNS.Annotations[utils.AnnotationRobot])
+		})
+	}
+}
+
+func TestRepairBinding_DeletedSecretTwice(t *testing.T) {
+	h := &driftedHarbor{calls: []string{}}
+	harbor, server := newTestClients(t, h)
+	defer server.Close()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "team",
+		Annotations: map[string]string{utils.AnnotationProject: "team", utils.AnnotationRobot: "3"},
+	}}
+	sa := &corev1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Namespace: "team", Name: "default"},
+		ImagePullSecrets: []corev1.LocalObjectReference{{Name: "other"}, {Name: "regsecret-team"}},
+	}
+	bd := binding("team", "binding-default", "default", "harbor", "3")
+	bd.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret-team"}
+	c := fake.NewFakeClientWithScheme(newTestScheme(t), ns, sa, &bd)
+	r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+	hsc := &goharborv1alpha1.HarborServerConfiguration{}
+	hsc.Spec.ServerURL = "harbor.local"
+
+	for i := 0; i < 2; i++ {
+		gotSA := &corev1.ServiceAccount{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "default"}, gotSA))
+		require.NoError(t, r.repairBinding(context.Background(), logf.Log, harbor, hsc, &bd, gotSA, driftSecretNotFound))
+
+		// Delete the regenerated pull secret again
+		regsec := &corev1.Secret{}
+		require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec))
+		require.NoError(t, c.Delete(context.Background(), regsec))
+	}
+
+	// The service account only refers the pull secret of the binding once, the deleted ones are dropped
+	gotSA := &corev1.ServiceAccount{}
+	require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "default"}, gotSA))
+	require.Equal(t, []corev1.LocalObjectReference{{Name: "other"}, {Name: bd.Annotations[utils.AnnotationRobotSecretRef]}}, gotSA.ImagePullSecrets)
+}
+
+func TestRecordRepair(t *testing.T) {
+	type testcase struct {
+		description string
+		repairs     int
+		expected    int
+		oldest      string
+	}
+	tests := []testcase{
+		{
+			description: "repair is appended",
+			repairs:     1,
+			expected:    2,
+			oldest:      "repair 0",
+		},
+		{
+			description: "oldest repair is dropped",
+			repairs:     maxBindingRepairs,
+			expected:    maxBindingRepairs,
+			oldest:      "repair 1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			for i := 0; i < tc.repairs; i++ {
+				bd.Status.Repairs = append(bd.Status.Repairs, goharborv1alpha1.BindingRepair{Reason: driftRobotDisabled, Message: fmt.Sprintf("repair %d", i)})
+			}
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), &bd)
+			recorder := record.NewFakeRecorder(1)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log, Recorder: recorder}
+
+			r.recordRepair(context.Background(), &bd, driftSecretNotFound, "pull secret is regenerated")
+
+			got := &goharborv1alpha1.PullSecretBinding{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: bd.Name}, got))
+			require.Len(t, got.Status.Repairs, tc.expected)
+			require.Equal(t, driftSecretNotFound, got.Status.Repairs[tc.expected-1].Reason)
+			require.Equal(t, tc.oldest, got.Status.Repairs[0].Message)
+			require.Equal(t, "Warning SecretNotFound pull secret is regenerated", <-recorder.Events)
+		})
+	}
+}
+
+func TestDeleteReplacedRobot(t *testing.T) {
+	type testcase struct {
+		description string
+		otherRobot  string
+		calls       []string
+	}
+	tests := []testcase{
+		{
+			description: "robot account used by no other binding is deleted",
+			otherRobot:  "5",
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/3"},
+		},
+		{
+			description: "robot account shared with the other bindings is kept",
+			otherRobot:  "3",
+			calls:       []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			robots, server := newTestHarbor(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+			}))
+			defer server.Close()
+
+			// The repaired binding refers the new robot account already
+			bd := binding("team", "binding-frontend", "frontend", "harbor", "7")
+			other := binding("team", "binding-backend", "backend", "harbor", tc.otherRobot)
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), &bd, &other)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+
+			r.deleteReplacedRobot(context.Background(), logf.Log, robots, &bd, 12, 3)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/kstatus/status"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+)
+
+// stalledError is the error which can not be recovered without the user action, the resource is reported as stalled
+// instead of being retried
+type stalledError struct {
+	reason string
+	err    error
+}
+
+func (e *stalledError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stalledError) Unwrap() error {
+	return e.err
+}
+
+// stalled marks the error can not be recovered without the user action
+func stalled(reason string, err error) error {
+	return &stalledError{reason: reason, err: err}
+}
+
+// stalledReason returns the reason if the error can not be recovered without the user action,
+// the operations not supported by the harbor server are such errors
+func stalledReason(err error) (string, bool) {
+	var se *stalledError
+	if errors.As(err, &se) {
+		return se.reason, true
+	}
+
+	if errors.Is(err, model.ErrNotSupported) {
+		return "NotSupported", true
+	}
+
+	return "", false
+}
+
+// readyConditions returns the standard conditions of a resource which is fully reconciled
+func readyConditions(generation int64, reason, message string) []goharborv1alpha1.Condition {
+	return standardConditions(generation, corev1.ConditionTrue, corev1.ConditionFalse, corev1.ConditionFalse, reason, message)
+}
+
+// reconcilingConditions returns the standard conditions of a resource which is still being reconciled
+func reconcilingConditions(generation int64, reason, message string) []goharborv1alpha1.Condition {
+	return standardConditions(generation, corev1.ConditionFalse, corev1.ConditionTrue, corev1.ConditionFalse, reason, message)
+}
+
+// stalledConditions returns the standard conditions of a resource which can not be reconciled without external changes
+func stalledConditions(generation int64, reason, message string) []goharborv1alpha1.Condition {
+	return standardConditions(generation, corev1.ConditionFalse, corev1.ConditionFalse, corev1.ConditionTrue, reason, message)
+}
+
+func standardConditions(generation int64, ready, reconciling, stalled corev1.ConditionStatus, reason, message string) []goharborv1alpha1.Condition {
+	newCondition := func(condType status.ConditionType, st corev1.ConditionStatus) goharborv1alpha1.Condition {
+		return goharborv1alpha1.Condition{
+			Type:               condType,
+			Status:             st,
+			Reason:             reason,
+			Message:            message,
+			ObservedGeneration: generation,
+		}
+	}
+
+	return []goharborv1alpha1.Condition{
+		newCondition(goharborv1alpha1.ConditionReady, ready),
+		newCondition(goharborv1alpha1.ConditionReconciling, reconciling),
+		newCondition(goharborv1alpha1.ConditionStalled, stalled),
+	}
+}
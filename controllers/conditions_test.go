@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kustomize/kstatus/status"
+)
+
+func TestStalledReason(t *testing.T) {
+	type testcase struct {
+		description string
+		err         error
+		reason      string
+		stalled     bool
+	}
+	tests := []testcase{
+		{
+			description: "transient error is retried",
+			err:         errors.New("connection refused"),
+		},
+		{
+			description: "marked error is stalled",
+			err:         fmt.Errorf("bind error: %w", stalled("InvalidProject", errors.New("bad project"))),
+			reason:      "InvalidProject",
+			stalled:     true,
+		},
+		{
+			description: "operation not supported by harbor is stalled",
+			err:         fmt.Errorf("robot account management is %w", model.ErrNotSupported),
+			reason:      "NotSupported",
+			stalled:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			reason, ok := stalledReason(tc.err)
+			require.Equal(t, tc.stalled, ok)
+			require.Equal(t, tc.reason, reason)
+		})
+	}
+}
+
+func TestSetStandardConditions(t *testing.T) {
+	type testcase struct {
+		description string
+		conditions  []goharborv1alpha1.Condition
+		cerr        error
+		reason      string
+		stalled     bool
+		reconciling bool
+	}
+	tests := []testcase{
+		{
+			description: "healthy server is ready",
+			reason:      "Healthy",
+		},
+		{
+			description: "unreachable server is retried",
+			cerr:        errors.New("connection refused"),
+			reason:      "HealthCheckFailed",
+			reconciling: true,
+		},
+		{
+			description: "unhealthy components are retried",
+			conditions:  []goharborv1alpha1.Condition{{Type: "core", Status: corev1.ConditionFalse}},
+			reason:      "ComponentsUnhealthy",
+			reconciling: true,
+		},
+		{
+			description: "insufficient credentials are stalled",
+			conditions:  []goharborv1alpha1.Condition{{Type: status.ConditionType(credentialsSufficient), Status: corev1.ConditionFalse}},
+			reason:      "CredentialsInsufficient",
+			stalled:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			hsc := &goharborv1alpha1.HarborServerConfiguration{}
+			st := &goharborv1alpha1.HarborServerConfigurationStatus{Conditions: tc.conditions}
+			setStandardConditions(hsc, st, tc.cerr)
+
+			require.Equal(t, tc.reason, goharborv1alpha1.FindCondition(st.Conditions, goharborv1alpha1.ConditionReady).Reason)
+			require.Equal(t, tc.stalled, goharborv1alpha1.IsConditionTrue(st.Conditions, goharborv1alpha1.ConditionStalled))
+			require.Equal(t, tc.reconciling, goharborv1alpha1.IsConditionTrue(st.Conditions, goharborv1alpha1.ConditionReconciling))
+		})
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	type testcase struct {
+		description string
+		user        string
+		permissions string
+		status      corev1.ConditionStatus
+		reason      string
+	}
+	tests := []testcase{
+		{
+			description: "failure of reading the user is unknown",
+			status:      corev1.ConditionUnknown,
+			reason:      "VerificationFailed",
+		},
+		{
+			description: "failure of reading the permissions is unknown",
+			user:        `{"username":"bot"}`,
+			status:      corev1.ConditionUnknown,
+			reason:      "VerificationFailed",
+		},
+		{
+			description: "missing permissions",
+			user:        `{"username":"bot"}`,
+			permissions: `[]`,
+			status:      corev1.ConditionFalse,
+			reason:      "MissingPermissions",
+		},
+		{
+			description: "system admin",
+			user:        `{"username":"admin","sysadmin_flag":true}`,
+			status:      corev1.ConditionTrue,
+			reason:      "SystemAdmin",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			harbor, server := newTestHarbor(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				body := tc.user
+				if strings.HasSuffix(req.URL.Path, "/permissions") {
+					body = tc.permissions
+				}
+
+				if body == "" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			r := &HarborServerConfigurationReconciler{Log: logf.Log}
+			cond := r.checkCredentials(harbor, &goharborv1alpha1.HarborServerConfiguration{}, nil)
+			require.Equal(t, tc.status, cond.Status)
+			require.Equal(t, tc.reason, cond.Reason)
+		})
+	}
+}
@@ -0,0 +1,373 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/kustomize/kstatus/status"
+)
+
+const (
+	// credentialManaged condition reports the state of the robot account managed by the operator
+	credentialManaged = "CredentialManaged"
+	// managedRobotPrefix is the prefix of the name of the robot account managed by the operator
+	managedRobotPrefix = "4k8s-operator"
+)
+
+var (
+	// managedRobotProjectPermissions are the permissions of the robot account managed by the operator on all the projects
+	managedRobotProjectPermissions = []model.Permission{
+		{Resource: "project", Action: "read"},
+		{Resource: "project", Action: "update"},
+		{Resource: "project", Action: "delete"},
+		{Resource: "member", Action: "create"},
+		{Resource: "member", Action: "read"},
+		{Resource: "member", Action: "list"},
+		{Resource: "member", Action: "update"},
+		{Resource: "member", Action: "delete"},
+		{Resource: "label", Action: "create"},
+		{Resource: "label", Action: "read"},
+		{Resource: "label", Action: "list"},
+		{Resource: "immutable-tag", Action: "create"},
+		{Resource: "immutable-tag", Action: "list"},
+		{Resource: "robot", Action: "create"},
+		{Resource: "robot", Action: "read"},
+		{Resource: "robot", Action: "list"},
+		{Resource: "robot", Action: "delete"},
+		{Resource: "repository", Action: "pull"},
+	}
+
+	// persistSecretBackoff is the backoff of the retries keeping the rotated robot secret in the managed secret
+	persistSecretBackoff = wait.Backoff{
+		Steps:    5,
+		Duration: 100 * time.Millisecond,
+		Factor:   2.0,
+		Jitter:   0.1,
+	}
+)
+
+// manageCredential creates the robot account managed by the operator with the access credential if its secret does not exist,
+// otherwise the robot secret is rotated once the rotation interval is passed.
+// It returns the condition of the managed credential and the wait until the next rotation.
+func (r *HarborServerConfigurationReconciler) manageCredential(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string, harbor *legacy.Client, st *goharborv1alpha1.HarborServerConfigurationStatus) (goharborv1alpha1.Condition, time.Duration) {
+	cond := goharborv1alpha1.Condition{
+		Type:   status.ConditionType(credentialManaged),
+		Status: corev1.ConditionFalse,
+	}
+	interval := hsc.Spec.ManagedCredential.GetRotationInterval()
+	ref := harborClient.ManagedCredentialRef(hsc)
+
+	if !st.ServerInfo.HasCapability(model.CapabilitySystemRobot) {
+		cond.Reason = "NotSupported"
+		cond.Message = fmt.Sprintf("system level robot accounts are not supported by the harbor server, capability %s is required", model.CapabilitySystemRobot)
+		return cond, 0
+	}
+
+	sec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, ref, sec); err != nil {
+		if !apierr.IsNotFound(err) {
+			cond.Reason = "SecretError"
+			cond.Message = fmt.Sprintf("get secret %s error: %s", ref, err)
+			return cond, 0
+		}
+
+		robot, err := r.bootstrapCredential(ctx, hsc, serverURL)
+		if err != nil {
+			log.Error(err, "failed to bootstrap managed credential")
+			cond.Reason = "BootstrapFailed"
+			cond.Message = err.Error()
+			return cond, 0
+		}
+
+		log.Info("managed robot account is created", "robot", robot.RobotName, "secret", ref)
+		st.ManagedCredential = robot
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "Created"
+		cond.Message = fmt.Sprintf("robot account %s is created", robot.RobotName)
+		return cond, interval
+	}
+
+	cred := &model.AccessCred{Type: goharborv1alpha1.AccessCredentialTypeRobot}
+	robotID, err := strconv.ParseInt(sec.Annotations[utils.AnnotationRobot], 10, 64)
+	if err == nil {
+		err = cred.FillIn(sec)
+	}
+	if err != nil {
+		cond.Reason = "InvalidSecret"
+		cond.Message = fmt.Sprintf("secret %s is not a robot account created by the operator, delete it to create the robot account again", ref)
+		return cond, 0
+	}
+
+	robot := &goharborv1alpha1.ManagedCredentialStatus{
+		RobotID:   robotID,
+		RobotName: cred.AccessKey,
+	}
+	if last := hsc.Status.ManagedCredential; last != nil && last.RobotID == robotID {
+		robot.PermissionsHash = last.PermissionsHash
+	}
+	if rotatedAt, err := time.Parse(time.RFC3339, sec.Annotations[utils.AnnotationRotatedAt]); err == nil {
+		robot.LastRotationTime = metav1.NewTime(rotatedAt)
+	}
+	st.ManagedCredential = robot
+
+	cond, wait := r.rotateCredential(ctx, log, sec, robot, interval, harbor)
+
+	// The permissions required by the configuration may be changed since the robot account was granted.
+	// A failure does not block the rotation, it is retried in the next cycle.
+	if hash := managedPermissionsHash(hsc); robot.PermissionsHash != hash {
+		if err := r.syncCredentialPermissions(ctx, log, hsc, serverURL, robot); err != nil {
+			if cond.Status == corev1.ConditionTrue {
+				log.Error(err, "failed to sync permissions of managed credential", "robot", robot.RobotName)
+				cond.Status = corev1.ConditionFalse
+				cond.Reason = "PermissionSyncFailed"
+				cond.Message = fmt.Sprintf("grant the permissions required by the configuration to robot account %s with the access credential error, check the access credential", robot.RobotName)
+			}
+		} else {
+			robot.PermissionsHash = hash
+		}
+	}
+
+	return cond, wait
+}
+
+// rotateCredential rotates the secret of the managed robot account once the rotation interval is passed,
+// it returns the condition of the managed credential and the wait until the next rotation
+func (r *HarborServerConfigurationReconciler) rotateCredential(ctx context.Context, log logr.Logger, sec *corev1.Secret, robot *goharborv1alpha1.ManagedCredentialStatus, interval time.Duration, harbor *legacy.Client) (goharborv1alpha1.Condition, time.Duration) {
+	cond := goharborv1alpha1.Condition{
+		Type:   status.ConditionType(credentialManaged),
+		Status: corev1.ConditionFalse,
+	}
+	ref := types.NamespacedName{Namespace: sec.Namespace, Name: sec.Name}
+
+	if wait := time.Until(robot.LastRotationTime.Add(interval)); wait > 0 {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "UpToDate"
+		cond.Message = fmt.Sprintf("robot account %s will be rotated at %s", robot.RobotName, robot.LastRotationTime.Add(interval).Format(time.RFC3339))
+		return cond, wait
+	}
+
+	// The robot account rotates its own secret
+	if harbor.CredentialType() != goharborv1alpha1.AccessCredentialTypeRobot {
+		cond.Reason = "RotationPending"
+		cond.Message = fmt.Sprintf("robot account %s is not in use yet", robot.RobotName)
+		return cond, 0
+	}
+
+	secret, err := harbor.RefreshSystemRobotAccountSecret(robot.RobotID)
+	if err != nil {
+		log.Error(err, "failed to rotate managed credential", "robot", robot.RobotName)
+		cond.Reason = "RotationFailed"
+		cond.Message = fmt.Sprintf("rotate robot account %s error: %s, delete secret %s to create the robot account again", robot.RobotName, err, ref)
+		return cond, 0
+	}
+
+	// The old robot secret is invalid once rotated, the new one must not be lost
+	now := metav1.Now()
+	if err := r.persistRotatedSecret(ctx, sec, robot.RobotName, secret, now); err != nil {
+		log.Error(err, "failed to update managed credential secret", "secret", ref)
+		cond.Reason = "SecretError"
+		cond.Message = fmt.Sprintf("update secret %s with the rotated robot secret error: %s, delete it to create the robot account again", ref, err)
+		return cond, 0
+	}
+
+	log.Info("managed robot account is rotated", "robot", robot.RobotName)
+	robot.LastRotationTime = now
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = "Rotated"
+	cond.Message = fmt.Sprintf("robot account %s is rotated", robot.RobotName)
+
+	return cond, interval
+}
+
+// syncCredentialPermissions grants the managed robot account the permissions required by the configuration with the access credential,
+// the robot account can not update its own permissions. It is only called when the required permissions are changed.
+func (r *HarborServerConfigurationReconciler) syncCredentialPermissions(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string, robot *goharborv1alpha1.ManagedCredentialStatus) error {
+	harbor, err := harborClient.CreateBootstrapHarborLegacyClient(ctx, r.Client, hsc, serverURL)
+	if err != nil {
+		return fmt.Errorf("create harbor client with access credential error: %w", err)
+	}
+
+	updated, err := harbor.EnsureSystemRobotAccountPermissions(robot.RobotID, requiredPermissions(hsc), managedRobotProjectPermissions)
+	if err != nil {
+		return err
+	}
+
+	if updated {
+		log.Info("permissions of managed robot account are updated", "robot", robot.RobotName)
+	}
+
+	return nil
+}
+
+// managedPermissionsHash returns the hash of the permissions the managed robot account is required to be granted
+func managedPermissionsHash(hsc *goharborv1alpha1.HarborServerConfiguration) string {
+	keys := func(kind string, permissions []model.Permission) []string {
+		k := make([]string, 0, len(permissions))
+		for _, p := range permissions {
+			k = append(k, fmt.Sprintf("%s:%s:%s", kind, p.Resource, p.Action))
+		}
+		return k
+	}
+
+	all := append(keys("system", requiredPermissions(hsc)), keys("project", managedRobotProjectPermissions)...)
+	sort.Strings(all)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(all))))
+}
+
+// persistRotatedSecret keeps the rotated robot secret in the managed secret. The update is retried on any error,
+// the secret is read again before each retry and created again if it was deleted.
+func (r *HarborServerConfigurationReconciler) persistRotatedSecret(ctx context.Context, sec *corev1.Secret, robotName, robotSecret string, rotatedAt metav1.Time) error {
+	ref := types.NamespacedName{Namespace: sec.Namespace, Name: sec.Name}
+	current, create := sec.DeepCopy(), false
+
+	return retry.OnError(persistSecretBackoff, func(error) bool { return true }, func() error {
+		if current == nil {
+			current = &corev1.Secret{}
+			if err := r.Client.Get(ctx, ref, current); err != nil {
+				if !apierr.IsNotFound(err) {
+					current = nil
+					return err
+				}
+
+				current.ObjectMeta = metav1.ObjectMeta{
+					Name:        ref.Name,
+					Namespace:   ref.Namespace,
+					Annotations: sec.DeepCopy().Annotations,
+				}
+				current.Type = corev1.SecretTypeOpaque
+				create = true
+			}
+		}
+
+		if current.Annotations == nil {
+			current.Annotations = make(map[string]string)
+		}
+		current.Annotations[utils.AnnotationRotatedAt] = rotatedAt.UTC().Format(time.RFC3339)
+		current.Data = model.RobotSecretData(robotName, robotSecret)
+
+		var err error
+		if create {
+			err = r.Client.Create(ctx, current)
+		} else {
+			err = r.Client.Update(ctx, current)
+		}
+		// Read it again before the retry
+		current, create = nil, false
+
+		return err
+	})
+}
+
+// bootstrapCredential creates the robot account with the access credential and keeps it in the managed secret.
+// The robot account replaced by the new one is deleted.
+func (r *HarborServerConfigurationReconciler) bootstrapCredential(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string) (*goharborv1alpha1.ManagedCredentialStatus, error) {
+	harbor, err := harborClient.CreateBootstrapHarborLegacyClient(ctx, r.Client, hsc, serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("create harbor client with access credential error: %w", err)
+	}
+
+	robot, err := harbor.CreateSystemRobotAccount(utils.RandomName(managedRobotPrefix), fmt.Sprintf("managed by harbor-automation-4k8s for %s", hsc.Key()), requiredPermissions(hsc), managedRobotProjectPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("create robot account error: %w", err)
+	}
+
+	now := metav1.Now()
+	ref := harborClient.ManagedCredentialRef(hsc)
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+			Annotations: map[string]string{
+				utils.AnnotationRobot:     strconv.FormatInt(robot.ID, 10),
+				utils.AnnotationSecOwner:  defaultOwner,
+				utils.AnnotationRotatedAt: now.UTC().Format(time.RFC3339),
+			},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: model.RobotSecretData(robot.Name, robot.Token),
+	}
+	if err := r.Client.Create(ctx, sec); err != nil {
+		// Do not leave the robot account nobody knows the secret of
+		if derr := harbor.DeleteSystemRobotAccount(robot.ID); derr != nil {
+			r.Log.Error(derr, "failed to delete robot account", "robot", robot.Name)
+		}
+
+		return nil, fmt.Errorf("create secret %s error: %w", ref, err)
+	}
+
+	if last := hsc.Status.ManagedCredential; last != nil && last.RobotID != robot.ID {
+		if err := harbor.DeleteSystemRobotAccount(last.RobotID); err != nil {
+			r.Log.Error(err, "failed to delete replaced robot account", "robot", last.RobotName)
+		}
+	}
+
+	return &goharborv1alpha1.ManagedCredentialStatus{
+		RobotID:          robot.ID,
+		RobotName:        robot.Name,
+		LastRotationTime: now,
+		PermissionsHash:  managedPermissionsHash(hsc),
+	}, nil
+}
+
+// releaseCredential deletes the robot account managed by the operator and its secret, the errors are only logged
+func (r *HarborServerConfigurationReconciler) releaseCredential(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration) {
+	if hsc.Spec.ManagedCredential == nil {
+		return
+	}
+
+	ref := harborClient.ManagedCredentialRef(hsc)
+	sec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, ref, sec); err != nil {
+		if !apierr.IsNotFound(err) {
+			log.Error(err, "failed to get managed credential secret", "secret", ref)
+		}
+		return
+	}
+
+	if robotID, err := strconv.ParseInt(sec.Annotations[utils.AnnotationRobot], 10, 64); err == nil {
+		harbor, err := harborClient.CreateBootstrapHarborLegacyClient(ctx, r.Client, hsc, hsc.ActiveServerURL())
+		if err == nil {
+			err = harbor.DeleteSystemRobotAccount(robotID)
+		}
+		if err != nil {
+			log.Error(err, "failed to delete managed robot account", "robot", robotID)
+		}
+	}
+
+	if err := r.Client.Delete(ctx, sec); err != nil && !apierr.IsNotFound(err) {
+		log.Error(err, "failed to delete managed credential secret", "secret", ref)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestManageCredential(t *testing.T) {
+	hsc := &goharborv1alpha1.HarborServerConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "harbor"},
+		Spec: goharborv1alpha1.HarborServerConfigurationSpec{
+			InSecure: true,
+			AccessCredential: &goharborv1alpha1.AccessCredential{
+				Namespace:       "kube-system",
+				AccessSecretRef: "harbor-admin",
+				Type:            goharborv1alpha1.AccessCredentialTypeBasic,
+			},
+			ManagedCredential: &goharborv1alpha1.ManagedCredential{
+				SecretRef:        "harbor-robot",
+				RotationInterval: &metav1.Duration{Duration: time.Hour},
+			},
+		},
+	}
+	accessSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "harbor-admin", Namespace: "kube-system"},
+		Data:       map[string][]byte{"accessKey": []byte("admin"), "accessSecret": []byte("Harbor12345")},
+	}
+	granted := robotPermissionsJSON(t, requiredPermissions(hsc), managedRobotProjectPermissions)
+	outdated := robotPermissionsJSON(t, requiredPermissions(hsc), managedRobotProjectPermissions[:3])
+	ref := harborClient.ManagedCredentialRef(hsc)
+	managedSecret := func(robotID string, rotatedAt time.Time) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ref.Name,
+				Namespace: ref.Namespace,
+				Annotations: map[string]string{
+					utils.AnnotationRobot:     robotID,
+					utils.AnnotationRotatedAt: rotatedAt.UTC().Format(time.RFC3339),
+				},
+			},
+			Data: model.RobotSecretData("robot$4k8s-operator", "old"),
+		}
+	}
+	robotCred := &model.AccessCred{Type: goharborv1alpha1.AccessCredentialTypeRobot, AccessKey: "robot$4k8s-operator", AccessSecret: "old"}
+	basicCred := &model.AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"}
+
+	type testcase struct {
+		description  string
+		capabilities []string
+		secret       *corev1.Secret
+		cred         *model.AccessCred
+		refresh      int
+		synced       bool
+		permissions  string
+		get          int
+		updated      bool
+		status       corev1.ConditionStatus
+		reason       string
+		robotSecret  string
+	}
+	tests := []testcase{
+		{
+			description:  "system robot is not supported",
+			capabilities: []string{model.CapabilityProjectAPI, model.CapabilityProjectRobot},
+			secret:       managedSecret("7", time.Now().Add(-2*time.Hour)),
+			cred:         robotCred,
+			status:       corev1.ConditionFalse,
+			reason:       "NotSupported",
+			robotSecret:  "old",
+		},
+		{
+			description: "secret is not created by the operator",
+			secret:      managedSecret("", time.Now()),
+			cred:        robotCred,
+			status:      corev1.ConditionFalse,
+			reason:      "InvalidSecret",
+			robotSecret: "old",
+		},
+		{
+			description: "robot secret is up to date",
+			secret:      managedSecret("7", time.Now()),
+			cred:        robotCred,
+			permissions: granted,
+			get:         http.StatusOK,
+			status:      corev1.ConditionTrue,
+			reason:      "UpToDate",
+			robotSecret: "old",
+		},
+		{
+			description: "synced permissions are not read again",
+			secret:      managedSecret("7", time.Now()),
+			cred:        robotCred,
+			synced:      true,
+			status:      corev1.ConditionTrue,
+			reason:      "UpToDate",
+			robotSecret: "old",
+		},
+		{
+			description: "synced robot secret is rotated",
+			secret:      managedSecret("7", time.Now().Add(-2*time.Hour)),
+			cred:        robotCred,
+			refresh:     http.StatusOK,
+			synced:      true,
+			status:      corev1.ConditionTrue,
+			reason:      "Rotated",
+			robotSecret: "new",
+		},
+		{
+			description: "outdated permissions are updated",
+			secret:      managedSecret("7", time.Now()),
+			cred:        robotCred,
+			permissions: outdated,
+			get:         http.StatusOK,
+			updated:     true,
+			status:      corev1.ConditionTrue,
+			reason:      "UpToDate",
+			robotSecret: "old",
+		},
+		{
+			description: "failed permission sync is reported",
+			secret:      managedSecret("7", time.Now()),
+			cred:        robotCred,
+			get:         http.StatusInternalServerError,
+			status:      corev1.ConditionFalse,
+			reason:      "PermissionSyncFailed",
+			robotSecret: "old",
+		},
+		{
+			description: "robot account is not in use",
+			secret:      managedSecret("7", time.Now().Add(-2*time.Hour)),
+			cred:        basicCred,
+			permissions: granted,
+			get:         http.StatusOK,
+			status:      corev1.ConditionFalse,
+			reason:      "RotationPending",
+			robotSecret: "old",
+		},
+		{
+			description: "robot secret is rotated",
+			secret:      managedSecret("7", time.Now().Add(-2*time.Hour)),
+			cred:        robotCred,
+			refresh:     http.StatusOK,
+			permissions: granted,
+			get:         http.StatusOK,
+			status:      corev1.ConditionTrue,
+			reason:      "Rotated",
+			robotSecret: "new",
+		},
+		{
+			description: "failed rotation keeps the secret",
+			secret:      managedSecret("7", time.Now().Add(-2*time.Hour)),
+			cred:        robotCred,
+			refresh:     http.StatusInternalServerError,
+			permissions: granted,
+			get:         http.StatusOK,
+			status:      corev1.ConditionFalse,
+			reason:      "RotationFailed",
+			robotSecret: "old",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			refreshed, read, updated := 0, 0, 0
+			harbor, server := newTestHarborWithCred(t, tc.cred, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if !strings.HasSuffix(req.URL.Path, "/robots/7") {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				switch req.Method {
+				case http.MethodPatch:
+					refreshed++
+					w.WriteHeader(tc.refresh)
+					_, _ = w.Write([]byte(`{"secret":"new"}`))
+				case http.MethodGet:
+					read++
+					w.WriteHeader(tc.get)
+					_, _ = w.Write([]byte(tc.permissions))
+				case http.MethodPut:
+					updated++
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			hsc := hsc.DeepCopy()
+			if tc.synced {
+				hsc.Status.ManagedCredential = &goharborv1alpha1.ManagedCredentialStatus{RobotID: 7, PermissionsHash: managedPermissionsHash(hsc)}
+			}
+
+			c := fake.NewFakeClient(tc.secret, accessSecret)
+			r := &HarborServerConfigurationReconciler{Client: c, Log: logf.Log}
+			st := &goharborv1alpha1.HarborServerConfigurationStatus{
+				ServerInfo: &goharborv1alpha1.ServerInfo{Capabilities: tc.capabilities},
+			}
+
+			cond, _ := r.manageCredential(context.Background(), logf.Log, hsc, strings.TrimPrefix(server.URL, "https://"), harbor, st)
+			require.Equal(t, tc.status, cond.Status)
+			require.Equal(t, tc.reason, cond.Reason)
+			if tc.refresh == 0 {
+				require.Zero(t, refreshed)
+			}
+			require.Equal(t, tc.updated, updated > 0)
+			// The access credential is only used when the required permissions are changed
+			require.Equal(t, tc.get != 0, read > 0)
+			if st.ManagedCredential != nil && (tc.synced || tc.get == http.StatusOK) {
+				require.Equal(t, managedPermissionsHash(hsc), st.ManagedCredential.PermissionsHash)
+			}
+
+			sec := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), ref, sec))
+			require.Equal(t, model.RobotSecretData("robot$4k8s-operator", tc.robotSecret), sec.Data)
+		})
+	}
+}
+
+// robotPermissionsJSON returns the system level robot account granted the system and project permissions
+func robotPermissionsJSON(t *testing.T, system, project []model.Permission) string {
+	access := func(permissions []model.Permission) []map[string]string {
+		a := make([]map[string]string, 0, len(permissions))
+		for _, p := range permissions {
+			a = append(a, map[string]string{"resource": p.Resource, "action": p.Action})
+		}
+		return a
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":   7,
+		"name": "robot$4k8s-operator",
+		"permissions": []map[string]interface{}{
+			{"kind": "system", "namespace": "/", "access": access(system)},
+			{"kind": "project", "namespace": "*", "access": access(project)},
+		},
+	})
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+func TestPersistRotatedSecret(t *testing.T) {
+	ref := harborClient.ManagedCredentialRef(&goharborv1alpha1.HarborServerConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "harbor"},
+		Spec: goharborv1alpha1.HarborServerConfigurationSpec{
+			AccessCredential:  &goharborv1alpha1.AccessCredential{Namespace: "kube-system"},
+			ManagedCredential: &goharborv1alpha1.ManagedCredential{SecretRef: "harbor-robot"},
+		},
+	})
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ref.Name,
+			Namespace:   ref.Namespace,
+			Annotations: map[string]string{utils.AnnotationRobot: "7"},
+		},
+		Data: model.RobotSecretData("robot$4k8s-operator", "old"),
+	}
+
+	type testcase struct {
+		description string
+		objects     []runtime.Object
+		secret      *corev1.Secret
+	}
+	tests := []testcase{
+		{
+			description: "secret is updated",
+			objects:     []runtime.Object{sec.DeepCopy()},
+			secret:      sec,
+		},
+		{
+			description: "stale secret is read again",
+			objects:     []runtime.Object{sec.DeepCopy()},
+			secret: func() *corev1.Secret {
+				stale := sec.DeepCopy()
+				stale.ResourceVersion = "stale"
+				return stale
+			}(),
+		},
+		{
+			description: "deleted secret is created again",
+			secret: func() *corev1.Secret {
+				deleted := sec.DeepCopy()
+				deleted.ResourceVersion = "1"
+				return deleted
+			}(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			c := fake.NewFakeClient(tc.objects...)
+			r := &HarborServerConfigurationReconciler{Client: c, Log: logf.Log}
+			current := tc.secret.DeepCopy()
+			if len(tc.objects) > 0 && len(current.ResourceVersion) == 0 {
+				require.NoError(t, c.Get(context.Background(), ref, current))
+			}
+
+			rotatedAt := metav1.NewTime(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, r.persistRotatedSecret(context.Background(), current, "robot$4k8s-operator", "new", rotatedAt))
+
+			got := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), ref, got))
+			require.Equal(t, model.RobotSecretData("robot$4k8s-operator", "new"), got.Data)
+			require.Equal(t, "7", got.Annotations[utils.AnnotationRobot])
+			require.Equal(t, "2021-01-01T00:00:00Z", got.Annotations[utils.AnnotationRotatedAt])
+		})
+	}
+}
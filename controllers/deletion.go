@@ -0,0 +1,238 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// hscFinalizer keeps the harbor server configuration until its deletion policy is fulfilled
+	hscFinalizer = "hsc.finalizers.resource.goharbor.io"
+	// deletionCheckCycle is the interval of checking if the blocked deletion can proceed
+	deletionCheckCycle = 30 * time.Second
+)
+
+// serverObject writes the changes of the configuration view back to the harbor server configuration or the harbor server
+type serverObject struct {
+	update       func() error
+	updateStatus func() error
+}
+
+// finalizeServer fulfills the deletion policy of the harbor server configuration being deleted,
+// the finalizer is removed once it is done
+func (r *HarborServerConfigurationReconciler) finalizeServer(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration, obj serverObject) (ctrl.Result, error) {
+	if !utils.ContainsString(hsc.Finalizers, hscFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	switch hsc.Spec.DeletionPolicy {
+	case goharborv1alpha1.DeletionPolicyBlock:
+		refs, err := r.referringResources(ctx, hsc)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("list resources referring %s error: %w", hsc.Key(), err)
+		}
+
+		if len(refs) > 0 {
+			msg := fmt.Sprintf("deletion is blocked by the resources referring it: %s", strings.Join(refs, ", "))
+			log.Info("deletion is blocked", "resources", refs)
+			if err := r.setDeletionConditions(hsc, obj, stalledConditions(hsc.Generation, "DeletionBlocked", msg)); err != nil {
+				return r.requeueWithError(err)
+			}
+
+			// The bindings and namespaces are not watched, check them later
+			return ctrl.Result{RequeueAfter: deletionCheckCycle}, nil
+		}
+	case goharborv1alpha1.DeletionPolicyCascade:
+		cleaned, total, err := r.cascadeDeletion(ctx, log, hsc)
+		if err != nil {
+			msg := fmt.Sprintf("cleaned up %d of %d pull secret bindings: %s", cleaned, total, err)
+			if uerr := r.setDeletionConditions(hsc, obj, reconcilingConditions(hsc.Generation, "Deleting", msg)); uerr != nil {
+				log.Error(uerr, "failed to update status")
+			}
+
+			return requeueIfThrottled(ctrl.Result{}, err)
+		}
+	}
+
+	// The robot account managed by the operator is useless without the configuration
+	r.releaseCredential(ctx, log, hsc)
+
+	hsc.Finalizers = utils.RemoveString(hsc.Finalizers, hscFinalizer)
+	if err := obj.update(); err != nil {
+		return ctrl.Result{}, fmt.Errorf("remove finalizer error: %w", err)
+	}
+
+	log.Info("Harbor server configuration is deleted", "deletionPolicy", hsc.Spec.DeletionPolicy)
+
+	return ctrl.Result{}, nil
+}
+
+func (r *HarborServerConfigurationReconciler) setDeletionConditions(hsc *goharborv1alpha1.HarborServerConfiguration, obj serverObject, conditions []goharborv1alpha1.Condition) error {
+	for _, cond := range conditions {
+		hsc.Status.Conditions = goharborv1alpha1.SetCondition(hsc.Status.Conditions, cond)
+	}
+
+	return obj.updateStatus()
+}
+
+// referringResources lists the pull secret bindings and the namespaces annotated with the harbor server configuration
+func (r *HarborServerConfigurationReconciler) referringResources(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration) ([]string, error) {
+	refs := make([]string, 0)
+
+	psbs, err := psbsReferringHSC(ctx, r.Client, hsc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, psb := range psbs {
+		refs = append(refs, fmt.Sprintf("PullSecretBinding %s/%s", psb.Namespace, psb.Name))
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := r.Client.List(ctx, nsList); err != nil {
+		return nil, err
+	}
+
+	for _, ns := range nsList.Items {
+		// The harbor server only serves its own namespace
+		if len(hsc.Namespace) > 0 && ns.Name != hsc.Namespace {
+			continue
+		}
+
+		if ns.Annotations[utils.AnnotationHarborServer] == hsc.Name {
+			refs = append(refs, fmt.Sprintf("Namespace %s", ns.Name))
+		}
+	}
+
+	return refs, nil
+}
+
+// cascadeDeletion revokes the robot accounts and removes the pull secrets of the bindings referring the harbor server configuration,
+// then the bindings are deleted. It returns the number of the cleaned bindings and the total number.
+func (r *HarborServerConfigurationReconciler) cascadeDeletion(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration) (int, int, error) {
+	psbs, err := psbsReferringHSC(ctx, r.Client, hsc)
+	if err != nil {
+		return 0, 0, fmt.Errorf("list pull secret bindings error: %w", err)
+	}
+
+	if len(psbs) == 0 {
+		return 0, 0, nil
+	}
+
+	harbor, err := harborClient.CreateHarborClients(ctx, r.Client, hsc)
+	if err != nil {
+		return 0, len(psbs), fmt.Errorf("create harbor client error: %w", err)
+	}
+
+	cleaned := 0
+	errs := make([]string, 0)
+	for i := range psbs {
+		bd := &psbs[i]
+		if err := r.cleanupBinding(ctx, harbor, bd); err != nil {
+			log.Error(err, "failed to clean up pull secret binding", "binding", bd.Name, "namespace", bd.Namespace)
+			errs = append(errs, fmt.Sprintf("%s/%s: %s", bd.Namespace, bd.Name, err))
+			continue
+		}
+
+		cleaned++
+	}
+
+	if len(errs) > 0 {
+		return cleaned, len(psbs), fmt.Errorf("clean up pull secret bindings error: %s", strings.Join(errs, "; "))
+	}
+
+	return cleaned, len(psbs), nil
+}
+
+// cleanupBinding revokes the robot account of the binding, removes its pull secret from the service account and deletes the binding
+func (r *HarborServerConfigurationReconciler) cleanupBinding(ctx context.Context, harbor *harborClient.Clients, bd *goharborv1alpha1.PullSecretBinding) error {
+	if projID, robotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID); projID > 0 && robotID > 0 {
+		robots, err := harbor.Robots()
+		if err != nil {
+			return err
+		}
+
+		if err := robots.DeleteRobotAccount(projID, robotID); err != nil {
+			return fmt.Errorf("revoke robot account error: %w", err)
+		}
+	}
+
+	if secretName, ok := bd.Annotations[utils.AnnotationRobotSecretRef]; ok {
+		sa := &corev1.ServiceAccount{}
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: bd.Namespace, Name: bd.Spec.ServiceAccount}, sa); err != nil {
+			if !apierr.IsNotFound(err) {
+				return fmt.Errorf("get service account error: %w", err)
+			}
+		} else if removePullSecretRef(sa, secretName) {
+			if err := r.Client.Update(ctx, sa, &client.UpdateOptions{}); err != nil {
+				return fmt.Errorf("update service account error: %w", err)
+			}
+		}
+
+		regsec := &corev1.Secret{}
+		regsec.Namespace, regsec.Name = bd.Namespace, secretName
+		if err := r.Client.Delete(ctx, regsec); err != nil && !apierr.IsNotFound(err) {
+			return fmt.Errorf("delete pull secret error: %w", err)
+		}
+	}
+
+	// The external resources are cleaned up already, the finalizer of the binding is not needed
+	if utils.ContainsString(bd.Finalizers, finalizerID) {
+		bd.Finalizers = utils.RemoveString(bd.Finalizers, finalizerID)
+		if err := r.Client.Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+			return fmt.Errorf("remove finalizer of binding error: %w", err)
+		}
+	}
+
+	if err := r.Client.Delete(ctx, bd); err != nil && !apierr.IsNotFound(err) {
+		return fmt.Errorf("delete binding error: %w", err)
+	}
+
+	return nil
+}
+
+// removePullSecretRef removes the pull secret from the service account, it returns true if the service account is changed
+func removePullSecretRef(sa *corev1.ServiceAccount, secretName string) bool {
+	refs := make([]corev1.LocalObjectReference, 0, len(sa.ImagePullSecrets))
+	for _, ref := range sa.ImagePullSecrets {
+		if ref.Name != secretName {
+			refs = append(refs, ref)
+		}
+	}
+
+	if len(refs) == len(sa.ImagePullSecrets) {
+		return false
+	}
+
+	sa.ImagePullSecrets = refs
+
+	return true
+}
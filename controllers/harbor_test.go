@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// newTestHarbor starts a fake harbor server serving the handler and returns its legacy client, the server should be closed
+func newTestHarbor(t *testing.T, handler http.Handler) (*legacy.Client, *httptest.Server) {
+	return newTestHarborWithCred(t, &model.AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"}, handler)
+}
+
+// newTestHarborWithCred is newTestHarbor with the legacy client talking to the fake harbor server with the credential
+func newTestHarborWithCred(t *testing.T, cred *model.AccessCred, handler http.Handler) (*legacy.Client, *httptest.Server) {
+	server := httptest.NewTLSServer(handler)
+
+	c, err := legacy.NewWithServer(model.NewHarborServer(strings.TrimPrefix(server.URL, "https://"), cred, true))
+	require.NoError(t, err)
+
+	return c, server
+}
+
+// newTestClients is newTestHarbor returning the clients of both APIs, the capabilities of the harbor server are not detected
+func newTestClients(t *testing.T, handler http.Handler) (*harborClient.Clients, *httptest.Server) {
+	server := httptest.NewTLSServer(handler)
+
+	hs := model.NewHarborServer(strings.TrimPrefix(server.URL, "https://"), &model.AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"}, true)
+	v2Client, err := v2.NewWithServer(hs)
+	require.NoError(t, err)
+	legacyClient, err := legacy.NewWithServer(hs)
+	require.NoError(t, err)
+
+	return &harborClient.Clients{V2: v2Client, Legacy: legacyClient}, server
+}
+
+// newTestScheme returns the scheme of the fake clients holding the objects of the operator
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(s))
+	require.NoError(t, goharborv1alpha1.AddToScheme(s))
+
+	return s
+}
@@ -0,0 +1,132 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// HarborServerReconciler reconciles a HarborServer object.
+// The harbor server is checked in the same way as the harbor server configuration.
+type HarborServerReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// MaxConcurrentReconciles is the max number of concurrent reconciles
+	MaxConcurrentReconciles int
+}
+
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborservers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborservers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+
+// Reconcile the HarborServer
+func (r *HarborServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("harborserver", req.NamespacedName)
+	log.Info("Starting HarborServer Reconciler")
+
+	hs := &goharborv1alpha1.HarborServer{}
+	if err := r.Client.Get(ctx, req.NamespacedName, hs); err != nil {
+		if apierr.IsNotFound(err) {
+			// It could have been deleted after reconcile request coming in.
+			log.Info("Harbor server does not exist")
+			metrics.DeleteServer(req.NamespacedName.String())
+			harborClient.Release(req.NamespacedName.String())
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, fmt.Errorf("get HarborServer error: %w", err)
+	}
+
+	hsc := hs.ToServerConfiguration()
+	checker := &HarborServerConfigurationReconciler{
+		Client: r.Client,
+		Log:    log,
+		Scheme: r.Scheme,
+	}
+
+	return checker.reconcileServer(ctx, log, hsc, serverObject{
+		update: func() error {
+			hs.Finalizers = hsc.Finalizers
+			return r.Client.Update(ctx, hs)
+		},
+		updateStatus: func() error {
+			hs.Status = hsc.Status
+			return r.Client.Status().Update(ctx, hs)
+		},
+	})
+}
+
+// SetupWithManager for HarborServer reconcile controller
+func (r *HarborServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&goharborv1alpha1.HarborServer{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.harborServersForReferredObject(hscSecretIndex),
+		}, builder.WithPredicates(referredObjectChanged())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.harborServersForReferredObject(hscConfigMapIndex),
+		}, builder.WithPredicates(referredObjectChanged())).
+		Complete(r)
+}
+
+// harborServersForReferredObject maps the changed secret or configmap to the harbor servers referring it with the index
+func (r *HarborServerReconciler) harborServersForReferredObject(index string) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		return r.harborServersForRef(index, types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()})
+	}
+}
+
+func (r *HarborServerReconciler) harborServersForRef(index string, ref types.NamespacedName) []reconcile.Request {
+	hscs, err := hscsReferring(context.Background(), r.Client, index, ref)
+	if err != nil {
+		r.Log.Error(err, "failed to list harbor servers referring object", "object", ref)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(hscs))
+	for _, hsc := range hscs {
+		if len(hsc.Namespace) == 0 {
+			// Served by the harbor server configuration controller
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: hsc.Namespace, Name: hsc.Name}})
+	}
+
+	return requests
+}
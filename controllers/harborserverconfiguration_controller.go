@@ -19,17 +19,29 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
 	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/metrics"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sigs.k8s.io/kustomize/kstatus/status"
 )
 
@@ -37,7 +49,12 @@ const (
 	defaultCycle    = 5 * time.Minute
 	defaultStatus   = "Unknown"
 	unhealthyStatus = "UnHealthy"
+	healthyStatus   = "healthy"
 	defaultComp     = "Harbor"
+	configComp      = "Configuration"
+	versionMismatch = "VersionMismatch"
+	// credentialsSufficient condition reports if the access credential has the permissions required by the operator
+	credentialsSufficient = "CredentialsSufficient"
 )
 
 // HarborServerConfigurationReconciler reconciles a HarborServerConfiguration object
@@ -45,13 +62,18 @@ type HarborServerConfigurationReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
-	Harbor *legacy.Client
+	// MaxConcurrentReconciles is the max number of concurrent reconciles
+	MaxConcurrentReconciles int
 }
 
 // +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborserverconfigurations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborserverconfigurations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=pullsecretbindings,verbs=get;list;watch;update;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=list
 
 // Reconcile the HarborServerConfiguration
 func (r *HarborServerConfigurationReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -65,45 +87,131 @@ func (r *HarborServerConfigurationReconciler) Reconcile(req ctrl.Request) (ctrl.
 		if apierr.IsNotFound(err) {
 			// It could have been deleted after reconcile request coming in.
 			log.Info("Harbor server configuration does not exist")
+			metrics.DeleteServer(req.Name)
+			harborClient.Release(req.Name)
 			return ctrl.Result{}, nil
 		}
 
 		return ctrl.Result{}, fmt.Errorf("get HarborServerConfiguraiton error: %w", err)
 	}
 
-	// Create harbor client
-	harborLegacy, err := harborClient.CreateHarborLegacyClient(ctx, r.Client, hsc)
-	if err != nil {
-		log.Error(err, "failed to create harbor client")
-		return ctrl.Result{}, nil
-	}
-	r.Harbor = harborLegacy
+	return r.reconcileServer(ctx, log, hsc, serverObject{
+		update: func() error {
+			return r.Client.Update(ctx, hsc)
+		},
+		updateStatus: func() error {
+			return r.Client.Status().Update(ctx, hsc)
+		},
+	})
+}
 
+// reconcileServer checks the harbor server of the configuration and writes the changes back with the server object.
+// It is shared by the harbor server configurations and the harbor servers.
+func (r *HarborServerConfigurationReconciler) reconcileServer(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration, obj serverObject) (ctrl.Result, error) {
 	// Check if the configuration is being deleted
 	if !hsc.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("Harbor server configuration is being deleted")
-		return ctrl.Result{}, nil
+		return r.finalizeServer(ctx, log, hsc, obj)
+	}
+
+	// Keep the configuration until its deletion policy is fulfilled
+	if !utils.ContainsString(hsc.Finalizers, hscFinalizer) {
+		hsc.Finalizers = append(hsc.Finalizers, hscFinalizer)
+		if err := obj.update(); err != nil {
+			return ctrl.Result{}, fmt.Errorf("add finalizer error: %w", err)
+		}
+	}
+
+	// Mark the new generation is being reconciled
+	if hsc.Status.ObservedGeneration != hsc.Generation && !goharborv1alpha1.IsConditionTrue(hsc.Status.Conditions, goharborv1alpha1.ConditionReconciling) {
+		for _, cond := range reconcilingConditions(hsc.Generation, "Progressing", fmt.Sprintf("reconciling generation %d", hsc.Generation)) {
+			hsc.Status.Conditions = goharborv1alpha1.SetCondition(hsc.Status.Conditions, cond)
+		}
+
+		if err := obj.updateStatus(); err != nil {
+			log.Info("failed to update status, requeue")
+			return r.requeueWithError(err)
+		}
+	}
+
+	// Create harbor clients for all the endpoints
+	serverURLs := hsc.Spec.ServerURLs()
+	harbors := make([]*legacy.Client, 0, len(serverURLs))
+	for _, serverURL := range serverURLs {
+		harborLegacy, err := harborClient.CreateHarborLegacyClientWithURL(ctx, r.Client, hsc, serverURL)
+		if err != nil {
+			log.Error(err, "failed to create harbor client", "serverURL", serverURL)
+			// Report the configuration error, it will be reconciled again once the HSC is changed
+			hsc.Status = invalidConfigStatus(hsc, err)
+			if err := obj.updateStatus(); err != nil {
+				log.Info("failed to update status, requeue")
+				return r.requeueWithError(err)
+			}
+
+			return ctrl.Result{}, nil
+		}
+
+		harbors = append(harbors, harborLegacy)
 	}
 
 	// Check server health and construct status
-	st, cerr := r.checkServerHealth()
+	active, endpoints := r.probeEndpoints(hsc.Key(), serverURLs, harbors)
+	if active.ServerURL != hsc.ActiveServerURL() {
+		log.Info("switch active endpoint", "from", hsc.ActiveServerURL(), "to", active.ServerURL)
+	}
+
+	harborLegacy := active.client
+	st, cerr := r.checkServerHealth(active, hsc.Key())
+	st.ActiveServerURL = active.ServerURL
+	st.Endpoints = endpoints
+	// Keep the managed credential and the provisioned proxy caches unless they are changed
+	st.ManagedCredential = hsc.Status.ManagedCredential
+	st.ProxyCaches = hsc.Status.ProxyCaches
+	requeueAfter := defaultCycle
+	if cerr == nil {
+		info := r.detectServerInfo(harborLegacy, hsc, &st)
+		st.Conditions = append(st.Conditions, r.checkCredentials(harborLegacy, hsc, info))
+
+		if hsc.Spec.ManagedCredential != nil {
+			cond, wait := r.manageCredential(ctx, log, hsc, active.ServerURL, harborLegacy, &st)
+			st.Conditions = append(st.Conditions, cond)
+			if wait > 0 && wait < requeueAfter {
+				requeueAfter = wait
+			}
+		}
+
+		if len(hsc.Spec.ProxyCaches) > 0 {
+			st.Conditions = append(st.Conditions, r.provisionProxyCaches(ctx, log, hsc, &st))
+		}
+	} else {
+		// Keep the last detected server info
+		st.ServerInfo = hsc.Status.ServerInfo
+	}
+	if hsc.Spec.ManagedCredential == nil {
+		st.ManagedCredential = nil
+	}
+	// The registry endpoints and projects of the removed proxy caches are left in harbor, only their rewrite rules are dropped
+	if len(hsc.Spec.ProxyCaches) == 0 {
+		st.ProxyCaches = nil
+	}
+	setStandardConditions(hsc, &st, cerr)
 
 	// Update status first for both success and failed checks
 	hsc.Status = st
-	if err := r.Client.Status().Update(ctx, hsc); err != nil {
+	if err := obj.updateStatus(); err != nil {
 		// requeue if there is error
 		log.Info("failed to update status, requeue")
 		return r.requeueWithError(err)
 	}
 
 	if cerr != nil {
-		return ctrl.Result{}, cerr
+		return requeueIfThrottled(ctrl.Result{}, cerr)
 	}
 
 	log.Info("Finished HarborServerConfiguration Reconciler")
-	// The health should be rechecked after a reasonable cycle
+	// The health should be rechecked after a reasonable cycle, or earlier if the managed credential should be rotated
 	return ctrl.Result{
-		RequeueAfter: defaultCycle,
+		RequeueAfter: requeueAfter,
 	}, nil
 }
 
@@ -111,17 +219,107 @@ func (r *HarborServerConfigurationReconciler) Reconcile(req ctrl.Request) (ctrl.
 func (r *HarborServerConfigurationReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&goharborv1alpha1.HarborServerConfiguration{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.hscsForReferredObject(hscSecretIndex),
+		}, builder.WithPredicates(referredObjectChanged())).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: r.hscsForReferredObject(hscConfigMapIndex),
+		}, builder.WithPredicates(referredObjectChanged())).
 		Complete(r)
 }
 
-func (r *HarborServerConfigurationReconciler) checkServerHealth() (goharborv1alpha1.HarborServerConfigurationStatus, error) {
+// hscsForReferredObject maps the changed secret or configmap to the harbor server configurations referring it with the index,
+// then the changed credentials and CA bundles are validated immediately
+func (r *HarborServerConfigurationReconciler) hscsForReferredObject(index string) handler.ToRequestsFunc {
+	return func(obj handler.MapObject) []reconcile.Request {
+		return r.hscsForRef(index, types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()})
+	}
+}
+
+func (r *HarborServerConfigurationReconciler) hscsForRef(index string, ref types.NamespacedName) []reconcile.Request {
+	hscs, err := hscsReferring(context.Background(), r.Client, index, ref)
+	if err != nil {
+		r.Log.Error(err, "failed to list harbor server configurations referring object", "object", ref)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(hscs))
+	for _, hsc := range hscs {
+		if len(hsc.Namespace) > 0 {
+			// Served by the harbor server controller
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: hsc.Name}})
+	}
+
+	return requests
+}
+
+// endpointProbe is the health check result of an endpoint
+type endpointProbe struct {
+	ServerURL string
+	client    *legacy.Client
+	payload   *models.OverallHealthStatus
+	err       error
+	duration  time.Duration
+}
+
+func (p *endpointProbe) healthy() bool {
+	return p.err == nil && p.payload.Status == healthyStatus
+}
+
+// probeEndpoints checks the health of all the endpoints, the first healthy one in order becomes active.
+// The primary endpoint keeps active if none of them is healthy.
+func (r *HarborServerConfigurationReconciler) probeEndpoints(name string, serverURLs []string, harbors []*legacy.Client) (*endpointProbe, []goharborv1alpha1.EndpointStatus) {
+	var active *endpointProbe
+	endpoints := make([]goharborv1alpha1.EndpointStatus, 0, len(harbors))
+	endpointHealth := make(map[string]bool, len(harbors))
+	for i, harbor := range harbors {
+		start := time.Now()
+		payload, err := harbor.CheckHealth()
+		probe := &endpointProbe{
+			ServerURL: serverURLs[i],
+			client:    harbor,
+			payload:   payload,
+			err:       err,
+			duration:  time.Since(start),
+		}
+
+		ep := goharborv1alpha1.EndpointStatus{
+			ServerURL:     probe.ServerURL,
+			Healthy:       probe.healthy(),
+			LastProbeTime: metav1.Now(),
+		}
+		switch {
+		case err != nil:
+			ep.Message = err.Error()
+		case !ep.Healthy:
+			ep.Message = fmt.Sprintf("harbor server is %s", payload.Status)
+		}
+		endpoints = append(endpoints, ep)
+		endpointHealth[probe.ServerURL] = ep.Healthy
+
+		if i == 0 || !active.healthy() && probe.healthy() {
+			active = probe
+		}
+	}
+	metrics.RecordEndpointHealth(name, endpointHealth)
+
+	return active, endpoints
+}
+
+func (r *HarborServerConfigurationReconciler) checkServerHealth(probe *endpointProbe, name string) (goharborv1alpha1.HarborServerConfigurationStatus, error) {
 	overallStatus := goharborv1alpha1.HarborServerConfigurationStatus{
 		Status:     defaultStatus,
 		Conditions: make([]goharborv1alpha1.Condition, 0),
 	}
 
-	healthPayload, err := r.Harbor.CheckHealth()
+	healthPayload, err, duration := probe.payload, probe.err, probe.duration
 	if err != nil {
+		metrics.RecordHealthCheck(name, false, nil, duration)
+
 		r.Log.Error(err, "check harbor server health failed.")
 		overallStatus.Conditions = append(overallStatus.Conditions, goharborv1alpha1.Condition{
 			Type:    status.ConditionType(defaultComp),
@@ -134,7 +332,10 @@ func (r *HarborServerConfigurationReconciler) checkServerHealth() (goharborv1alp
 	}
 
 	overallStatus.Status = healthPayload.Status
+	componentHealth := make(map[string]bool, len(healthPayload.Components))
 	for _, comp := range healthPayload.Components {
+		componentHealth[comp.Name] = len(comp.Error) == 0
+
 		cond := goharborv1alpha1.Condition{
 			Type:   status.ConditionType(comp.Name),
 			Status: corev1.ConditionTrue,
@@ -149,10 +350,203 @@ func (r *HarborServerConfigurationReconciler) checkServerHealth() (goharborv1alp
 
 		overallStatus.Conditions = append(overallStatus.Conditions, cond)
 	}
+	metrics.RecordHealthCheck(name, healthPayload.Status == healthyStatus, componentHealth, duration)
 
 	return overallStatus, nil
 }
 
+// detectServerInfo detects the version and capabilities of the harbor server and
+// compares the detected version with the declared one. It returns nil if the detection is failed.
+func (r *HarborServerConfigurationReconciler) detectServerInfo(harbor *legacy.Client, hsc *goharborv1alpha1.HarborServerConfiguration, st *goharborv1alpha1.HarborServerConfigurationStatus) *model.ServerInfo {
+	info, err := harbor.GetSystemInfo()
+	if err != nil {
+		r.Log.Error(err, "detect harbor server info failed.")
+		// Keep the last detected server info
+		st.ServerInfo = hsc.Status.ServerInfo
+		return nil
+	}
+
+	caps, err := info.Capabilities()
+	if err != nil {
+		r.Log.Error(err, "detect harbor server capabilities failed.")
+	}
+
+	st.ServerInfo = &goharborv1alpha1.ServerInfo{
+		Version:         info.Version,
+		AuthMode:        info.AuthMode,
+		RegistryURL:     info.RegistryURL,
+		WithNotary:      info.WithNotary,
+		WithChartmuseum: info.WithChartmuseum,
+		ReadOnly:        info.ReadOnly,
+		Capabilities:    caps,
+	}
+
+	if len(hsc.Spec.Version) == 0 {
+		return info
+	}
+
+	cond := goharborv1alpha1.Condition{
+		Type:   status.ConditionType(versionMismatch),
+		Status: corev1.ConditionFalse,
+	}
+
+	declared, derr := utils.ParseVersion(hsc.Spec.Version)
+	detected, err := utils.ParseVersion(info.Version)
+	switch {
+	case derr != nil:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "InvalidVersion"
+		cond.Message = derr.Error()
+	case err != nil:
+		cond.Status = corev1.ConditionUnknown
+		cond.Reason = "UndetectableVersion"
+		cond.Message = err.Error()
+	case declared.Compare(detected) != 0:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "VersionMismatch"
+		cond.Message = fmt.Sprintf("declared version %s does not match the detected version %s", hsc.Spec.Version, info.Version)
+	}
+
+	st.Conditions = append(st.Conditions, cond)
+
+	return info
+}
+
+// checkCredentials verifies the access credential has the permissions required by the operator
+func (r *HarborServerConfigurationReconciler) checkCredentials(harbor *legacy.Client, hsc *goharborv1alpha1.HarborServerConfiguration, info *model.ServerInfo) goharborv1alpha1.Condition {
+	cond := goharborv1alpha1.Condition{
+		Type:   status.ConditionType(credentialsSufficient),
+		Status: corev1.ConditionUnknown,
+	}
+
+	// The managed robot account is in use if it is ready
+	if harbor.CredentialType() == goharborv1alpha1.AccessCredentialTypeRobot {
+		cond.Reason = "VerificationNotSupported"
+		cond.Message = "the permissions of robot accounts can not be verified"
+		return cond
+	}
+
+	// The permissions are unknown if they can not be read, the transient API failures should not stall the configuration
+	user, err := harbor.GetCurrentUser()
+	if err != nil {
+		r.Log.Error(err, "get current user failed.")
+		cond.Reason = "VerificationFailed"
+		cond.Message = fmt.Sprintf("get the user of the credential error: %s", err)
+		return cond
+	}
+
+	if user.SysadminFlag || user.AdminRoleInAuth {
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "SystemAdmin"
+		cond.Message = fmt.Sprintf("user %s is system admin", user.Username)
+		return cond
+	}
+
+	granted, err := harbor.GetCurrentUserPermissions(model.ScopeSystem)
+	if err != nil {
+		r.Log.Error(err, "get permissions of current user failed.")
+		cond.Reason = "VerificationFailed"
+		cond.Message = fmt.Sprintf("get the permissions of user %s error: %s", user.Username, err)
+		return cond
+	}
+
+	// All the users can create projects if the creation is not restricted to admins
+	if info != nil && info.ProjectCreationRestriction == model.ProjectCreationEveryone {
+		granted = append(granted, model.PermissionCreateProject)
+	}
+
+	missing := model.MissingPermissions(requiredPermissions(hsc), granted)
+	if len(missing) > 0 {
+		names := make([]string, 0, len(missing))
+		for _, p := range missing {
+			names = append(names, p.String())
+		}
+
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = "MissingPermissions"
+		cond.Message = fmt.Sprintf("user %s is missing permissions: %s", user.Username, strings.Join(names, ", "))
+		return cond
+	}
+
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = "PermissionsGranted"
+	cond.Message = fmt.Sprintf("user %s has all the required permissions", user.Username)
+
+	return cond
+}
+
+// requiredPermissions returns the system level permissions required by the features enabled with the configuration.
+// The project level permissions are granted to the user creating the projects.
+func requiredPermissions(hsc *goharborv1alpha1.HarborServerConfiguration) []model.Permission {
+	permissions := []model.Permission{model.PermissionCreateProject}
+	if len(hsc.Spec.ProxyCaches) > 0 {
+		permissions = append(permissions, model.PermissionCreateRegistry, model.PermissionUpdateRegistry, model.PermissionListRegistry)
+	}
+
+	return permissions
+}
+
+// setStandardConditions sets the Ready, Reconciling and Stalled conditions based on the health check result.
+// The transition times of the conditions whose status is not changed are kept.
+func setStandardConditions(hsc *goharborv1alpha1.HarborServerConfiguration, st *goharborv1alpha1.HarborServerConfigurationStatus, cerr error) {
+	unhealthy := make([]string, 0)
+	var credCond *goharborv1alpha1.Condition
+	for i, cond := range st.Conditions {
+		switch cond.Type {
+		case versionMismatch, credentialManaged, proxyCachesProvisioned:
+		case credentialsSufficient:
+			credCond = &st.Conditions[i]
+		default:
+			if cond.Status == corev1.ConditionFalse {
+				unhealthy = append(unhealthy, string(cond.Type))
+			}
+		}
+	}
+
+	var conditions []goharborv1alpha1.Condition
+	switch {
+	case credCond != nil && credCond.Status == corev1.ConditionFalse:
+		// The credential has to be granted with the missing permissions
+		conditions = stalledConditions(hsc.Generation, "CredentialsInsufficient", credCond.Message)
+	case cerr != nil:
+		// The server may be temporarily unreachable, the health check is retried
+		conditions = reconcilingConditions(hsc.Generation, "HealthCheckFailed", cerr.Error())
+	case len(unhealthy) > 0:
+		conditions = reconcilingConditions(hsc.Generation, "ComponentsUnhealthy", fmt.Sprintf("unhealthy components: %s", strings.Join(unhealthy, ", ")))
+	default:
+		conditions = readyConditions(hsc.Generation, "Healthy", "Harbor server is healthy")
+	}
+
+	for i := range st.Conditions {
+		st.Conditions[i].ObservedGeneration = hsc.Generation
+	}
+
+	st.Conditions = goharborv1alpha1.MergeConditions(hsc.Status.Conditions, append(conditions, st.Conditions...))
+	st.ObservedGeneration = hsc.Generation
+}
+
+func invalidConfigStatus(hsc *goharborv1alpha1.HarborServerConfiguration, err error) goharborv1alpha1.HarborServerConfigurationStatus {
+	conditions := append(stalledConditions(hsc.Generation, "InvalidConfiguration", err.Error()), goharborv1alpha1.Condition{
+		Type:               status.ConditionType(configComp),
+		Status:             corev1.ConditionFalse,
+		Reason:             "InvalidConfiguration",
+		Message:            err.Error(),
+		ObservedGeneration: hsc.Generation,
+	})
+
+	return goharborv1alpha1.HarborServerConfigurationStatus{
+		Status:             unhealthyStatus,
+		Conditions:         goharborv1alpha1.MergeConditions(hsc.Status.Conditions, conditions),
+		ObservedGeneration: hsc.Generation,
+		// Keep the last detected server info and endpoints
+		ServerInfo:        hsc.Status.ServerInfo,
+		ActiveServerURL:   hsc.Status.ActiveServerURL,
+		Endpoints:         hsc.Status.Endpoints,
+		ManagedCredential: hsc.Status.ManagedCredential,
+		ProxyCaches:       hsc.Status.ProxyCaches,
+	}
+}
+
 func (r *HarborServerConfigurationReconciler) requeueWithError(err error) (ctrl.Result, error) {
 	res := ctrl.Result{
 		Requeue: true,
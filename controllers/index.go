@@ -0,0 +1,168 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+)
+
+const (
+	// hscSecretIndex indexes the harbor server configurations by the secrets they refer
+	hscSecretIndex = "spec.secretRefs"
+	// hscConfigMapIndex indexes the harbor server configurations by the configmaps they refer
+	hscConfigMapIndex = "spec.configMapRefs"
+	// psbHSCIndex indexes the pull secret bindings by the harbor server configuration they refer
+	psbHSCIndex = "spec.harborServerConfig"
+	// psbSAIndex indexes the pull secret bindings by the service account they bind
+	psbSAIndex = "spec.serviceAccount"
+)
+
+// SetupIndexes registers the field indexes shared by the controllers.
+// It should be called once before setting up the controllers.
+func SetupIndexes(ctx context.Context, mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.HarborServerConfiguration{}, hscSecretIndex, func(obj runtime.Object) []string {
+		hsc, ok := obj.(*goharborv1alpha1.HarborServerConfiguration)
+		if !ok {
+			return nil
+		}
+
+		return refKeys(harborClient.SecretRefs(hsc))
+	}); err != nil {
+		return fmt.Errorf("index harbor server configurations by secrets error: %w", err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.HarborServer{}, hscSecretIndex, func(obj runtime.Object) []string {
+		hs, ok := obj.(*goharborv1alpha1.HarborServer)
+		if !ok {
+			return nil
+		}
+
+		return refKeys(harborClient.SecretRefs(hs.ToServerConfiguration()))
+	}); err != nil {
+		return fmt.Errorf("index harbor servers by secrets error: %w", err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.HarborServerConfiguration{}, hscConfigMapIndex, func(obj runtime.Object) []string {
+		hsc, ok := obj.(*goharborv1alpha1.HarborServerConfiguration)
+		if !ok {
+			return nil
+		}
+
+		return refKeys(harborClient.ConfigMapRefs(hsc))
+	}); err != nil {
+		return fmt.Errorf("index harbor server configurations by configmaps error: %w", err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.HarborServer{}, hscConfigMapIndex, func(obj runtime.Object) []string {
+		hs, ok := obj.(*goharborv1alpha1.HarborServer)
+		if !ok {
+			return nil
+		}
+
+		return refKeys(harborClient.ConfigMapRefs(hs.ToServerConfiguration()))
+	}); err != nil {
+		return fmt.Errorf("index harbor servers by configmaps error: %w", err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.PullSecretBinding{}, psbHSCIndex, func(obj runtime.Object) []string {
+		psb, ok := obj.(*goharborv1alpha1.PullSecretBinding)
+		if !ok {
+			return nil
+		}
+
+		return []string{psb.Spec.HarborServerConfig}
+	}); err != nil {
+		return fmt.Errorf("index pull secret bindings by harbor server configuration error: %w", err)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &goharborv1alpha1.PullSecretBinding{}, psbSAIndex, func(obj runtime.Object) []string {
+		psb, ok := obj.(*goharborv1alpha1.PullSecretBinding)
+		if !ok {
+			return nil
+		}
+
+		return []string{psb.Spec.ServiceAccount}
+	}); err != nil {
+		return fmt.Errorf("index pull secret bindings by service account error: %w", err)
+	}
+
+	return nil
+}
+
+func refKeys(refs []types.NamespacedName) []string {
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, ref.String())
+	}
+
+	return keys
+}
+
+// hscsReferringSecret lists the harbor server configurations referring the secret,
+// together with the configuration views of the harbor servers in the namespace of the secret
+func hscsReferringSecret(ctx context.Context, c client.Client, secret types.NamespacedName) ([]goharborv1alpha1.HarborServerConfiguration, error) {
+	return hscsReferring(ctx, c, hscSecretIndex, secret)
+}
+
+// hscsReferring lists the harbor server configurations and harbor servers referring the object with the index
+func hscsReferring(ctx context.Context, c client.Client, index string, ref types.NamespacedName) ([]goharborv1alpha1.HarborServerConfiguration, error) {
+	hscs := &goharborv1alpha1.HarborServerConfigurationList{}
+	if err := c.List(ctx, hscs, client.MatchingFields{index: ref.String()}); err != nil {
+		return nil, err
+	}
+
+	hss := &goharborv1alpha1.HarborServerList{}
+	if err := c.List(ctx, hss, client.InNamespace(ref.Namespace), client.MatchingFields{index: ref.String()}); err != nil {
+		return nil, err
+	}
+
+	for i := range hss.Items {
+		hscs.Items = append(hscs.Items, *hss.Items[i].ToServerConfiguration())
+	}
+
+	return hscs.Items, nil
+}
+
+// psbsReferringHSC lists the pull secret bindings referring the harbor server configuration.
+// Only the bindings in the namespace of the configuration viewed from a harbor server are listed.
+func psbsReferringHSC(ctx context.Context, c client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) ([]goharborv1alpha1.PullSecretBinding, error) {
+	psbs := &goharborv1alpha1.PullSecretBindingList{}
+	if err := c.List(ctx, psbs, client.InNamespace(hsc.Namespace), client.MatchingFields{psbHSCIndex: hsc.Name}); err != nil {
+		return nil, err
+	}
+
+	return psbs.Items, nil
+}
+
+// psbsBindingServiceAccount lists the pull secret bindings of the service account
+func psbsBindingServiceAccount(ctx context.Context, c client.Client, sa types.NamespacedName) ([]goharborv1alpha1.PullSecretBinding, error) {
+	psbs := &goharborv1alpha1.PullSecretBindingList{}
+	if err := c.List(ctx, psbs, client.InNamespace(sa.Namespace), client.MatchingFields{psbSAIndex: sa.Name}); err != nil {
+		return nil, err
+	}
+
+	return psbs.Items, nil
+}
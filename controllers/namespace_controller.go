@@ -19,24 +19,29 @@ package controllers
 import (
 	"context"
 	"fmt"
-	"strconv"
 
 	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
-	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
-	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
 	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
 )
 
@@ -47,17 +52,35 @@ const (
 // NamespaceReconciler reconciles a Namespace object
 type NamespaceReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	HarborV2 *v2.Client
-	Harbor   *legacy.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// MaxConcurrentReconciles is the max number of concurrent reconciles
+	MaxConcurrentReconciles int
+	// MaxConcurrentReconcilesPerServer is the max number of concurrent reconciles of the same harbor server configuration,
+	// no limit other than MaxConcurrentReconciles if it is not positive
+	MaxConcurrentReconcilesPerServer int
+	// ClusterName is rendered into the names of the projects created for the namespaces
+	ClusterName string
+	// Recorder records the actions taken on the projects of the deleted namespaces
+	Recorder record.EventRecorder
+
+	gate *harborClient.ReconcileGate
 }
 
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=namespaces/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=pullsecretbindings,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=pullsecretbindings,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborservers,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *NamespaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	return requeueIfThrottled(r.reconcile(req))
+}
+
+func (r *NamespaceReconciler) reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("namespace", req.NamespacedName)
 
@@ -75,7 +98,7 @@ func (r *NamespaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	// Check if the ns is being deleted
 	if !ns.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("namespace is being deleted", "name", ns.Name)
-		return ctrl.Result{}, nil
+		return r.finalizeNamespace(ctx, log, ns)
 	}
 
 	// Get the binding list if existing
@@ -96,35 +119,44 @@ func (r *NamespaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
-	// Pull secret issuer is set and then check if the required default binding exists
-	// Confirm the service account name
+	// No new bindings for the configuration being deleted, the existing ones are handled by its deletion policy
+	if !harborCfg.ObjectMeta.DeletionTimestamp.IsZero() {
+		log.Info("harbor server configuration is being deleted, skip PSB creation", "hsc", harborCfg.Key())
+		return ctrl.Result{}, nil
+	}
+
+	// Leave the workers to the other servers if this one is busy
+	release, acquired := r.gate.TryAcquire(harborCfg.Key())
+	if !acquired {
+		log.Info("too many reconciles of the harbor server configuration, requeue", "hsc", harborCfg.Key())
+		return ctrl.Result{RequeueAfter: harborClient.BusyRetryDelay}, nil
+	}
+	defer release()
+
+	// Confirm the service accounts binding the pull secrets
 	// Use default SA if not set inside annotation
-	saName := defaultSaName
-	if setSa, ok := ns.Annotations[utils.AnnotationAccount]; ok {
-		saName = setSa
+	saNames, err := r.serviceAccountNames(ctx, ns)
+	if err != nil {
+		return ctrl.Result{}, err
 	}
-	// Check if custom service account exist
-	sa := &corev1.ServiceAccount{}
-	saNamespacedName := types.NamespacedName{
-		Namespace: ns.Name,
-		Name:      saName,
+
+	if err := r.removeUnselectedPSBs(ctx, log, ns, harborCfg.Name, saNames, bindings); err != nil {
+		return ctrl.Result{}, err
 	}
-	if err := r.Client.Get(ctx, saNamespacedName, sa); err != nil {
-		if apierr.IsNotFound(err) {
-			return ctrl.Result{}, fmt.Errorf("service account %s not found in namespace %s: %w", saName, ns.Name, err)
-		}
-		return ctrl.Result{}, fmt.Errorf("get service account %s in namespace %s error: %w", saName, ns.Name, err)
+
+	if err := r.syncBindingPullProjects(ctx, log, ns, harborCfg.Name, bindings); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	// Find PSB
-	for _, bd := range bindings.Items {
-		if bd.Spec.HarborServerConfig == harborCfg.Name && bd.Spec.ServiceAccount == saName {
-			// Found it and reconcile is done
-			// TODO: the PSB might be useless if the credentials or projects are changed
-			// Need to check if the PSB is still valid.
-			log.Info("psb exist for this namespace")
-			return ctrl.Result{}, nil
-		}
+	unbound, err := r.unboundServiceAccounts(ctx, log, ns, harborCfg.Name, saNames, bindings)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if len(unbound) == 0 {
+		// The drift of the bound projects and robot accounts is repaired by the PSB reconciler
+		log.Info("psb exist for the service accounts of this namespace")
+		return r.syncProject(ctx, log, harborCfg, ns)
 	}
 
 	proj, projExist := ns.Annotations[utils.AnnotationProject]
@@ -133,42 +165,172 @@ func (r *NamespaceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
-	if err := r.setHarborClient(ctx, log, harborCfg); err != nil {
+	harbor, err := r.getHarborClient(ctx, log, harborCfg)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	var projName, projID, robotID string
-	if projName, projID, robotID, err = r.validateHarborProjectAndRobot(ctx, log, ns); err != nil {
+	projName, projID, err := r.validateHarborProject(log, harbor, harborCfg, ns)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// PSB doesn't exist, create one
-	log.Info("creating pull secret binding")
-	psb, err := r.createPullSecretBinding(ctx, ns, harborCfg.Name, saName, robotID, projID)
-	if err != nil {
+	// PSB doesn't exist, create one for each service account
+	if err := r.bindServiceAccounts(ctx, log, ns, harborCfg.Name, unbound, projName, projID, pullProjects(ns, projName)); err != nil {
 		return ctrl.Result{}, err
 	}
-	log.Info("created pull secret binding", "name", psb.Name)
 
 	// update namespace with updated annotation
 	if proj == "*" {
-		log.Info("update namespace annotations", "projectName", projName, "robotID", robotID)
+		log.Info("update namespace annotations", "projectName", projName)
 		ns.Annotations[utils.AnnotationProject] = projName
-		ns.Annotations[utils.AnnotationRobot] = robotID
 		if err := r.Client.Update(ctx, ns, &client.UpdateOptions{}); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	return r.syncProject(ctx, log, harborCfg, ns)
+}
+
+// syncProject keeps the declared settings and members in sync with the project of the namespace,
+// the namespace is rechecked periodically to revert the changes made in Harbor
+func (r *NamespaceReconciler) syncProject(ctx context.Context, log logr.Logger, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) (ctrl.Result, error) {
+	proj := ns.Annotations[utils.AnnotationProject]
+	if proj == "" || proj == "*" {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.ensureNamespaceFinalizer(ctx, harborCfg, ns); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	settings, err := projectSettings(harborCfg, ns)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	roles, err := memberRoles(harborCfg, ns)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if settings.IsEmpty() && len(roles) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	harbor, err := r.getHarborClient(ctx, log, harborCfg)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !settings.IsEmpty() {
+		updated, err := harbor.SyncProjectSettings(proj, settings)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("sync settings of project %s error: %w", proj, err)
+		}
+
+		if updated {
+			log.Info("project settings are synced", "project", proj)
+		}
+	}
+
+	if err := r.syncProjectMembers(ctx, log, harbor, harborCfg, ns, proj, roles); err != nil {
+		return ctrl.Result{}, fmt.Errorf("sync members of project %s error: %w", proj, err)
+	}
+
+	return ctrl.Result{RequeueAfter: defaultCycle}, nil
 }
 
 func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.gate = harborClient.NewReconcileGate(r.MaxConcurrentReconcilesPerServer)
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Namespace{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.namespacesForSecret),
+		}, builder.WithPredicates(referredObjectChanged())).
+		Watches(&source.Kind{Type: &rbacv1.RoleBinding{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(namespaceOfObject),
+		}, builder.WithPredicates(namespaceSubjectsChanged())).
+		Watches(&source.Kind{Type: &corev1.ServiceAccount{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(namespaceOfObject),
+		}, builder.WithPredicates(namespaceSubjectsChanged())).
 		Complete(r)
 }
 
+// namespaceOfObject maps the changed object to its namespace, e.g: the changed role binding may change the project members
+// and the created or relabeled service account may need a binding
+func namespaceOfObject(obj handler.MapObject) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.Meta.GetNamespace()}}}
+}
+
+// namespacesForSecret maps the changed secret to the namespaces served by the harbor server configurations referring it
+func (r *NamespaceReconciler) namespacesForSecret(obj handler.MapObject) []reconcile.Request {
+	ctx := context.Background()
+	secret := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+	hscs, err := hscsReferringSecret(ctx, r.Client, secret)
+	if err != nil {
+		r.Log.Error(err, "failed to list harbor server configurations referring secret", "secret", secret)
+		return nil
+	}
+
+	if len(hscs) == 0 {
+		return nil
+	}
+
+	nsList := &corev1.NamespaceList{}
+	if err := r.Client.List(ctx, nsList); err != nil {
+		r.Log.Error(err, "failed to list namespaces")
+		return nil
+	}
+
+	namespaces := make(map[string]struct{})
+	for _, hsc := range hscs {
+		psbs, err := psbsReferringHSC(ctx, r.Client, &hsc)
+		if err != nil {
+			r.Log.Error(err, "failed to list pull secret bindings referring harbor server configuration", "hsc", hsc.Key())
+		}
+
+		for _, psb := range psbs {
+			namespaces[psb.Namespace] = struct{}{}
+		}
+
+		if len(hsc.Namespace) > 0 {
+			// The harbor server only serves its own namespace
+			if harborCfg := namespaceHarborServer(nsList, hsc.Namespace); harborCfg == hsc.Name {
+				namespaces[hsc.Namespace] = struct{}{}
+			}
+			continue
+		}
+
+		for _, ns := range nsList.Items {
+			harborCfg := ns.Annotations[utils.AnnotationHarborServer]
+			if harborCfg == hsc.Name || (harborCfg == "" && hsc.Spec.Default) {
+				namespaces[ns.Name] = struct{}{}
+			}
+		}
+	}
+
+	requests := make([]reconcile.Request, 0, len(namespaces))
+	for ns := range namespaces {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns}})
+	}
+
+	return requests
+}
+
+// namespaceHarborServer returns the harbor server annotated on the namespace
+func namespaceHarborServer(nsList *corev1.NamespaceList, name string) string {
+	for _, ns := range nsList.Items {
+		if ns.Name == name {
+			return ns.Annotations[utils.AnnotationHarborServer]
+		}
+	}
+
+	return ""
+}
+
 func (r *NamespaceReconciler) getNewBindingCR(ns string, harborCfg string, sa string) *goharborv1alpha1.PullSecretBinding {
 	return &goharborv1alpha1.PullSecretBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -182,57 +344,41 @@ func (r *NamespaceReconciler) getNewBindingCR(ns string, harborCfg string, sa st
 	}
 }
 
-func (r *NamespaceReconciler) validateProject(projectName string) (string, error) {
-	var proj *v2models.Project
-	var err error
-	if proj, err = r.HarborV2.GetProject(projectName); err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("%d", proj.ProjectID), nil
-}
-
-func (r *NamespaceReconciler) validateRobot(proj, robot string) error {
-	if robot == "" {
-		return fmt.Errorf("robot should not be empty")
-	}
-	if proj == "" {
-		return fmt.Errorf("proj should not be empty")
-	}
-
-	robotID, err := strconv.ParseInt(robot, 10, 64)
+func (r *NamespaceReconciler) validateProject(harbor *harborClient.Clients, projectName string) (string, error) {
+	projects, err := harbor.Projects()
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	projectID, err := strconv.ParseInt(proj, 10, 64)
-	if err != nil {
-		return err
+	var proj *v2models.Project
+	if proj, err = projects.GetProject(projectName); err != nil {
+		return "", err
 	}
-
-	_, err = r.Harbor.GetRobotAccount(projectID, robotID)
-	return err
+	return fmt.Sprintf("%d", proj.ProjectID), nil
 }
 
-func (r *NamespaceReconciler) createProjectAndRobot(proj string) (string, string, error) {
-	projID, err := r.HarborV2.EnsureProject(proj)
+// createProject creates the project of the namespace if it does not exist. No robot account is created here,
+// its token is only returned once, so each binding creates the robot account whose credential it keeps in the pull secret.
+func (r *NamespaceReconciler) createProject(harbor *harborClient.Clients, proj string, settings *model.ProjectSettings) (string, error) {
+	projects, err := harbor.Projects()
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	robot, err := r.Harbor.CreateRobotAccount(projID)
+	projID, err := projects.EnsureProject(proj, settings)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
 
-	return fmt.Sprintf("%d", projID), fmt.Sprintf("%d", robot.ID), nil
+	return fmt.Sprintf("%d", projID), nil
 }
 
 func (r *NamespaceReconciler) findDefaultHarborCfg(ctx context.Context, log logr.Logger, ns *corev1.Namespace) (*goharborv1alpha1.HarborServerConfiguration, error) {
 	// check annotation first
 	harborCfg, yes := ns.Annotations[utils.AnnotationHarborServer]
 	if yes && harborCfg != "" {
-		hsc := &goharborv1alpha1.HarborServerConfiguration{}
-		err := r.Client.Get(ctx, types.NamespacedName{Name: harborCfg}, hsc)
+		// The harbor server in the namespace is preferred
+		hsc, err := harborClient.GetServerConfiguration(ctx, r.Client, ns.Name, harborCfg)
 		if err != nil {
 			if apierr.IsNotFound(err) {
 				log.Info("hsc specified in annotation doesn't exist")
@@ -277,14 +423,35 @@ func (r *NamespaceReconciler) removeStalePSB(ctx context.Context, log logr.Logge
 	return nil
 }
 
-func (r *NamespaceReconciler) createPullSecretBinding(ctx context.Context, ns *corev1.Namespace, harborCfg, saName, robotID, projID string) (*goharborv1alpha1.PullSecretBinding, error) {
+// bindServiceAccounts creates a binding for each of the unbound service accounts.
+// Each binding creates a robot account of its own once it is reconciled, as the robot token is only returned on creation.
+// A robot account still shared by the bindings of the earlier versions is only revoked once no other binding refers it (see robotInUse).
+func (r *NamespaceReconciler) bindServiceAccounts(ctx context.Context, log logr.Logger, ns *corev1.Namespace, harborCfg string, unbound []string, projName, projID string, pullProjects []string) error {
+	for _, saName := range unbound {
+		log.Info("creating pull secret binding", "serviceAccount", saName)
+		psb, err := r.createPullSecretBinding(ctx, ns, harborCfg, saName, projName, projID, pullProjects)
+		if err != nil {
+			return err
+		}
+		log.Info("created pull secret binding", "name", psb.Name, "serviceAccount", saName)
+	}
+
+	return nil
+}
+
+func (r *NamespaceReconciler) createPullSecretBinding(ctx context.Context, ns *corev1.Namespace, harborCfg, saName, projName, projID string, pullProjects []string) (*goharborv1alpha1.PullSecretBinding, error) {
 	defaultBinding := r.getNewBindingCR(ns.Name, harborCfg, saName)
 	if err := controllerutil.SetControllerReference(ns, defaultBinding, r.Scheme); err != nil {
 		return nil, fmt.Errorf("set ctrl reference error: %w", err)
 	}
 
-	defaultBinding.Spec.RobotID = robotID
+	// The project is recreated with the name if it is deleted
+	defaultBinding.Annotations = map[string]string{utils.AnnotationBoundProject: projName}
+
 	defaultBinding.Spec.ProjectID = projID
+	if len(pullProjects) > 0 {
+		defaultBinding.Spec.PullProjects = pullProjects
+	}
 
 	if err := r.Client.Create(ctx, defaultBinding, &client.CreateOptions{}); err != nil {
 		return nil, fmt.Errorf("create binding CR error: %w", err)
@@ -293,53 +460,52 @@ func (r *NamespaceReconciler) createPullSecretBinding(ctx context.Context, ns *c
 	return defaultBinding, nil
 }
 
-func (r *NamespaceReconciler) setHarborClient(ctx context.Context, log logr.Logger, harborCfg *goharborv1alpha1.HarborServerConfiguration) error {
+func (r *NamespaceReconciler) getHarborClient(ctx context.Context, log logr.Logger, harborCfg *goharborv1alpha1.HarborServerConfiguration) (*harborClient.Clients, error) {
 	// Create harbor client
-	harborV2, harborLegacy, err := harborClient.CreateHarborClients(ctx, r.Client, harborCfg)
+	clients, err := harborClient.CreateHarborClients(ctx, r.Client, harborCfg)
 	if err != nil {
 		log.Error(err, "failed to create harbor client")
-		return err
+		return nil, err
 	}
-	r.HarborV2 = harborV2
-	r.Harbor = harborLegacy
-	return nil
+	return clients, nil
 }
 
-func (r *NamespaceReconciler) validateHarborProjectAndRobot(ctx context.Context, log logr.Logger, ns *corev1.Namespace) (string, string, string, error) {
-	var err error
-	var projID string
-
-	// Validate the annotation and create PSB is needed
+// validateHarborProject creates the project of the namespace annotated with project "*", or validates the annotated project.
+// It returns the name and ID of the project.
+func (r *NamespaceReconciler) validateHarborProject(log logr.Logger, harbor *harborClient.Clients, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) (string, string, error) {
 	proj := ns.Annotations[utils.AnnotationProject]
-	robotID, robotExist := ns.Annotations[utils.AnnotationRobot]
+
+	// Each binding creates a robot account of its own, the robot account of the namespace is not used by the new bindings
+	if robotID, ok := ns.Annotations[utils.AnnotationRobot]; ok {
+		log.Info("annotation robot is deprecated and ignored by the new bindings", "robotID", robotID)
+	}
 
 	if proj == "*" {
-		log.Info("validate project and robot account")
-		// Automatically generate project and robot account based on namespace name
-		// TODO: should be more structure name since many clusters might share the same Harbor instance
-		proj = utils.RandomName(ns.Name)
-		projID, robotID, err = r.createProjectAndRobot(proj)
+		log.Info("create project of namespace")
+		// Automatically generate project based on the project name template.
+		// The name is stable for the namespace, so the existing project is reused if the annotation is lost.
+		name, err := utils.RenderProjectName(harborCfg.Spec.GetProjectNameTemplate(), utils.NewProjectNameData(r.ClusterName, ns.Name, ns.Labels))
 		if err != nil {
-			log.Error(err, "Failed creating project and robot", "project", proj, "robot", robotID)
-			return "", "", "", err
+			log.Error(err, "Failed rendering project name", "template", harborCfg.Spec.GetProjectNameTemplate())
+			return "", "", err
 		}
-		return proj, projID, robotID, nil
-	} else {
-		projID, err = r.validateProject(proj)
+		settings, err := projectSettings(harborCfg, ns)
 		if err != nil {
-			log.Error(err, "Harbor annotation for project is invalid", "project", proj)
-			return "", "", "", fmt.Errorf("project are invalid: %w", err)
+			return "", "", err
 		}
-		if !robotExist {
-			return "", "", "", fmt.Errorf("robotID is not set")
-		}
-
-		err := r.validateRobot(projID, robotID)
+		projID, err := r.createProject(harbor, name, settings)
 		if err != nil {
-			log.Error(err, "annotation 'robotID'  is invalid", "robotID", robotID)
-			return "", "", "", fmt.Errorf("robotID is invalid: %w", err)
+			log.Error(err, "Failed creating project", "project", name)
+			return "", "", err
 		}
+		return name, projID, nil
+	}
+
+	projID, err := r.validateProject(harbor, proj)
+	if err != nil {
+		log.Error(err, "Harbor annotation for project is invalid", "project", proj)
+		return "", "", fmt.Errorf("project are invalid: %w", err)
 	}
 
-	return proj, projID, robotID, nil
+	return proj, projID, nil
 }
@@ -0,0 +1,348 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// nsFinalizer keeps the namespace until the deletion policy of its project is fulfilled
+	nsFinalizer = "ns.finalizers.resource.goharbor.io"
+	// archivedLabel is the project level label attached to the artifacts of the project of the deleted namespace
+	archivedLabel = "archived"
+	// namespaceFinalizeTimeout is how long the deletion policy is retried before the namespace is released without it
+	namespaceFinalizeTimeout = time.Hour
+)
+
+// namespaceDeletionPolicy returns the deletion policy of the project of the namespace,
+// the policy of the harbor server configuration is overridden by the namespace annotation
+func namespaceDeletionPolicy(hsc *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) (string, error) {
+	if policy, ok := ns.Annotations[utils.AnnotationNamespaceDeletionPolicy]; ok {
+		if !utils.ContainsString(goharborv1alpha1.NamespaceDeletionPolicies, policy) {
+			return "", fmt.Errorf("invalid annotation %s: %q is not one of %s", utils.AnnotationNamespaceDeletionPolicy, policy, strings.Join(goharborv1alpha1.NamespaceDeletionPolicies, ", "))
+		}
+
+		return policy, nil
+	}
+
+	if len(hsc.Spec.NamespaceDeletionPolicy) == 0 {
+		return goharborv1alpha1.NamespaceDeletionPolicyRetain, nil
+	}
+
+	return hsc.Spec.NamespaceDeletionPolicy, nil
+}
+
+// ensureNamespaceFinalizer adds the finalizer to the namespace if its project should be handled when it is deleted,
+// otherwise the finalizer is removed
+func (r *NamespaceReconciler) ensureNamespaceFinalizer(ctx context.Context, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) error {
+	policy, err := namespaceDeletionPolicy(harborCfg, ns)
+	if err != nil {
+		return err
+	}
+
+	required := policy != goharborv1alpha1.NamespaceDeletionPolicyRetain
+	if required == utils.ContainsString(ns.Finalizers, nsFinalizer) {
+		return nil
+	}
+
+	if required {
+		ns.Finalizers = append(ns.Finalizers, nsFinalizer)
+	} else {
+		ns.Finalizers = utils.RemoveString(ns.Finalizers, nsFinalizer)
+	}
+
+	if err := r.Client.Update(ctx, ns, &client.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update finalizer of namespace error: %w", err)
+	}
+
+	return nil
+}
+
+// finalizeNamespace fulfills the deletion policy of the project of the namespace being deleted,
+// the finalizer is removed once it is done
+func (r *NamespaceReconciler) finalizeNamespace(ctx context.Context, log logr.Logger, ns *corev1.Namespace) (ctrl.Result, error) {
+	if !utils.ContainsString(ns.Finalizers, nsFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	proj := ns.Annotations[utils.AnnotationProject]
+	if ns.DeletionTimestamp != nil && time.Since(ns.DeletionTimestamp.Time) > namespaceFinalizeTimeout {
+		// Do not block the namespace deletion forever when the harbor server is broken
+		r.eventf(ns, corev1.EventTypeWarning, "DeletionPolicyTimeout", "deletion policy of project %s is not applied in %s, the namespace is released", proj, namespaceFinalizeTimeout)
+		return r.removeNamespaceFinalizer(ctx, ns)
+	}
+
+	harborCfg, err := r.findDefaultHarborCfg(ctx, log, ns)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error finding harborCfg: %w", err)
+	}
+
+	if harborCfg == nil || proj == "" || proj == "*" {
+		r.eventf(ns, corev1.EventTypeWarning, "ProjectRetained", "no harbor server or project is found, the project is retained")
+	} else {
+		policy, err := namespaceDeletionPolicy(harborCfg, ns)
+		if err != nil {
+			r.eventf(ns, corev1.EventTypeWarning, "InvalidDeletionPolicy", "%s", err)
+			policy = goharborv1alpha1.NamespaceDeletionPolicyRetain
+		}
+
+		if err := r.applyDeletionPolicy(ctx, log, harborCfg, ns, proj, policy); err != nil {
+			r.eventf(ns, corev1.EventTypeWarning, "DeletionPolicyFailed", "apply deletion policy %s to project %s error: %s", policy, proj, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return r.removeNamespaceFinalizer(ctx, ns)
+}
+
+// removeNamespaceFinalizer removes the finalizer to release the namespace
+func (r *NamespaceReconciler) removeNamespaceFinalizer(ctx context.Context, ns *corev1.Namespace) (ctrl.Result, error) {
+	ns.Finalizers = utils.RemoveString(ns.Finalizers, nsFinalizer)
+	if err := r.Client.Update(ctx, ns, &client.UpdateOptions{}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("remove finalizer of namespace error: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// applyDeletionPolicy handles the project of the deleted namespace with the policy and records the action in an event
+func (r *NamespaceReconciler) applyDeletionPolicy(ctx context.Context, log logr.Logger, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace, proj, policy string) error {
+	if policy == goharborv1alpha1.NamespaceDeletionPolicyRetain {
+		r.eventf(ns, corev1.EventTypeNormal, "ProjectRetained", "project %s is retained", proj)
+		return nil
+	}
+
+	harbor, err := r.getHarborClient(ctx, log, harborCfg)
+	if err != nil {
+		return err
+	}
+
+	projects, err := harbor.Projects()
+	if err != nil {
+		return err
+	}
+
+	p, err := projects.GetProject(proj)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			r.eventf(ns, corev1.EventTypeNormal, "ProjectNotFound", "project %s does not exist", proj)
+			return nil
+		}
+
+		return err
+	}
+	projectID := int64(p.ProjectID)
+
+	revoked, err := r.revokeNamespaceRobots(ctx, harbor, harborCfg, ns, projectID)
+	if err != nil {
+		return err
+	}
+
+	switch policy {
+	case goharborv1alpha1.NamespaceDeletionPolicyDeleteRobotsOnly:
+		r.eventf(ns, corev1.EventTypeNormal, "RobotsDeleted", "%d robot accounts of project %s are deleted", revoked, proj)
+	case goharborv1alpha1.NamespaceDeletionPolicyDeleteProjectIfEmpty:
+		deletable, reason, err := projects.IsProjectDeletable(projectID)
+		if err != nil {
+			return err
+		}
+
+		if !deletable {
+			r.eventf(ns, corev1.EventTypeNormal, "ProjectRetained", "%d robot accounts are deleted, project %s is retained as it is not empty: %s", revoked, proj, reason)
+			return nil
+		}
+
+		if err := projects.DeleteProject(proj); err != nil {
+			return err
+		}
+		r.eventf(ns, corev1.EventTypeNormal, "ProjectDeleted", "project %s is deleted", proj)
+	case goharborv1alpha1.NamespaceDeletionPolicyArchive:
+		demoted, limit, err := makeProjectReadOnly(harbor, projectID)
+		if err != nil {
+			return err
+		}
+
+		// Nothing can be pushed to the read-only project, so all its artifacts are labelled
+		labelled, err := labelArchivedArtifacts(harbor, ns, proj, projectID)
+		if err != nil {
+			return err
+		}
+		r.eventf(ns, corev1.EventTypeNormal, "ProjectArchived", "project %s is archived read-only, %d robot accounts are deleted, %d members are demoted to guest, "+
+			"the tags are immutable, the storage is limited to %d bytes and %d artifacts are labelled %s", proj, revoked, demoted, limit, labelled, archivedLabel)
+	}
+
+	return nil
+}
+
+// makeProjectReadOnly stops pushing to the project as harbor has no read-only projects: the members except the user of the operator
+// are demoted to guest, all the tags are made immutable and the storage is limited to the used storage, so neither the members
+// nor the robot accounts added later can push. It returns the number of the demoted members and the storage limit in bytes.
+func makeProjectReadOnly(harbor *harborClient.Clients, projectID int64) (int, int64, error) {
+	members, err := harbor.Members()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	demoted, err := demoteProjectMembers(members, projectID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	immutability, err := harbor.Immutability()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if _, err := immutability.EnsureProjectImmutable(projectID); err != nil {
+		return 0, 0, err
+	}
+
+	quotas, err := harbor.Quotas()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit, err := quotas.FreezeProjectStorage(projectID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return demoted, limit, nil
+}
+
+// labelArchivedArtifacts attaches the archived label to the artifacts of the read-only project as harbor can not label the projects,
+// it returns the number of the newly labelled artifacts
+func labelArchivedArtifacts(harbor *harborClient.Clients, ns *corev1.Namespace, proj string, projectID int64) (int, error) {
+	labels, err := harbor.Labels()
+	if err != nil {
+		return 0, err
+	}
+
+	deletedAt := time.Now()
+	if ns.DeletionTimestamp != nil {
+		deletedAt = ns.DeletionTimestamp.Time
+	}
+
+	desc := fmt.Sprintf("namespace %s was deleted at %s", ns.Name, deletedAt.UTC().Format(time.RFC3339))
+	labelID, err := labels.EnsureProjectLabel(projectID, archivedLabel, desc)
+	if err != nil {
+		return 0, err
+	}
+
+	artifacts, err := harbor.Artifacts()
+	if err != nil {
+		return 0, err
+	}
+
+	return artifacts.LabelProjectArtifacts(proj, labelID)
+}
+
+// eventf records the event of the namespace if the recorder is set
+func (r *NamespaceReconciler) eventf(ns *corev1.Namespace, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(ns, eventType, reason, messageFmt, args...)
+	}
+}
+
+// revokeNamespaceRobots deletes the robot accounts of the namespace annotation and the pull secret bindings in the project,
+// it returns the number of the deleted robot accounts
+func (r *NamespaceReconciler) revokeNamespaceRobots(ctx context.Context, harbor *harborClient.Clients, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace, projectID int64) (int, error) {
+	robotIDs := make(map[int64]struct{})
+	if robotID := parseIntID(ns.Annotations[utils.AnnotationRobot]); robotID > 0 {
+		robotIDs[robotID] = struct{}{}
+	}
+
+	// The bindings may be deleted already with the namespace
+	bindings := &goharborv1alpha1.PullSecretBindingList{}
+	if err := r.Client.List(ctx, bindings, client.InNamespace(ns.Name)); err != nil {
+		return 0, fmt.Errorf("list bindings error: %w", err)
+	}
+
+	for _, bd := range bindings.Items {
+		if bd.Spec.HarborServerConfig != harborCfg.Name || parseIntID(bd.Spec.ProjectID) != projectID {
+			continue
+		}
+
+		if robotID := parseIntID(bd.Spec.RobotID); robotID > 0 {
+			robotIDs[robotID] = struct{}{}
+		}
+	}
+
+	if len(robotIDs) == 0 {
+		return 0, nil
+	}
+
+	robots, err := harbor.Robots()
+	if err != nil {
+		return 0, err
+	}
+
+	for robotID := range robotIDs {
+		if err := robots.DeleteRobotAccount(projectID, robotID); err != nil {
+			return 0, fmt.Errorf("delete robot account %d error: %w", robotID, err)
+		}
+	}
+
+	return len(robotIDs), nil
+}
+
+// demoteProjectMembers changes the roles of the project members except the user of the operator to guest,
+// it returns the number of the demoted members
+func demoteProjectMembers(members rest.MemberClient, projectID int64) (int, error) {
+	guest, _ := model.ProjectRoleID(model.RoleGuest)
+
+	current, err := members.ListProjectMembers(projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Keep the user of the operator, otherwise it can not manage the project anymore
+	user, err := members.GetCurrentUser()
+	if err != nil {
+		return 0, err
+	}
+	operator := user.Username
+
+	demoted := 0
+	for _, m := range current {
+		if m.RoleID == guest || (m.EntityType == model.MemberUser && m.EntityName == operator) {
+			continue
+		}
+
+		if err := members.UpdateProjectMemberRole(projectID, m.ID, guest); err != nil {
+			return demoted, err
+		}
+		demoted++
+	}
+
+	return demoted, nil
+}
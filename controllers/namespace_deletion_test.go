@@ -0,0 +1,341 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestFinalizeNamespace(t *testing.T) {
+	type testcase struct {
+		description    string
+		deletedAgo     time.Duration
+		recorder       bool
+		expectedReason string
+	}
+	tests := []testcase{
+		{
+			description:    "namespace is released once the deletion policy times out",
+			deletedAgo:     2 * namespaceFinalizeTimeout,
+			recorder:       true,
+			expectedReason: "DeletionPolicyTimeout",
+		},
+		{
+			description: "namespace is released on timeout without the recorder",
+			deletedAgo:  2 * namespaceFinalizeTimeout,
+		},
+		{
+			description:    "project is retained without the harbor server",
+			recorder:       true,
+			expectedReason: "ProjectRetained",
+		},
+		{
+			description: "project is retained without the harbor server and the recorder",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			deletedAt := metav1.NewTime(time.Now().Add(-tc.deletedAgo))
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "team",
+					Annotations:       map[string]string{utils.AnnotationProject: "team"},
+					Finalizers:        []string{nsFinalizer},
+					DeletionTimestamp: &deletedAt,
+				},
+			}
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), ns)
+			r := &NamespaceReconciler{Client: c, Log: logf.Log}
+			recorder := record.NewFakeRecorder(1)
+			if tc.recorder {
+				r.Recorder = recorder
+			}
+
+			_, err := r.finalizeNamespace(context.Background(), logf.Log, ns)
+			require.NoError(t, err)
+
+			got := &corev1.Namespace{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "team"}, got))
+			require.NotContains(t, got.Finalizers, nsFinalizer)
+
+			if tc.recorder {
+				require.Len(t, recorder.Events, 1)
+				require.Contains(t, <-recorder.Events, tc.expectedReason)
+			}
+		})
+	}
+}
+
+func TestLabelArchivedArtifacts(t *testing.T) {
+	type testcase struct {
+		description string
+		labels      []*models.Label
+		calls       []string
+		labelled    int
+	}
+	tests := []testcase{
+		{
+			description: "label is created and attached to the unlabelled artifacts",
+			calls: []string{
+				"POST /api/v2.0/labels",
+				"POST /api/v2.0/projects/team/repositories/app/artifacts/sha256:b/labels 7",
+				"POST /api/v2.0/projects/team/repositories/tools%2Fcli/artifacts/sha256:c/labels 7",
+			},
+			labelled: 1,
+		},
+		{
+			description: "existing label is attached to the unlabelled artifacts",
+			labels:      []*models.Label{{ID: 7, Name: archivedLabel}},
+			calls: []string{
+				"POST /api/v2.0/projects/team/repositories/app/artifacts/sha256:b/labels 7",
+				"POST /api/v2.0/projects/team/repositories/tools%2Fcli/artifacts/sha256:c/labels 7",
+			},
+			labelled: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body interface{}
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/labels":
+					body = tc.labels
+				case req.Method == http.MethodPost && req.URL.Path == "/api/v2.0/labels":
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+					w.Header().Set("Location", "/api/v2.0/labels/7")
+					w.WriteHeader(http.StatusCreated)
+					return
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/team/repositories":
+					body = []map[string]interface{}{{"name": "team/app"}, {"name": "team/tools/cli"}}
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/team/repositories/app/artifacts":
+					body = []map[string]interface{}{
+						{"digest": "sha256:a", "labels": []map[string]interface{}{{"id": 7, "name": archivedLabel}}},
+						{"digest": "sha256:b"},
+					}
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/team/repositories/tools%2Fcli/artifacts":
+					body = []map[string]interface{}{{"digest": "sha256:c"}}
+				case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/labels"):
+					l := &models.Label{}
+					require.NoError(t, json.NewDecoder(req.Body).Decode(l))
+					calls = append(calls, fmt.Sprintf("%s %s %d", req.Method, req.URL.Path, l.ID))
+					// Labelled by others in the meantime
+					if strings.Contains(req.URL.Path, "sha256:c") {
+						w.WriteHeader(http.StatusConflict)
+					}
+					return
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			deletedAt := metav1.Now()
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", DeletionTimestamp: &deletedAt}}
+
+			labelled, err := labelArchivedArtifacts(harbor, ns, "team", 1)
+			require.NoError(t, err)
+			require.Equal(t, tc.labelled, labelled)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func TestDemoteProjectMembers(t *testing.T) {
+	admin, _ := model.ProjectRoleID(model.RoleProjectAdmin)
+	developer, _ := model.ProjectRoleID(model.RoleDeveloper)
+	guest, _ := model.ProjectRoleID(model.RoleGuest)
+
+	type testcase struct {
+		description string
+		userErr     bool
+		calls       []string
+		demoted     int
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "members except the operator and the guests are demoted",
+			calls:       []string{"PUT /api/v2.0/projects/1/members/2", "PUT /api/v2.0/projects/1/members/4"},
+			demoted:     2,
+		},
+		{
+			description: "failure of reading the operator user stops the demotion",
+			userErr:     true,
+			calls:       []string{},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body interface{}
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/1/members":
+					body = []*models.ProjectMemberEntity{
+						{ID: 1, EntityType: model.MemberUser, EntityName: "operator", RoleID: admin},
+						{ID: 2, EntityType: model.MemberUser, EntityName: "alice", RoleID: developer},
+						{ID: 3, EntityType: model.MemberUser, EntityName: "bob", RoleID: guest},
+						{ID: 4, EntityType: model.MemberGroup, EntityName: "dev", RoleID: developer},
+					}
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/users/current":
+					if tc.userErr {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					body = map[string]string{"username": "operator"}
+				default:
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			members, err := harbor.Members()
+			require.NoError(t, err)
+
+			demoted, err := demoteProjectMembers(members, 1)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.demoted, demoted)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func TestMakeProjectReadOnly(t *testing.T) {
+	developer, _ := model.ProjectRoleID(model.RoleDeveloper)
+
+	type testcase struct {
+		description string
+		rules       []*models.RetentionRule
+		hard        int64
+		used        int64
+		calls       []string
+		limit       int64
+	}
+	allTags := &models.RetentionRule{
+		Action:       "immutable",
+		TagSelectors: []*models.RetentionSelector{{Kind: "doublestar", Decoration: "matches", Pattern: "**"}},
+		ScopeSelectors: map[string][]models.RetentionSelector{
+			"repository": {{Kind: "doublestar", Decoration: "repoMatches", Pattern: "**"}},
+		},
+	}
+	tests := []testcase{
+		{
+			description: "tags are made immutable and the storage is limited to the used storage",
+			rules:       []*models.RetentionRule{{Action: "immutable", TagSelectors: []*models.RetentionSelector{{Decoration: "matches", Pattern: "v*"}}}},
+			hard:        -1,
+			used:        1024,
+			calls: []string{
+				"PUT /api/v2.0/projects/1/members/2",
+				"POST /api/v2.0/projects/1/immutabletagrules",
+				"PUT /api/v2.0/quotas/5 1024",
+			},
+			limit: 1024,
+		},
+		{
+			description: "empty project is limited to one byte",
+			rules:       []*models.RetentionRule{allTags},
+			hard:        -1,
+			calls: []string{
+				"PUT /api/v2.0/projects/1/members/2",
+				"PUT /api/v2.0/quotas/5 1",
+			},
+			limit: 1,
+		},
+		{
+			description: "read-only project is not changed again",
+			rules:       []*models.RetentionRule{allTags},
+			hard:        1024,
+			used:        1024,
+			calls:       []string{"PUT /api/v2.0/projects/1/members/2"},
+			limit:       1024,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body interface{}
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/1/members":
+					body = []*models.ProjectMemberEntity{{ID: 2, EntityType: model.MemberUser, EntityName: "alice", RoleID: developer}}
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/users/current":
+					body = map[string]string{"username": "operator"}
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects/1/immutabletagrules":
+					body = tc.rules
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/quotas":
+					body = []*models.Quota{{ID: 5, Hard: models.ResourceList{"storage": tc.hard}, Used: models.ResourceList{"storage": tc.used}}}
+				case req.Method == http.MethodPut && req.URL.Path == "/api/v2.0/quotas/5":
+					q := &models.QuotaUpdateReq{}
+					require.NoError(t, json.NewDecoder(req.Body).Decode(q))
+					calls = append(calls, fmt.Sprintf("%s %s %d", req.Method, req.URL.Path, q.Hard["storage"]))
+					return
+				default:
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			demoted, limit, err := makeProjectReadOnly(harbor, 1)
+			require.NoError(t, err)
+			require.Equal(t, 1, demoted)
+			require.Equal(t, tc.limit, limit)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
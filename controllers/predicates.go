@@ -0,0 +1,115 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ignoredSecretTypes are the types of the secrets which are never referred by the harbor server configurations
+var ignoredSecretTypes = map[corev1.SecretType]bool{
+	corev1.SecretTypeServiceAccountToken: true,
+	corev1.SecretTypeDockerConfigJson:    true,
+	corev1.SecretTypeDockercfg:           true,
+	"helm.sh/release.v1":                 true,
+}
+
+// referredObjectChanged filters the events of the secrets and configmaps watched for the harbor server configurations.
+// The secrets of the ignored types are skipped, and the updates only changing the metadata or the status are skipped.
+func referredObjectChanged() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return !ignoredObject(e.Object)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return !ignoredObject(e.Object)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if ignoredObject(e.ObjectNew) {
+				return false
+			}
+
+			return !reflect.DeepEqual(objectData(e.ObjectOld), objectData(e.ObjectNew))
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return !ignoredObject(e.Object)
+		},
+	}
+}
+
+// imagePullSecretsChanged filters the events of the service accounts watched for the pull secret bindings.
+// The updates not changing the image pull secrets are skipped, e.g: the tokens added by the token controller.
+func imagePullSecretsChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSA, ok1 := e.ObjectOld.(*corev1.ServiceAccount)
+			newSA, ok2 := e.ObjectNew.(*corev1.ServiceAccount)
+			if !(ok1 && ok2) {
+				return true
+			}
+
+			return !reflect.DeepEqual(oldSA.ImagePullSecrets, newSA.ImagePullSecrets)
+		},
+	}
+}
+
+// namespaceSubjectsChanged filters the events of the service accounts and role bindings watched for the namespaces.
+// The creations and deletions are passed, the updates are only passed if they may change the service accounts selected
+// by the namespace or the members of its project. The other updates are skipped, e.g: the image pull secrets added by
+// the pull secret bindings and the tokens added by the token controller.
+func namespaceSubjectsChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			switch o := e.ObjectOld.(type) {
+			case *corev1.ServiceAccount:
+				n, ok := e.ObjectNew.(*corev1.ServiceAccount)
+				return !ok || !reflect.DeepEqual(o.Labels, n.Labels)
+			case *rbacv1.RoleBinding:
+				n, ok := e.ObjectNew.(*rbacv1.RoleBinding)
+				return !ok || !reflect.DeepEqual(o.RoleRef, n.RoleRef) || !reflect.DeepEqual(o.Subjects, n.Subjects)
+			default:
+				return true
+			}
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
+
+func ignoredObject(obj runtime.Object) bool {
+	sec, ok := obj.(*corev1.Secret)
+	return ok && ignoredSecretTypes[sec.Type]
+}
+
+// objectData returns the content of the secret or configmap which may be referred by the harbor server configurations
+func objectData(obj runtime.Object) interface{} {
+	switch o := obj.(type) {
+	case *corev1.Secret:
+		return []interface{}{o.Type, o.Data, o.StringData}
+	case *corev1.ConfigMap:
+		return []interface{}{o.Data, o.BinaryData}
+	default:
+		return obj
+	}
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReferredObjectChanged(t *testing.T) {
+	secret := func(secretType corev1.SecretType, value, resourceVersion string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "secret", ResourceVersion: resourceVersion},
+			Type:       secretType,
+			Data:       map[string][]byte{"accessSecret": []byte(value)},
+		}
+	}
+	configMap := func(value, resourceVersion string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", ResourceVersion: resourceVersion},
+			Data:       map[string]string{"ca.crt": value},
+		}
+	}
+
+	type testcase struct {
+		description string
+		old         runtime.Object
+		new         runtime.Object
+		expected    bool
+	}
+	tests := []testcase{
+		{
+			description: "changed secret data",
+			old:         secret(corev1.SecretTypeOpaque, "a", "1"),
+			new:         secret(corev1.SecretTypeOpaque, "b", "2"),
+			expected:    true,
+		},
+		{
+			description: "secret metadata only",
+			old:         secret(corev1.SecretTypeOpaque, "a", "1"),
+			new:         secret(corev1.SecretTypeOpaque, "a", "2"),
+		},
+		{
+			description: "service account token secret",
+			old:         secret(corev1.SecretTypeServiceAccountToken, "a", "1"),
+			new:         secret(corev1.SecretTypeServiceAccountToken, "b", "2"),
+		},
+		{
+			description: "changed configmap data",
+			old:         configMap("a", "1"),
+			new:         configMap("b", "2"),
+			expected:    true,
+		},
+		{
+			description: "configmap metadata only",
+			old:         configMap("a", "1"),
+			new:         configMap("a", "2"),
+		},
+	}
+
+	p := referredObjectChanged()
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new}))
+		})
+	}
+
+	require.True(t, p.Create(event.CreateEvent{Object: secret(corev1.SecretTypeBasicAuth, "a", "1")}))
+	require.False(t, p.Create(event.CreateEvent{Object: secret("helm.sh/release.v1", "a", "1")}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: configMap("a", "1")}))
+}
+
+func TestImagePullSecretsChanged(t *testing.T) {
+	sa := func(resourceVersion string, pullSecrets ...string) *corev1.ServiceAccount {
+		obj := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "demo", ResourceVersion: resourceVersion},
+			Secrets:    []corev1.ObjectReference{{Name: "default-token-" + resourceVersion}},
+		}
+		for _, name := range pullSecrets {
+			obj.ImagePullSecrets = append(obj.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+		return obj
+	}
+
+	type testcase struct {
+		description string
+		old         runtime.Object
+		new         runtime.Object
+		expected    bool
+	}
+	tests := []testcase{
+		{
+			description: "added image pull secret",
+			old:         sa("1"),
+			new:         sa("2", "regsecret"),
+			expected:    true,
+		},
+		{
+			description: "removed image pull secret",
+			old:         sa("1", "regsecret", "other"),
+			new:         sa("2", "other"),
+			expected:    true,
+		},
+		{
+			description: "token only",
+			old:         sa("1", "regsecret"),
+			new:         sa("2", "regsecret"),
+		},
+	}
+
+	p := imagePullSecretsChanged()
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new}))
+		})
+	}
+
+	require.True(t, p.Create(event.CreateEvent{Object: sa("1")}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: sa("1", "regsecret")}))
+}
+
+func TestNamespaceSubjectsChanged(t *testing.T) {
+	sa := func(resourceVersion string, labels map[string]string, pullSecrets ...string) *corev1.ServiceAccount {
+		obj := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "builder", Namespace: "demo", ResourceVersion: resourceVersion, Labels: labels},
+			Secrets:    []corev1.ObjectReference{{Name: "builder-token-" + resourceVersion}},
+		}
+		for _, name := range pullSecrets {
+			obj.ImagePullSecrets = append(obj.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+		return obj
+	}
+	rb := func(resourceVersion, role string, users ...string) *rbacv1.RoleBinding {
+		obj := &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs", Namespace: "demo", ResourceVersion: resourceVersion},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+		}
+		for _, name := range users {
+			obj.Subjects = append(obj.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, Name: name})
+		}
+		return obj
+	}
+	selected := map[string]string{"pull": "harbor"}
+
+	type testcase struct {
+		description string
+		old         runtime.Object
+		new         runtime.Object
+		expected    bool
+	}
+	tests := []testcase{
+		{
+			description: "service account is selected",
+			old:         sa("1", nil),
+			new:         sa("2", selected),
+			expected:    true,
+		},
+		{
+			description: "service account is unselected",
+			old:         sa("1", selected),
+			new:         sa("2", nil),
+			expected:    true,
+		},
+		{
+			description: "image pull secret is added",
+			old:         sa("1", selected),
+			new:         sa("2", selected, "regsecret"),
+		},
+		{
+			description: "token is added",
+			old:         sa("1", selected, "regsecret"),
+			new:         sa("2", selected, "regsecret"),
+		},
+		{
+			description: "role binding subject is added",
+			old:         rb("1", "edit", "alice"),
+			new:         rb("2", "edit", "alice", "bob"),
+			expected:    true,
+		},
+		{
+			description: "role binding role is changed",
+			old:         rb("1", "edit", "alice"),
+			new:         rb("2", "view", "alice"),
+			expected:    true,
+		},
+		{
+			description: "role binding metadata only",
+			old:         rb("1", "edit", "alice"),
+			new:         rb("2", "edit", "alice"),
+		},
+	}
+
+	p := namespaceSubjectsChanged()
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.Update(event.UpdateEvent{ObjectOld: tc.old, ObjectNew: tc.new}))
+		})
+	}
+
+	require.True(t, p.Create(event.CreateEvent{Object: sa("1", nil)}))
+	require.True(t, p.Delete(event.DeleteEvent{Object: rb("1", "edit", "alice")}))
+}
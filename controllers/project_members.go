@@ -0,0 +1,237 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// memberKey identifies the user or group member of the project
+type memberKey struct {
+	entityType string
+	name       string
+}
+
+// memberRoles returns the mappings from the kubernetes roles to the harbor project role IDs,
+// the mappings of the harbor server configuration are replaced by the namespace annotation
+func memberRoles(hsc *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) (map[string]int64, error) {
+	mappings := hsc.Spec.ProjectMemberRoles
+	if value, ok := ns.Annotations[utils.AnnotationProjectMemberRoles]; ok {
+		mappings = make(map[string]string)
+		for _, pair := range strings.Split(value, ",") {
+			if pair = strings.TrimSpace(pair); len(pair) == 0 {
+				continue
+			}
+
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid annotation %s: %q is not in the format role=projectRole", utils.AnnotationProjectMemberRoles, pair)
+			}
+			mappings[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	roles := make(map[string]int64, len(mappings))
+	for role, projectRole := range mappings {
+		kind, name, err := goharborv1alpha1.ParseMemberRole(role)
+		if err != nil {
+			return nil, err
+		}
+
+		id, ok := model.ProjectRoleID(projectRole)
+		if !ok {
+			return nil, fmt.Errorf("role %s is mapped to invalid project role %q", role, projectRole)
+		}
+		roles[roleRefKey(kind, name)] = id
+	}
+
+	return roles, nil
+}
+
+// roleRefKey is the key of the role in the mappings returned by memberRoles
+func roleRefKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// parseMembers parses the members recorded in the annotation of the namespace
+func parseMembers(value string) map[memberKey]bool {
+	members := make(map[memberKey]bool)
+	for _, m := range strings.Split(value, ",") {
+		kv := strings.SplitN(strings.TrimSpace(m), ":", 2)
+		if len(kv) == 2 && len(kv[1]) > 0 {
+			members[memberKey{entityType: kv[0], name: kv[1]}] = true
+		}
+	}
+
+	return members
+}
+
+// formatMembers formats the members to be recorded in the annotation of the namespace, the members are sorted
+func formatMembers(members map[memberKey]bool) string {
+	items := make([]string, 0, len(members))
+	for m := range members {
+		items = append(items, m.entityType+":"+m.name)
+	}
+	sort.Strings(items)
+
+	return strings.Join(items, ",")
+}
+
+// desiredMembers returns the users and groups bound to the mapped roles, the subject bound to multiple roles gets the highest one.
+// The groups are skipped if they are not supported by the auth mode.
+func desiredMembers(roles map[string]int64, bindings []rbacv1.RoleBinding, withGroups bool) map[memberKey]int64 {
+	members := make(map[memberKey]int64)
+	for _, rb := range bindings {
+		roleID, ok := roles[roleRefKey(rb.RoleRef.Kind, rb.RoleRef.Name)]
+		if !ok {
+			continue
+		}
+
+		for _, subject := range rb.Subjects {
+			var key memberKey
+			switch {
+			case subject.Kind == rbacv1.UserKind:
+				key = memberKey{entityType: model.MemberUser, name: subject.Name}
+			case subject.Kind == rbacv1.GroupKind && withGroups:
+				key = memberKey{entityType: model.MemberGroup, name: subject.Name}
+			default:
+				continue
+			}
+
+			if current, ok := members[key]; ok {
+				members[key] = model.HigherRole(current, roleID)
+				continue
+			}
+			members[key] = roleID
+		}
+	}
+
+	return members
+}
+
+// syncProjectMembers keeps the members of the project matching the users and groups bound to the mapped roles in the namespace.
+// The members added by the operator are recorded in the namespace annotation, only they are removed once no longer bound.
+// The group members are left as they are if the groups are not supported by the auth mode.
+func (r *NamespaceReconciler) syncProjectMembers(ctx context.Context, log logr.Logger, harbor *harborClient.Clients, harborCfg *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace, projectName string, roles map[string]int64) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	bindings := &rbacv1.RoleBindingList{}
+	if err := r.Client.List(ctx, bindings, client.InNamespace(ns.Name)); err != nil {
+		return fmt.Errorf("list role bindings error: %w", err)
+	}
+
+	var groupType int64
+	withGroups := false
+	if info := harborCfg.Status.ServerInfo; info != nil {
+		groupType, withGroups = model.GroupType(info.AuthMode)
+	}
+	desired := desiredMembers(roles, bindings.Items, withGroups)
+
+	projects, err := harbor.Projects()
+	if err != nil {
+		return err
+	}
+
+	members, err := harbor.Members()
+	if err != nil {
+		return err
+	}
+
+	proj, err := projects.GetProject(projectName)
+	if err != nil {
+		return err
+	}
+	projectID := int64(proj.ProjectID)
+
+	current, err := members.ListProjectMembers(projectID)
+	if err != nil {
+		return err
+	}
+
+	// The user of the operator keeps its membership to manage the project
+	user, err := members.GetCurrentUser()
+	if err != nil {
+		return fmt.Errorf("get current user error: %w", err)
+	}
+	delete(desired, memberKey{entityType: model.MemberUser, name: user.Username})
+
+	// Only the members added by the operator are removed, the ones added in Harbor are left as they are
+	managed := parseMembers(ns.Annotations[utils.AnnotationProjectMembers])
+	synced := make(map[memberKey]bool, len(desired))
+	for _, m := range current {
+		key := memberKey{entityType: m.EntityType, name: m.EntityName}
+		roleID, ok := desired[key]
+		switch {
+		case ok && roleID != m.RoleID:
+			log.Info("update project member role", "project", projectName, "member", m.EntityName, "roleID", roleID)
+			if err := members.UpdateProjectMemberRole(projectID, m.ID, roleID); err != nil {
+				return err
+			}
+		case !ok && key.entityType == model.MemberGroup && !withGroups:
+		case !ok && managed[key]:
+			log.Info("remove project member", "project", projectName, "member", m.EntityName)
+			if err := members.DeleteProjectMember(projectID, m.ID); err != nil {
+				return err
+			}
+		}
+
+		if ok {
+			synced[key] = true
+		}
+		delete(desired, key)
+	}
+
+	for key, roleID := range desired {
+		log.Info("add project member", "project", projectName, "member", key.name, "roleID", roleID)
+		if err := members.AddProjectMember(projectID, &model.ProjectMember{EntityType: key.entityType, Name: key.name, RoleID: roleID}, groupType); err != nil {
+			// The user may not be onboarded to Harbor yet, retry in the next sync
+			log.Error(err, "failed to add project member", "project", projectName, "member", key.name)
+			continue
+		}
+		synced[key] = true
+	}
+
+	// The group members are kept managed if the groups are not supported by the auth mode
+	for key := range managed {
+		if key.entityType == model.MemberGroup && !withGroups {
+			synced[key] = true
+		}
+	}
+
+	if value := formatMembers(synced); value != ns.Annotations[utils.AnnotationProjectMembers] {
+		ns.Annotations[utils.AnnotationProjectMembers] = value
+		if err := r.Client.Update(ctx, ns, &client.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update managed project members error: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestMemberRoles(t *testing.T) {
+	admin, _ := model.ProjectRoleID(model.RoleProjectAdmin)
+	developer, _ := model.ProjectRoleID(model.RoleDeveloper)
+
+	type testcase struct {
+		description string
+		mappings    map[string]string
+		annotation  string
+		expected    map[string]int64
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "role without kind is a cluster role",
+			mappings:    map[string]string{"admin": model.RoleProjectAdmin, "Role/deployer": model.RoleDeveloper},
+			expected:    map[string]int64{"ClusterRole/admin": admin, "Role/deployer": developer},
+		},
+		{
+			description: "annotation replaces the mappings",
+			mappings:    map[string]string{"admin": model.RoleProjectAdmin},
+			annotation:  "ClusterRole/edit=developer, Role/deployer=developer",
+			expected:    map[string]int64{"ClusterRole/edit": developer, "Role/deployer": developer},
+		},
+		{
+			description: "invalid kind",
+			annotation:  "RoleBinding/admin=projectAdmin",
+			expectedErr: true,
+		},
+		{
+			description: "invalid project role",
+			mappings:    map[string]string{"admin": "owner"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			hsc := &goharborv1alpha1.HarborServerConfiguration{}
+			hsc.Spec.ProjectMemberRoles = tc.mappings
+			ns := &corev1.Namespace{}
+			if len(tc.annotation) > 0 {
+				ns.Annotations = map[string]string{utils.AnnotationProjectMemberRoles: tc.annotation}
+			}
+
+			roles, err := memberRoles(hsc, ns)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, roles)
+		})
+	}
+}
+
+func TestDesiredMembers(t *testing.T) {
+	admin, _ := model.ProjectRoleID(model.RoleProjectAdmin)
+	guest, _ := model.ProjectRoleID(model.RoleGuest)
+	roles := map[string]int64{"ClusterRole/admin": admin, "ClusterRole/view": guest}
+
+	bindings := []rbacv1.RoleBinding{
+		roleBinding("admins", rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"}, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"}),
+		roleBinding("viewers", rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"},
+			rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"}, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "dev"}),
+		// A Role with the same name as the mapped ClusterRole can be created in the namespace
+		roleBinding("fake-admins", rbacv1.RoleRef{Kind: "Role", Name: "admin"}, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "mallory"}),
+		roleBinding("sa", rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"}, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "default"}),
+	}
+
+	require.Equal(t, map[memberKey]int64{
+		{entityType: model.MemberUser, name: "alice"}: admin,
+	}, desiredMembers(roles, bindings, false))
+
+	require.Equal(t, map[memberKey]int64{
+		{entityType: model.MemberUser, name: "alice"}: admin,
+		{entityType: model.MemberGroup, name: "dev"}:  guest,
+	}, desiredMembers(roles, bindings, true))
+}
+
+func TestSyncProjectMembers(t *testing.T) {
+	admin, _ := model.ProjectRoleID(model.RoleProjectAdmin)
+	developer, _ := model.ProjectRoleID(model.RoleDeveloper)
+
+	type testcase struct {
+		description string
+		current     []*models.ProjectMemberEntity
+		managed     string
+		userErr     bool
+		calls       []string
+		annotation  string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "bound user is added",
+			current: []*models.ProjectMemberEntity{
+				{ID: 1, EntityType: model.MemberUser, EntityName: "operator", RoleID: admin},
+			},
+			calls:      []string{"POST /members alice"},
+			annotation: "u:alice",
+		},
+		{
+			description: "role of the bound user is updated",
+			current: []*models.ProjectMemberEntity{
+				{ID: 1, EntityType: model.MemberUser, EntityName: "operator", RoleID: admin},
+				{ID: 2, EntityType: model.MemberUser, EntityName: "alice", RoleID: admin},
+			},
+			managed:    "u:alice",
+			calls:      []string{"PUT /members/2"},
+			annotation: "u:alice",
+		},
+		{
+			description: "only the unbound members added by the operator are removed",
+			current: []*models.ProjectMemberEntity{
+				{ID: 1, EntityType: model.MemberUser, EntityName: "operator", RoleID: admin},
+				{ID: 2, EntityType: model.MemberUser, EntityName: "alice", RoleID: developer},
+				{ID: 3, EntityType: model.MemberUser, EntityName: "bob", RoleID: developer},
+				{ID: 4, EntityType: model.MemberUser, EntityName: "carol", RoleID: developer},
+				{ID: 5, EntityType: model.MemberGroup, EntityName: "dev", RoleID: developer},
+			},
+			managed:    "u:alice,u:bob,g:dev",
+			calls:      []string{"DELETE /members/3"},
+			annotation: "g:dev,u:alice",
+		},
+		{
+			description: "failure of reading the operator user stops the sync",
+			current: []*models.ProjectMemberEntity{
+				{ID: 1, EntityType: model.MemberUser, EntityName: "operator", RoleID: admin},
+				{ID: 3, EntityType: model.MemberUser, EntityName: "bob", RoleID: developer},
+			},
+			managed:     "u:bob",
+			userErr:     true,
+			annotation:  "u:bob",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				path := strings.TrimPrefix(req.URL.Path, "/api/v2.0/projects/1")
+				var body interface{}
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects":
+					body = []map[string]interface{}{{"project_id": 1, "name": "team"}}
+				case req.Method == http.MethodGet && path == "/members":
+					body = tc.current
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/users/current":
+					if tc.userErr {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					body = map[string]string{"username": "operator"}
+				case req.Method == http.MethodPost:
+					pm := &models.ProjectMember{}
+					require.NoError(t, json.NewDecoder(req.Body).Decode(pm))
+					calls = append(calls, fmt.Sprintf("%s %s %s", req.Method, path, pm.MemberUser.Username))
+					w.WriteHeader(http.StatusCreated)
+					return
+				default:
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, path))
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "team",
+					Annotations: map[string]string{utils.AnnotationProject: "team", utils.AnnotationProjectMembers: tc.managed},
+				},
+			}
+			rb := roleBinding("developers", rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"},
+				rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice"}, rbacv1.Subject{Kind: rbacv1.UserKind, Name: "operator"})
+			c := fake.NewFakeClient(ns, &rb)
+			r := &NamespaceReconciler{Client: c, Log: logf.Log}
+			hsc := &goharborv1alpha1.HarborServerConfiguration{}
+			hsc.Status.ServerInfo = &goharborv1alpha1.ServerInfo{AuthMode: "db_auth"}
+
+			err := r.syncProjectMembers(context.Background(), logf.Log, harbor, hsc, ns, "team", map[string]int64{"ClusterRole/edit": developer})
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.ElementsMatch(t, tc.calls, calls)
+
+			got := &corev1.Namespace{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Name: "team"}, got))
+			require.Equal(t, tc.annotation, got.Annotations[utils.AnnotationProjectMembers])
+		})
+	}
+}
+
+func roleBinding(name string, ref rbacv1.RoleRef, subjects ...rbacv1.Subject) rbacv1.RoleBinding {
+	return rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team"},
+		RoleRef:    ref,
+		Subjects:   subjects,
+	}
+}
@@ -0,0 +1,106 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// severities are the valid vulnerability severity thresholds of the harbor projects
+var severities = []string{"none", "low", "medium", "high", "critical"}
+
+// projectSettings returns the project settings of the namespace, the settings of the harbor server configuration
+// are overridden by the namespace annotations
+func projectSettings(hsc *goharborv1alpha1.HarborServerConfiguration, ns *corev1.Namespace) (*model.ProjectSettings, error) {
+	settings := &model.ProjectSettings{}
+	if defaults := hsc.Spec.ProjectSettings; defaults != nil {
+		settings.Public = defaults.Public
+		settings.AutoScan = defaults.AutoScan
+		settings.PreventVul = defaults.PreventVulnerableImages
+		settings.EnableContentTrust = defaults.EnableContentTrust
+		settings.CVEAllowlist = defaults.CVEAllowlist
+		if len(defaults.Severity) > 0 {
+			severity := defaults.Severity
+			settings.Severity = &severity
+		}
+		if defaults.StorageLimit != nil {
+			limit := defaults.StorageLimit.Value()
+			settings.StorageLimit = &limit
+		}
+	}
+
+	var err error
+	for annotation, setting := range map[string]**bool{
+		utils.AnnotationProjectPublic:       &settings.Public,
+		utils.AnnotationProjectAutoScan:     &settings.AutoScan,
+		utils.AnnotationProjectPreventVul:   &settings.PreventVul,
+		utils.AnnotationProjectContentTrust: &settings.EnableContentTrust,
+	} {
+		if *setting, err = boolAnnotation(ns, annotation, *setting); err != nil {
+			return nil, err
+		}
+	}
+
+	if severity, ok := ns.Annotations[utils.AnnotationProjectSeverity]; ok {
+		if !utils.ContainsString(severities, severity) {
+			return nil, fmt.Errorf("invalid annotation %s: %q is not one of %s", utils.AnnotationProjectSeverity, severity, strings.Join(severities, ", "))
+		}
+		settings.Severity = &severity
+	}
+
+	if allowlist, ok := ns.Annotations[utils.AnnotationProjectCVEAllowlist]; ok {
+		settings.CVEAllowlist = make([]string, 0)
+		for _, id := range strings.Split(allowlist, ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				settings.CVEAllowlist = append(settings.CVEAllowlist, id)
+			}
+		}
+	}
+
+	if limit, ok := ns.Annotations[utils.AnnotationProjectStorageLimit]; ok {
+		q, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", utils.AnnotationProjectStorageLimit, err)
+		}
+		value := q.Value()
+		settings.StorageLimit = &value
+	}
+
+	return settings, nil
+}
+
+func boolAnnotation(ns *corev1.Namespace, annotation string, defaultValue *bool) (*bool, error) {
+	value, ok := ns.Annotations[annotation]
+	if !ok {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %w", annotation, err)
+	}
+
+	return &b, nil
+}
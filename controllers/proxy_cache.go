@@ -0,0 +1,173 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/kstatus/status"
+)
+
+// proxyCachesProvisioned condition reports the state of the registry endpoints and proxy-cache projects declared by the configuration
+const proxyCachesProvisioned = "ProxyCachesProvisioned"
+
+// provisionProxyCaches ensures the registry endpoint and the proxy-cache project of each declared proxy cache exist in the harbor server,
+// the provisioned proxy caches are recorded in the status with their rewrite rules.
+// The proxy cache failed to provision keeps its last status, so the images are still rewritten to the existing project.
+func (r *HarborServerConfigurationReconciler) provisionProxyCaches(ctx context.Context, log logr.Logger, hsc *goharborv1alpha1.HarborServerConfiguration, st *goharborv1alpha1.HarborServerConfigurationStatus) goharborv1alpha1.Condition {
+	cond := goharborv1alpha1.Condition{
+		Type:   status.ConditionType(proxyCachesProvisioned),
+		Status: corev1.ConditionFalse,
+	}
+
+	harbor, err := harborClient.CreateHarborClients(ctx, r.Client, hsc)
+	if err != nil {
+		cond.Reason = "ClientError"
+		cond.Message = fmt.Sprintf("create harbor clients error: %s", err)
+		st.ProxyCaches = hsc.Status.ProxyCaches
+		return cond
+	}
+
+	registries, err := harbor.Registries()
+	if err != nil {
+		cond.Reason = "NotSupported"
+		cond.Message = err.Error()
+		st.ProxyCaches = hsc.Status.ProxyCaches
+		return cond
+	}
+
+	projects, err := harbor.Projects()
+	if err != nil {
+		cond.Reason = "NotSupported"
+		cond.Message = err.Error()
+		st.ProxyCaches = hsc.Status.ProxyCaches
+		return cond
+	}
+
+	provisioned := make([]goharborv1alpha1.ProxyCacheStatus, 0, len(hsc.Spec.ProxyCaches))
+	failures := make([]string, 0)
+	for i := range hsc.Spec.ProxyCaches {
+		pc := &hsc.Spec.ProxyCaches[i]
+		pcs, err := r.provisionProxyCache(ctx, registries, projects, pc, proxyCacheStatusOf(hsc.Status.ProxyCaches, pc.Name))
+		if err != nil {
+			log.Error(err, "failed to provision proxy cache", "proxyCache", pc.Name)
+			failures = append(failures, fmt.Sprintf("%s: %s", pc.Name, err))
+
+			if last := proxyCacheStatusOf(hsc.Status.ProxyCaches, pc.Name); last != nil {
+				provisioned = append(provisioned, *last)
+			}
+			continue
+		}
+
+		provisioned = append(provisioned, *pcs)
+	}
+	st.ProxyCaches = provisioned
+
+	if len(failures) > 0 {
+		cond.Reason = "ProvisionFailed"
+		cond.Message = strings.Join(failures, "; ")
+		return cond
+	}
+
+	cond.Status = corev1.ConditionTrue
+	cond.Reason = "Provisioned"
+	cond.Message = fmt.Sprintf("%d proxy caches are provisioned", len(provisioned))
+
+	return cond
+}
+
+// provisionProxyCache checks the upstream registry can be reached, then ensures its registry endpoint and proxy-cache project exist.
+// The credential is updated to the registry endpoint if its hash differs from the one in the last status.
+func (r *HarborServerConfigurationReconciler) provisionProxyCache(ctx context.Context, registries rest.RegistryClient, projects rest.ProjectClient, pc *goharborv1alpha1.ProxyCache, last *goharborv1alpha1.ProxyCacheStatus) (*goharborv1alpha1.ProxyCacheStatus, error) {
+	reg := &model.Registry{
+		Name:     pc.Name,
+		Type:     pc.Type,
+		URL:      pc.URL,
+		Insecure: pc.Insecure,
+	}
+
+	if pc.Credential != nil {
+		sec := &corev1.Secret{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Namespace: pc.Credential.Namespace, Name: pc.Credential.SecretRef}, sec); err != nil {
+			return nil, fmt.Errorf("get registry credential error: %w", err)
+		}
+
+		cred := &model.AccessCred{Type: goharborv1alpha1.AccessCredentialTypeBasic}
+		if err := cred.FillIn(sec); err != nil {
+			return nil, fmt.Errorf("fill in registry credential error: %w", err)
+		}
+
+		reg.AccessKey = cred.AccessKey
+		reg.AccessSecret = cred.AccessSecret
+	}
+
+	credentialHash := registryCredentialHash(reg)
+	reg.CredentialChanged = last == nil || last.CredentialHash != credentialHash
+
+	if err := registries.PingRegistry(reg); err != nil {
+		return nil, err
+	}
+
+	registryID, err := registries.EnsureRegistry(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID, err := projects.EnsureProxyCacheProject(pc.GetProject(), registryID, pc.IsPublic())
+	if err != nil {
+		return nil, err
+	}
+
+	return &goharborv1alpha1.ProxyCacheStatus{
+		Name:           pc.Name,
+		RegistryID:     registryID,
+		CredentialHash: credentialHash,
+		ProjectID:      projectID,
+		Rule:           pc.Rule(),
+	}, nil
+}
+
+// registryCredentialHash returns the hash of the credential of the registry endpoint, empty if it is accessed anonymously.
+// It is kept in the status as harbor masks the secret of the endpoint.
+func registryCredentialHash(reg *model.Registry) string {
+	if len(reg.AccessKey) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join([]string{reg.Name, reg.AccessKey, reg.AccessSecret}, "\x00"))))
+}
+
+// proxyCacheStatusOf returns the status of the proxy cache with the name
+func proxyCacheStatusOf(caches []goharborv1alpha1.ProxyCacheStatus, name string) *goharborv1alpha1.ProxyCacheStatus {
+	for i := range caches {
+		if caches[i].Name == name {
+			return &caches[i]
+		}
+	}
+
+	return nil
+}
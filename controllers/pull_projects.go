@@ -0,0 +1,197 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pullProjects returns the additional projects declared on the namespace, the project of the namespace is excluded
+func pullProjects(ns *corev1.Namespace, project string) []string {
+	projects := make([]string, 0)
+	for _, name := range strings.Split(ns.Annotations[utils.AnnotationPullProjects], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 && name != project && !utils.ContainsString(projects, name) {
+			projects = append(projects, name)
+		}
+	}
+
+	return projects
+}
+
+// syncBindingPullProjects updates the additional projects of the bindings created for the namespace
+func (r *NamespaceReconciler) syncBindingPullProjects(ctx context.Context, log logr.Logger, ns *corev1.Namespace, harborCfg string, bindings *goharborv1alpha1.PullSecretBindingList) error {
+	projects := pullProjects(ns, ns.Annotations[utils.AnnotationProject])
+	for i := range bindings.Items {
+		bd := &bindings.Items[i]
+		if bd.Spec.HarborServerConfig != harborCfg || !metav1.IsControlledBy(bd, ns) {
+			continue
+		}
+
+		if len(bd.Spec.PullProjects) == 0 && len(projects) == 0 || reflect.DeepEqual(bd.Spec.PullProjects, projects) {
+			continue
+		}
+
+		log.Info("update pull projects of binding", "binding", bd.Name, "projects", projects)
+		bd.Spec.PullProjects = projects
+		if err := r.Client.Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+			return fmt.Errorf("update binding %s error: %w", bd.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// syncPullRobots keeps a pull-only robot account in each additional project of the binding and merges their credentials into the pull secret.
+// The images of the additional projects are matched by the auth entries of the registry paths of the projects.
+func (r *PullSecretBindingReconciler) syncPullRobots(ctx context.Context, harbor *harborClient.Clients, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding) error {
+	stale := make([]goharborv1alpha1.PullRobot, 0)
+	pullRobots := make([]goharborv1alpha1.PullRobot, 0, len(bd.Spec.PullProjects))
+	for _, pr := range bd.Status.PullRobots {
+		if utils.ContainsString(bd.Spec.PullProjects, pr.Project) {
+			pullRobots = append(pullRobots, pr)
+		} else {
+			stale = append(stale, pr)
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, project := range bd.Spec.PullProjects {
+		if pullRobotOfProject(pullRobots, project) == nil && !utils.ContainsString(missing, project) {
+			missing = append(missing, project)
+		}
+	}
+
+	if len(stale) == 0 && len(missing) == 0 {
+		return nil
+	}
+
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		return fmt.Errorf("get registry secret error: %w", err)
+	}
+
+	auths, err := secret.Decode(regsec.Data[datakey])
+	if err != nil {
+		return err
+	}
+
+	projects, err := harbor.Projects()
+	if err != nil {
+		return err
+	}
+
+	robots, err := harbor.Robots()
+	if err != nil {
+		return err
+	}
+
+	ttl := robotCredentialPolicy(hsc, bd).GetTTL()
+	created := make([]goharborv1alpha1.PullRobot, 0, len(missing))
+	// Revoke the robot accounts created in this round if they can not be recorded
+	revokeCreated := func() {
+		for _, pr := range created {
+			if err := robots.DeleteRobotAccount(pr.ProjectID, pr.RobotID); err != nil {
+				r.Log.Error(err, "failed to delete pull robot account", "project", pr.Project, "robotID", pr.RobotID)
+			}
+		}
+	}
+
+	for _, project := range missing {
+		proj, err := projects.GetProject(project)
+		if err != nil {
+			revokeCreated()
+			return fmt.Errorf("get pull project %s error: %w", project, err)
+		}
+
+		robot, err := robots.CreatePullRobotAccount(int64(proj.ProjectID), ttl)
+		if err != nil {
+			revokeCreated()
+			return fmt.Errorf("create pull robot account in project %s error: %w", project, err)
+		}
+		created = append(created, goharborv1alpha1.PullRobot{Project: project, ProjectID: int64(proj.ProjectID), RobotID: robot.ID, ExpiresAt: expiryTime(robot.ExpiresAt)})
+
+		for _, registry := range hsc.Spec.ServerURLs() {
+			auths.Auths[secret.ProjectKey(registry, project)] = &secret.Auth{
+				Username: robot.Name,
+				Password: robot.Token,
+				Email:    fmt.Sprintf("%s@goharbor.io", robot.Name),
+			}
+		}
+	}
+
+	for _, pr := range stale {
+		for _, registry := range hsc.Spec.ServerURLs() {
+			delete(auths.Auths, secret.ProjectKey(registry, pr.Project))
+		}
+	}
+
+	regsec.Data[datakey] = auths.Encode()
+	if err := r.Client.Update(ctx, regsec, &client.UpdateOptions{}); err != nil {
+		revokeCreated()
+		return fmt.Errorf("update registry secret error: %w", err)
+	}
+
+	bd.Status.PullRobots = append(pullRobots, created...)
+	if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+		revokeCreated()
+		return fmt.Errorf("update pull robots of binding error: %w", err)
+	}
+
+	// The credentials are removed from the secret, the stale robot accounts can be deleted safely
+	for _, pr := range stale {
+		if err := robots.DeleteRobotAccount(pr.ProjectID, pr.RobotID); err != nil {
+			r.Log.Error(err, "failed to delete pull robot account", "project", pr.Project, "robotID", pr.RobotID)
+		}
+	}
+
+	return nil
+}
+
+// pullRobotOfProject returns the pull robot account of the project
+func pullRobotOfProject(pullRobots []goharborv1alpha1.PullRobot, project string) *goharborv1alpha1.PullRobot {
+	for i := range pullRobots {
+		if pullRobots[i].Project == project {
+			return &pullRobots[i]
+		}
+	}
+
+	return nil
+}
+
+// revokePullRobots deletes the pull robot accounts of the binding
+func revokePullRobots(robots rest.RobotClient, bd *goharborv1alpha1.PullSecretBinding) error {
+	for _, pr := range bd.Status.PullRobots {
+		if err := robots.DeleteRobotAccount(pr.ProjectID, pr.RobotID); err != nil {
+			return fmt.Errorf("delete pull robot account of project %s error: %w", pr.Project, err)
+		}
+	}
+
+	return nil
+}
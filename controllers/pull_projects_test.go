@@ -0,0 +1,221 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestSyncPullRobots(t *testing.T) {
+	type testcase struct {
+		description  string
+		pullProjects []string
+		pullRobots   []goharborv1alpha1.PullRobot
+		calls        []string
+		auths        []string
+		robots       []string
+		expectedErr  bool
+	}
+	tests := []testcase{
+		{
+			description:  "nothing to sync",
+			pullProjects: []string{"platform"},
+			pullRobots:   []goharborv1alpha1.PullRobot{{Project: "platform", ProjectID: 22, RobotID: 122}},
+			calls:        []string{},
+			auths:        []string{"harbor.local", "harbor.local/platform", "harbor-dr.local", "harbor-dr.local/platform"},
+			robots:       []string{"platform/122"},
+		},
+		{
+			description:  "pull robot is created for the added project on all the endpoints",
+			pullProjects: []string{"platform", "shared", "shared"},
+			pullRobots:   []goharborv1alpha1.PullRobot{{Project: "platform", ProjectID: 22, RobotID: 122}},
+			calls:        []string{"POST /api/v2.0/projects/21/robots"},
+			auths: []string{
+				"harbor.local", "harbor.local/platform", "harbor.local/shared",
+				"harbor-dr.local", "harbor-dr.local/platform", "harbor-dr.local/shared",
+			},
+			robots: []string{"platform/122", "shared/121"},
+		},
+		{
+			description: "pull robot of the removed project is deleted after the secret is updated",
+			pullRobots:  []goharborv1alpha1.PullRobot{{Project: "platform", ProjectID: 22, RobotID: 122}},
+			calls:       []string{"DELETE /api/v2.0/projects/22/robots/122"},
+			auths:       []string{"harbor.local", "harbor-dr.local"},
+			robots:      []string{},
+		},
+		{
+			description:  "pull robots created in the round are revoked if a project is missing",
+			pullProjects: []string{"shared", "missing"},
+			pullRobots:   []goharborv1alpha1.PullRobot{},
+			calls:        []string{"POST /api/v2.0/projects/21/robots", "DELETE /api/v2.0/projects/21/robots/121"},
+			auths:        []string{"harbor.local", "harbor-dr.local"},
+			robots:       []string{},
+			expectedErr:  true,
+		},
+	}
+
+	projectIDs := map[string]int64{"shared": 21, "platform": 22}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body interface{}
+				switch {
+				case req.Method == http.MethodGet && req.URL.Path == "/api/v2.0/projects":
+					projects := make([]map[string]interface{}, 0)
+					if id, ok := projectIDs[req.URL.Query().Get("name")]; ok {
+						projects = append(projects, map[string]interface{}{"project_id": id, "name": req.URL.Query().Get("name")})
+					}
+					body = projects
+				case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/robots"):
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+					var projectID int64
+					_, _ = fmt.Sscanf(req.URL.Path, "/api/v2.0/projects/%d/robots", &projectID)
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Location", fmt.Sprintf("%s/%d", req.URL.Path, 100+projectID))
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(map[string]string{"name": fmt.Sprintf("robot$%d", projectID), "token": "secret"})
+					return
+				default:
+					calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			auths := &secret.Object{Auths: map[string]*secret.Auth{}}
+			for _, registry := range []string{"harbor.local", "harbor-dr.local"} {
+				auths.Auths[registry] = &secret.Auth{Username: "robot$team", Password: "team-secret"}
+				for _, pr := range tc.pullRobots {
+					auths.Auths[secret.ProjectKey(registry, pr.Project)] = &secret.Auth{Username: "robot$" + pr.Project, Password: "secret"}
+				}
+			}
+			regsec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "regsecret-team"},
+				Data:       map[string][]byte{datakey: auths.Encode()},
+			}
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Annotations = map[string]string{utils.AnnotationRobotSecretRef: regsec.Name}
+			bd.Spec.PullProjects = tc.pullProjects
+			bd.Status.PullRobots = tc.pullRobots
+
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), regsec, &bd)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+			hsc := &goharborv1alpha1.HarborServerConfiguration{}
+			hsc.Spec.ServerURL = "harbor.local"
+			hsc.Spec.SecondaryServerURLs = []goharborv1alpha1.ServerURL{"harbor-dr.local"}
+
+			err := r.syncPullRobots(context.Background(), harbor, hsc, &bd)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.calls, calls)
+
+			got := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: regsec.Name}, got))
+			gotAuths, err := secret.Decode(got.Data[datakey])
+			require.NoError(t, err)
+			keys := make([]string, 0, len(gotAuths.Auths))
+			for key := range gotAuths.Auths {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tc.auths, keys)
+
+			gotBinding := &goharborv1alpha1.PullSecretBinding{}
+			require.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: bd.Name}, gotBinding))
+			robots := make([]string, 0, len(gotBinding.Status.PullRobots))
+			for _, pr := range gotBinding.Status.PullRobots {
+				robots = append(robots, fmt.Sprintf("%s/%d", pr.Project, pr.RobotID))
+			}
+			require.ElementsMatch(t, tc.robots, robots)
+		})
+	}
+}
+
+func TestRevokePullRobots(t *testing.T) {
+	type testcase struct {
+		description string
+		pullRobots  []goharborv1alpha1.PullRobot
+		failed      bool
+		calls       []string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "no pull robot",
+			calls:       []string{},
+		},
+		{
+			description: "all the pull robots are deleted",
+			pullRobots:  []goharborv1alpha1.PullRobot{{Project: "shared", ProjectID: 21, RobotID: 121}, {Project: "platform", ProjectID: 22, RobotID: 122}},
+			calls:       []string{"DELETE /api/v2.0/projects/21/robots/121", "DELETE /api/v2.0/projects/22/robots/122"},
+		},
+		{
+			description: "failure stops the revocation",
+			pullRobots:  []goharborv1alpha1.PullRobot{{Project: "shared", ProjectID: 21, RobotID: 121}, {Project: "platform", ProjectID: 22, RobotID: 122}},
+			failed:      true,
+			calls:       []string{"DELETE /api/v2.0/projects/21/robots/121"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			robots, server := newTestHarbor(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+				if tc.failed {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer server.Close()
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Status.PullRobots = tc.pullRobots
+
+			err := revokePullRobots(robots, &bd)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
@@ -27,15 +27,21 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
-	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
-	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 )
 
@@ -44,24 +50,41 @@ const (
 	regSecType   = "kubernetes.io/dockerconfigjson"
 	datakey      = ".dockerconfigjson"
 	finalizerID  = "psb.finalizers.resource.goharbor.io"
+	readyPhase   = "ready"
+	errorPhase   = "error"
 )
 
 // PullSecretBindingReconciler reconciles a PullSecretBinding object
 type PullSecretBindingReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	HarborV2 *v2.Client
-	Harbor   *legacy.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// MaxConcurrentReconciles is the max number of concurrent reconciles
+	MaxConcurrentReconciles int
+	// MaxConcurrentReconcilesPerServer is the max number of concurrent reconciles of the same harbor server configuration,
+	// no limit other than MaxConcurrentReconciles if it is not positive
+	MaxConcurrentReconcilesPerServer int
+	// Recorder records the repairs of the drifted bindings
+	Recorder record.EventRecorder
+
+	gate *harborClient.ReconcileGate
 }
 
 // +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=pullsecretbindings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=pullsecretbindings/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborserverconfigurations,verbs=get
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborserverconfigurations,verbs=get;list;watch
+// +kubebuilder:rbac:groups=goharbor.goharbor.io,resources=harborservers,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;update
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;update;patch
 
-func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (res ctrl.Result, ferr error) {
+func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	return requeueIfThrottled(r.reconcile(req))
+}
+
+func (r *PullSecretBindingReconciler) reconcile(req ctrl.Request) (res ctrl.Result, ferr error) {
 	ctx := context.Background()
 	log := r.Log.WithValues("pullsecretbinding", req.NamespacedName)
 
@@ -77,18 +100,36 @@ func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (res ctrl.Resu
 	}
 
 	// Check binding resources
-	server, sa, res, err := r.checkBindingRes(ctx, bd)
+	hsc, sa, res, err := r.checkBindingRes(ctx, bd)
 	if err != nil {
+		r.updateStatus(ctx, bd, errorPhase, reconcilingConditions(bd.Generation, "DependencyNotReady", err.Error()))
 		return res, err
 	} else {
-		if server == nil || sa == nil {
+		if hsc == nil || sa == nil {
+			// Wait for the missing resource to be created
+			msg := fmt.Sprintf("harbor server configuration %s does not exist", bd.Spec.HarborServerConfig)
+			if hsc != nil {
+				msg = fmt.Sprintf("service account %s does not exist", bd.Spec.ServiceAccount)
+			}
+			r.updateStatus(ctx, bd, errorPhase, stalledConditions(bd.Generation, "DependencyNotFound", msg))
 			return res, err
 		}
 	}
 
+	// Leave the workers to the other servers if this one is busy
+	release, acquired := r.gate.TryAcquire(hsc.Key())
+	if !acquired {
+		log.Info("too many reconciles of the harbor server configuration, requeue", "hsc", hsc.Key())
+		return ctrl.Result{RequeueAfter: harborClient.BusyRetryDelay}, nil
+	}
+	defer release()
+
 	// Talk to this server
-	r.HarborV2.WithServer(server).WithContext(ctx)
-	r.Harbor.WithServer(server).WithContext(ctx)
+	harbor, err := r.getHarborClient(ctx, hsc)
+	if err != nil {
+		r.updateStatus(ctx, bd, errorPhase, stalledConditions(bd.Generation, "InvalidConfiguration", err.Error()))
+		return ctrl.Result{}, err
+	}
 
 	// Check if the binding is being deleted
 	if bd.ObjectMeta.DeletionTimestamp.IsZero() {
@@ -102,7 +143,7 @@ func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (res ctrl.Resu
 	} else {
 		if utils.ContainsString(bd.ObjectMeta.Finalizers, finalizerID) {
 			// Execute and remove our finalizer from the finalizer list
-			if err := r.deleteExternalResources(bd); err != nil {
+			if err := r.deleteExternalResources(ctx, log, harbor, bd); err != nil {
 				return ctrl.Result{}, err
 			}
 
@@ -117,64 +158,116 @@ func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (res ctrl.Resu
 	}
 
 	defer func() {
-		if ferr != nil && bd.Status.Status != "error" {
-			bd.Status.Status = "error"
-			if bd.Status.Conditions == nil {
-				bd.Status.Conditions = make([]goharborv1alpha1.Condition, 0)
-			}
-			if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
-				log.Error(err, "defer update status error", "cause", err)
-			}
+		if ferr == nil {
+			return
+		}
+
+		if reason, ok := stalledReason(ferr); ok {
+			// Retrying does not help, wait for the binding or the configuration to be changed
+			log.Error(ferr, "binding is stalled")
+			r.updateStatus(ctx, bd, errorPhase, stalledConditions(bd.Generation, reason, ferr.Error()))
+			res, ferr = ctrl.Result{RequeueAfter: defaultCycle}, nil
+			return
 		}
+
+		r.updateStatus(ctx, bd, errorPhase, reconcilingConditions(bd.Generation, "BindingFailed", ferr.Error()))
 	}()
 
-	projID, robotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID)
+	// The robot account of the binding is read once in each cycle, it is nil if it is replaced in this cycle
+	var robot *model.Robot
+
+	// The project or robot account may be deleted or disabled in harbor after the binding is bound
+	if _, ok := bd.Annotations[utils.AnnotationRobotSecretRef]; ok {
+		reason, bound, err := r.detectDrift(ctx, harbor, bd)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("detect drift error: %w", err)
+		}
+
+		robot = bound
+		if len(reason) > 0 {
+			log.Info("repairing drifted binding", "reason", reason)
+			if err := r.repairBinding(ctx, log, harbor, hsc, bd, sa, reason); err != nil {
+				return ctrl.Result{}, fmt.Errorf("repair binding error: %w", err)
+			}
+		}
+	}
 
 	// Bind robot to service account
-	// TODO: may cause dirty robots at the harbor project side
-	// TODO: check secret binding by get secret and service account
 	_, ok := bd.Annotations[utils.AnnotationRobotSecretRef]
 	if !ok {
-		// Need to create a new one as we only have one time to get the robot token
-		robot, err := r.Harbor.GetRobotAccount(projID, robotID)
+		projID := parseIntID(bd.Spec.ProjectID)
+
+		// Need to create a new one as we only have one time to get the robot token,
+		// the robot account read from harbor never carries it
+		robots, err := harbor.Robots()
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		created, err := robots.CreateRobotAccount(projID, model.RobotAccess(bd.Spec.GetPermissions()), robotCredentialPolicy(hsc, bd).GetTTL())
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf("create robot account error: %w", err)
 		}
 
 		// Make registry secret
-		regsec, err := r.createRegSec(ctx, bd.Namespace, server.ServerURL, robot, bd)
+		regsec, err := r.createRegSec(ctx, bd.Namespace, hsc.Spec.ServerURLs(), created, bd)
 		if err != nil {
+			if err := robots.DeleteRobotAccount(projID, created.ID); err != nil {
+				log.Error(err, "failed to delete the unused robot account", "projectID", projID, "robotID", created.ID)
+			}
+
 			return ctrl.Result{}, fmt.Errorf("create registry secret error: %w", err)
 		}
 		// Add secret to service account
-		if sa.ImagePullSecrets == nil {
-			sa.ImagePullSecrets = make([]corev1.LocalObjectReference, 0)
-		}
-		sa.ImagePullSecrets = append(sa.ImagePullSecrets, corev1.LocalObjectReference{
-			Name: regsec.Name,
-		})
-
-		// Update
-		if err := r.Client.Update(ctx, sa, &client.UpdateOptions{}); err != nil {
-			return ctrl.Result{}, fmt.Errorf("update error: %w", err)
+		if err := r.bindRegSec(ctx, sa, regsec.Name, ""); err != nil {
+			return ctrl.Result{}, err
 		}
 
 		// Update binding
 		if err := controllerutil.SetControllerReference(bd, regsec, r.Scheme); err != nil {
 			r.Log.Error(err, "set controller reference", "owner", bd.ObjectMeta, "controlled", regsec.ObjectMeta)
 		}
+		bd.Spec.RobotID = strconv.FormatInt(created.ID, 10)
+		recordRobotPermissions(bd)
 		setAnnotation(bd, utils.AnnotationRobotSecretRef, regsec.Name)
 		if err := r.update(ctx, bd); err != nil {
 			return ctrl.Result{}, fmt.Errorf("update error: %w", err)
 		}
 	}
 
-	// TODO: add conditions
-	if bd.Status.Status != "ready" {
-		bd.Status.Status = "ready"
-		if bd.Status.Conditions == nil {
-			bd.Status.Conditions = make([]goharborv1alpha1.Condition, 0)
+	// The service account may be recreated or updated without the pull secret
+	if err := r.bindRegSec(ctx, sa, bd.Annotations[utils.AnnotationRobotSecretRef], ""); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.syncPullRobots(ctx, harbor, hsc, bd); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The endpoints of the harbor server configuration may be changed after the pull secret is created
+	if err := r.syncRegSecServers(ctx, hsc, bd); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The robot account created in this cycle is read once for its expiry and access
+	if robot == nil {
+		if robot, err = getBoundRobot(harbor, bd); err != nil {
+			return ctrl.Result{}, fmt.Errorf("get robot account error: %w", err)
 		}
+	}
+
+	// The access read with the robot account is shared by the rotation and the condition
+	access := verifyRobotAccess(bd, robot)
+	if err := r.rotateRobots(ctx, log, harbor, hsc, bd, robot, access); err != nil {
+		return ctrl.Result{}, fmt.Errorf("rotate robot accounts error: %w", err)
+	}
+
+	accessCond := checkRobotAccess(bd, access)
+	accessChanged := conditionChanged(bd.Status.Conditions, accessCond)
+	if bd.Status.Status != readyPhase ||
+		bd.Status.ObservedGeneration != bd.Generation ||
+		!goharborv1alpha1.IsConditionTrue(bd.Status.Conditions, goharborv1alpha1.ConditionReady) || accessChanged {
+		setStatus(bd, readyPhase, append(readyConditions(bd.Generation, "Bound", "pull secret is bound to the service account"), accessCond))
 		if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
 			if apierr.IsConflict(err) {
 				log.Error(err, "failed to update status")
@@ -182,6 +275,10 @@ func (r *PullSecretBindingReconciler) Reconcile(req ctrl.Request) (res ctrl.Resu
 				return ctrl.Result{}, err
 			}
 		}
+
+		if accessChanged && accessCond.Status == corev1.ConditionFalse && r.Recorder != nil {
+			r.Recorder.Event(bd, corev1.EventTypeWarning, accessCond.Reason, accessCond.Message)
+		}
 	}
 
 	// Loop
@@ -203,34 +300,37 @@ func (r *PullSecretBindingReconciler) update(ctx context.Context, binding *gohar
 	return r.Client.Get(ctx, namespacedName, binding)
 }
 
-func (r *PullSecretBindingReconciler) getConfigData(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration) (*model.HarborServer, error) {
-	s := &model.HarborServer{
-		ServerURL: hsc.Spec.ServerURL,
-		InSecure:  hsc.Spec.InSecure,
+// updateStatus updates the status of the binding, the error is logged as the reconcile will be retried anyway
+func (r *PullSecretBindingReconciler) updateStatus(ctx context.Context, bd *goharborv1alpha1.PullSecretBinding, phase string, conditions []goharborv1alpha1.Condition) {
+	setStatus(bd, phase, conditions)
+	if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+		r.Log.Error(err, "update status error", "binding", bd.Name, "namespace", bd.Namespace)
 	}
+}
 
-	namespacedName := types.NamespacedName{
-		Namespace: hsc.Spec.AccessCredential.Namespace,
-		Name:      hsc.Spec.AccessCredential.AccessSecretRef,
+func setStatus(bd *goharborv1alpha1.PullSecretBinding, phase string, conditions []goharborv1alpha1.Condition) {
+	bd.Status.Status = phase
+	bd.Status.ObservedGeneration = bd.Generation
+	if bd.Status.Conditions == nil {
+		bd.Status.Conditions = make([]goharborv1alpha1.Condition, 0)
 	}
-	sec := &corev1.Secret{}
-	if err := r.Client.Get(ctx, namespacedName, sec); err != nil {
-		return nil, fmt.Errorf("failed to get the configured secret with error: %w", err)
+	for _, cond := range conditions {
+		bd.Status.Conditions = goharborv1alpha1.SetCondition(bd.Status.Conditions, cond)
 	}
+}
 
-	cred := &model.AccessCred{}
-	if err := cred.FillIn(sec); err != nil {
-		return nil, fmt.Errorf("get credential error: %w", err)
+func (r *PullSecretBindingReconciler) getHarborClient(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration) (*harborClient.Clients, error) {
+	clients, err := harborClient.CreateHarborClients(ctx, r.Client, hsc)
+	if err != nil {
+		return nil, fmt.Errorf("create harbor client error: %w", err)
 	}
 
-	s.AccessCred = cred
-
-	return s, nil
+	return clients, nil
 }
 
-func (r *PullSecretBindingReconciler) checkBindingRes(ctx context.Context, psb *goharborv1alpha1.PullSecretBinding) (*model.HarborServer, *corev1.ServiceAccount, ctrl.Result, error) {
+func (r *PullSecretBindingReconciler) checkBindingRes(ctx context.Context, psb *goharborv1alpha1.PullSecretBinding) (*goharborv1alpha1.HarborServerConfiguration, *corev1.ServiceAccount, ctrl.Result, error) {
 	// Get server configuration
-	hsc, err := r.getHarborServerConfig(ctx, psb.Spec.HarborServerConfig)
+	hsc, err := r.getHarborServerConfig(ctx, psb.Namespace, psb.Spec.HarborServerConfig)
 	if err != nil {
 		// Retry later
 		return nil, nil, ctrl.Result{}, fmt.Errorf("get server configuration error: %w", err)
@@ -257,25 +357,17 @@ func (r *PullSecretBindingReconciler) checkBindingRes(ctx context.Context, psb *
 	if sa == nil {
 		// Not exist
 		r.Log.Info("service account does not exist", "name", psb.Spec.ServiceAccount)
-		// Do not need to reconcile again
-		return nil, nil, ctrl.Result{}, nil
-	}
-
-	hs, err := r.getConfigData(ctx, hsc)
-	if err != nil {
-		return nil, nil, ctrl.Result{}, fmt.Errorf("get config data error: %w", err)
+		// The binding is reconciled again once the service account is created
+		return hsc, nil, ctrl.Result{}, nil
 	}
 
-	return hs, sa, ctrl.Result{}, nil
+	return hsc, sa, ctrl.Result{}, nil
 }
 
-func (r *PullSecretBindingReconciler) getHarborServerConfig(ctx context.Context, name string) (*goharborv1alpha1.HarborServerConfiguration, error) {
-	hsc := &goharborv1alpha1.HarborServerConfiguration{}
-	// HarborServerConfiguration is cluster scoped resource
-	namespacedName := types.NamespacedName{
-		Name: name,
-	}
-	if err := r.Client.Get(ctx, namespacedName, hsc); err != nil {
+// getHarborServerConfig gets the harbor server in the namespace of the binding or the harbor server configuration with the name
+func (r *PullSecretBindingReconciler) getHarborServerConfig(ctx context.Context, namespace, name string) (*goharborv1alpha1.HarborServerConfiguration, error) {
+	hsc, err := harborClient.GetServerConfiguration(ctx, r.Client, namespace, name)
+	if err != nil {
 		// Explicitly check not found error
 		if apierr.IsNotFound(err) {
 			return nil, nil
@@ -305,14 +397,18 @@ func (r *PullSecretBindingReconciler) getServiceAccount(ctx context.Context, ns,
 	return sc, nil
 }
 
-func (r *PullSecretBindingReconciler) createRegSec(ctx context.Context, namespace string, registry string, robot *model.Robot, psb *goharborv1alpha1.PullSecretBinding) (*corev1.Secret, error) {
+// createRegSec creates the pull secret with an auth entry for each endpoint of the harbor server,
+// the images can be still pulled from the secondary endpoints if the primary one is down
+func (r *PullSecretBindingReconciler) createRegSec(ctx context.Context, namespace string, registries []string, robot *model.Robot, psb *goharborv1alpha1.PullSecretBinding) (*corev1.Secret, error) {
 	auths := &secret.Object{
 		Auths: map[string]*secret.Auth{},
 	}
-	auths.Auths[registry] = &secret.Auth{
-		Username: robot.Name,
-		Password: robot.Token,
-		Email:    fmt.Sprintf("%s@goharbor.io", robot.Name),
+	for _, registry := range registries {
+		auths.Auths[registry] = &secret.Auth{
+			Username: robot.Name,
+			Password: robot.Token,
+			Email:    fmt.Sprintf("%s@goharbor.io", robot.Name),
+		}
 	}
 
 	encoded := auths.Encode()
@@ -335,9 +431,38 @@ func (r *PullSecretBindingReconciler) createRegSec(ctx context.Context, namespac
 	return regSec, r.Client.Create(ctx, regSec, &client.CreateOptions{})
 }
 
-func (r *PullSecretBindingReconciler) deleteExternalResources(bd *goharborv1alpha1.PullSecretBinding) error {
+func (r *PullSecretBindingReconciler) deleteExternalResources(ctx context.Context, log logr.Logger, harbor *harborClient.Clients, bd *goharborv1alpha1.PullSecretBinding) error {
+	if len(bd.Status.PullRobots) > 0 || len(bd.Status.RetiredRobots) > 0 {
+		robots, err := harbor.Robots()
+		if err != nil {
+			return err
+		}
+
+		if err := revokePullRobots(robots, bd); err != nil {
+			return err
+		}
+
+		// The grace period is over with the binding
+		kept, err := r.revokeRetiredRobots(ctx, log, robots, bd, bd.Status.RetiredRobots, true)
+		if err != nil {
+			return err
+		}
+		if len(kept) > 0 {
+			return fmt.Errorf("%d retired robot accounts can not be revoked", len(kept))
+		}
+	}
+
+	if err := r.revokeBoundRobot(ctx, log, harbor, bd); err != nil {
+		return err
+	}
+
 	if pro, ok := bd.Annotations[utils.AnnotationProject]; ok {
-		if err := r.HarborV2.DeleteProject(pro); err != nil {
+		projects, err := harbor.Projects()
+		if err != nil {
+			return err
+		}
+
+		if err := projects.DeleteProject(pro); err != nil {
 			// TODO: handle delete error
 			// Delete non-empty project will cause error?
 			r.Log.Error(err, "delete external resources", "finalizer", finalizerID)
@@ -347,12 +472,129 @@ func (r *PullSecretBindingReconciler) deleteExternalResources(bd *goharborv1alph
 	return nil
 }
 
+// revokeBoundRobot deletes the robot account bound by the binding, e.g: the binding of the service account no longer selected by the namespace.
+// The robot account shared by the bindings of the earlier versions is kept until the last binding referring it is deleted.
+func (r *PullSecretBindingReconciler) revokeBoundRobot(ctx context.Context, log logr.Logger, harbor *harborClient.Clients, bd *goharborv1alpha1.PullSecretBinding) error {
+	projID, robotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID)
+	// The robot account is only created by the binding once it is bound
+	if _, bound := bd.Annotations[utils.AnnotationRobotSecretRef]; !bound || projID <= 0 || robotID <= 0 {
+		return nil
+	}
+
+	bindings := &goharborv1alpha1.PullSecretBindingList{}
+	if err := r.Client.List(ctx, bindings, client.InNamespace(bd.Namespace)); err != nil {
+		return fmt.Errorf("list bindings error: %w", err)
+	}
+
+	if robotInUse(bindings, bd, robotID) {
+		log.Info("robot account is still used by other bindings, keep it", "robotID", robotID)
+		return nil
+	}
+
+	robots, err := harbor.Robots()
+	if err != nil {
+		return err
+	}
+
+	if err := robots.DeleteRobotAccount(projID, robotID); err != nil {
+		return fmt.Errorf("revoke robot account %d of project %d error: %w", robotID, projID, err)
+	}
+
+	return nil
+}
+
 func (r *PullSecretBindingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.gate = harborClient.NewReconcileGate(r.MaxConcurrentReconcilesPerServer)
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&goharborv1alpha1.PullSecretBinding{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.psbsForSecret),
+		}, builder.WithPredicates(referredObjectChanged())).
+		Watches(&source.Kind{Type: &corev1.ServiceAccount{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.psbsForServiceAccount),
+		}, builder.WithPredicates(imagePullSecretsChanged())).
+		Watches(&source.Kind{Type: &goharborv1alpha1.HarborServerConfiguration{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.psbsForServer),
+		}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &goharborv1alpha1.HarborServer{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.psbsForServer),
+		}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
 
+// psbsForServer maps the changed harbor server configuration or harbor server to the bindings referring it,
+// e.g: the pull secrets are re-encoded once the endpoints are changed
+func (r *PullSecretBindingReconciler) psbsForServer(obj handler.MapObject) []reconcile.Request {
+	var hsc *goharborv1alpha1.HarborServerConfiguration
+	switch o := obj.Object.(type) {
+	case *goharborv1alpha1.HarborServerConfiguration:
+		hsc = o
+	case *goharborv1alpha1.HarborServer:
+		hsc = o.ToServerConfiguration()
+	default:
+		return nil
+	}
+
+	psbs, err := psbsReferringHSC(context.Background(), r.Client, hsc)
+	if err != nil {
+		r.Log.Error(err, "failed to list pull secret bindings referring harbor server configuration", "hsc", hsc.Key())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(psbs))
+	for _, psb := range psbs {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: psb.Namespace, Name: psb.Name}})
+	}
+
+	return requests
+}
+
+// psbsForServiceAccount maps the created or changed service account to its bindings,
+// so the pull secret is bound again if the service account is recreated or its image pull secrets are wiped
+func (r *PullSecretBindingReconciler) psbsForServiceAccount(obj handler.MapObject) []reconcile.Request {
+	sa := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+	psbs, err := psbsBindingServiceAccount(context.Background(), r.Client, sa)
+	if err != nil {
+		r.Log.Error(err, "failed to list pull secret bindings of service account", "serviceAccount", sa)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(psbs))
+	for _, psb := range psbs {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: psb.Namespace, Name: psb.Name}})
+	}
+
+	return requests
+}
+
+// psbsForSecret maps the changed secret to the bindings depending on the harbor server configurations referring it
+func (r *PullSecretBindingReconciler) psbsForSecret(obj handler.MapObject) []reconcile.Request {
+	ctx := context.Background()
+	secret := types.NamespacedName{Namespace: obj.Meta.GetNamespace(), Name: obj.Meta.GetName()}
+	hscs, err := hscsReferringSecret(ctx, r.Client, secret)
+	if err != nil {
+		r.Log.Error(err, "failed to list harbor server configurations referring secret", "secret", secret)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0)
+	for _, hsc := range hscs {
+		psbs, err := psbsReferringHSC(ctx, r.Client, &hsc)
+		if err != nil {
+			r.Log.Error(err, "failed to list pull secret bindings referring harbor server configuration", "hsc", hsc.Key())
+			continue
+		}
+
+		for _, psb := range psbs {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: psb.Namespace, Name: psb.Name}})
+		}
+	}
+
+	return requests
+}
+
 func setAnnotation(obj *goharborv1alpha1.PullSecretBinding, key string, value string) {
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
@@ -0,0 +1,113 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// syncRegSecServers re-encodes the pull secret of the binding once the endpoints of the harbor server configuration are changed,
+// so the existing pull secrets also work with the added secondary endpoints
+func (r *PullSecretBindingReconciler) syncRegSecServers(ctx context.Context, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding) error {
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		return fmt.Errorf("get registry secret error: %w", err)
+	}
+
+	auths, err := secret.Decode(regsec.Data[datakey])
+	if err != nil {
+		return err
+	}
+
+	projects := make([]string, 0, len(bd.Status.PullRobots))
+	for _, pr := range bd.Status.PullRobots {
+		projects = append(projects, pr.Project)
+	}
+
+	if !syncServerAuths(auths, hsc.Spec.ServerURLs(), projects) {
+		return nil
+	}
+
+	regsec.Data[datakey] = auths.Encode()
+	if err := r.Client.Update(ctx, regsec, &client.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update registry secret error: %w", err)
+	}
+
+	return nil
+}
+
+// syncServerAuths makes the auth entries of the pull secret cover all the endpoints of the harbor server.
+// The credential of the registry and of each pull project is copied from an existing endpoint to the added ones,
+// and the entries of the removed endpoints are dropped. It returns true if the entries are changed.
+func syncServerAuths(auths *secret.Object, servers []string, projects []string) bool {
+	paths := []string{""}
+	for _, project := range projects {
+		paths = append(paths, "/"+project)
+	}
+
+	changed := false
+	for _, path := range paths {
+		var auth *secret.Auth
+		for key, a := range auths.Auths {
+			if _, p := splitAuthKey(key); p == path {
+				auth = a
+				// Prefer the credential of a current endpoint
+				if host, _ := splitAuthKey(key); utils.ContainsString(servers, host) {
+					break
+				}
+			}
+		}
+
+		if auth == nil {
+			continue
+		}
+
+		for _, server := range servers {
+			key := strings.TrimSuffix(server, "/") + path
+			if _, ok := auths.Auths[key]; !ok {
+				auths.Auths[key] = &secret.Auth{Username: auth.Username, Password: auth.Password, Email: auth.Email}
+				changed = true
+			}
+		}
+	}
+
+	for key := range auths.Auths {
+		if host, _ := splitAuthKey(key); !utils.ContainsString(servers, host) {
+			delete(auths.Auths, key)
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// splitAuthKey splits the key of the auth entry into the registry host and the path of the project
+func splitAuthKey(key string) (string, string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i:]
+	}
+
+	return key, ""
+}
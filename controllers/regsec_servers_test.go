@@ -0,0 +1,86 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+)
+
+func TestSyncServerAuths(t *testing.T) {
+	robot := &secret.Auth{Username: "robot$psb", Password: "secret"}
+	puller := &secret.Auth{Username: "robot$pull", Password: "pull-secret"}
+
+	type testcase struct {
+		description string
+		auths       map[string]*secret.Auth
+		servers     []string
+		projects    []string
+		changed     bool
+		keys        map[string]*secret.Auth
+	}
+	tests := []testcase{
+		{
+			description: "entries cover all the endpoints",
+			auths:       map[string]*secret.Auth{"harbor.local": robot, "mirror.local": robot},
+			servers:     []string{"harbor.local", "mirror.local"},
+			keys:        map[string]*secret.Auth{"harbor.local": robot, "mirror.local": robot},
+		},
+		{
+			description: "credentials are copied to the added endpoint",
+			auths:       map[string]*secret.Auth{"harbor.local": robot, "harbor.local/library": puller},
+			servers:     []string{"harbor.local", "mirror.local:8443"},
+			projects:    []string{"library"},
+			changed:     true,
+			keys: map[string]*secret.Auth{
+				"harbor.local":              robot,
+				"harbor.local/library":      puller,
+				"mirror.local:8443":         robot,
+				"mirror.local:8443/library": puller,
+			},
+		},
+		{
+			description: "entries of the removed endpoint are dropped",
+			auths:       map[string]*secret.Auth{"harbor.local": robot, "old.local": robot, "old.local/library": puller},
+			servers:     []string{"harbor.local"},
+			changed:     true,
+			keys:        map[string]*secret.Auth{"harbor.local": robot},
+		},
+		{
+			description: "endpoint is replaced",
+			auths:       map[string]*secret.Auth{"old.local": robot},
+			servers:     []string{"harbor.local"},
+			changed:     true,
+			keys:        map[string]*secret.Auth{"harbor.local": robot},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			auths := &secret.Object{Auths: tc.auths}
+			require.Equal(t, tc.changed, syncServerAuths(auths, tc.servers, tc.projects))
+			require.Len(t, auths.Auths, len(tc.keys))
+			for key, want := range tc.keys {
+				require.Contains(t, auths.Auths, key)
+				require.Equal(t, want.Username, auths.Auths[key].Username)
+				require.Equal(t, want.Password, auths.Auths[key].Password)
+			}
+		})
+	}
+}
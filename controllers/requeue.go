@@ -0,0 +1,37 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
+)
+
+// requeueIfThrottled requeues the request after the suggested delay if the API call is rejected by the
+// throttle of the Harbor server. The worker is released for the requests of other Harbor servers instead
+// of waiting for the throttled one.
+func requeueIfThrottled(res ctrl.Result, err error) (ctrl.Result, error) {
+	var throttled *ghttp.ThrottledError
+	if errors.As(err, &throttled) {
+		return ctrl.Result{RequeueAfter: throttled.RetryAfter}, nil
+	}
+
+	return res, err
+}
@@ -0,0 +1,120 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/kstatus/status"
+)
+
+// robotLeastPrivilege condition reports whether the robot account of the binding has no more access than the declared permissions
+const robotLeastPrivilege = "RobotLeastPrivilege"
+
+// robotAccess is the access of the robot account of the binding compared with its declared permissions
+type robotAccess struct {
+	// missing is the declared access not granted to the robot account
+	missing []model.Permission
+	// excess is the access granted to the robot account beyond the declared permissions
+	excess []model.Permission
+	// verified is false if the access can not be read from harbor, the missing access is then decided by the recorded permissions
+	verified bool
+}
+
+// verifyRobotAccess compares the access of the robot account of the binding with its declared permissions.
+// The access is read together with the robot account from Harbor v2.2 or later, for the older servers the robot account is taken as having
+// the permissions recorded when it was created by the binding, or pull only otherwise (e.g: the robot account of the namespace),
+// so the binding declaring more permissions gets a robot account of its own.
+func verifyRobotAccess(bd *goharborv1alpha1.PullSecretBinding, robot *model.Robot) *robotAccess {
+	declared := model.RobotAccess(bd.Spec.GetPermissions())
+	if robot.Access == nil {
+		return &robotAccess{missing: model.MissingPermissions(declared, recordedAccess(bd))}
+	}
+
+	return &robotAccess{
+		missing:  model.MissingPermissions(declared, robot.Access),
+		excess:   model.MissingPermissions(robot.Access, declared),
+		verified: true,
+	}
+}
+
+// recordedAccess returns the access of the robot account of the binding recorded when it was created by the binding, pull only if not recorded
+func recordedAccess(bd *goharborv1alpha1.PullSecretBinding) []model.Permission {
+	parts := strings.SplitN(bd.Annotations[utils.AnnotationRobotPermissions], ":", 2)
+	if len(parts) != 2 || parts[0] != bd.Spec.RobotID {
+		return model.RobotAccess(nil)
+	}
+
+	permissions := make([]goharborv1alpha1.RobotPermission, 0)
+	for _, p := range strings.Split(parts[1], ",") {
+		permissions = append(permissions, goharborv1alpha1.RobotPermission(strings.TrimSpace(p)))
+	}
+
+	return model.RobotAccess(permissions)
+}
+
+// recordRobotPermissions records the declared permissions the robot account of the binding is created with
+func recordRobotPermissions(bd *goharborv1alpha1.PullSecretBinding) {
+	permissions := make([]string, 0)
+	for _, p := range bd.Spec.GetPermissions() {
+		permissions = append(permissions, string(p))
+	}
+
+	setAnnotation(bd, utils.AnnotationRobotPermissions, fmt.Sprintf("%s:%s", bd.Spec.RobotID, strings.Join(permissions, ",")))
+}
+
+// checkRobotAccess flags the robot account of the binding if it has more access than the declared permissions.
+// The messages are stable for the same result, so the status is not rewritten in every cycle.
+func checkRobotAccess(bd *goharborv1alpha1.PullSecretBinding, access *robotAccess) goharborv1alpha1.Condition {
+	cond := goharborv1alpha1.Condition{
+		Type:               status.ConditionType(robotLeastPrivilege),
+		Status:             corev1.ConditionUnknown,
+		ObservedGeneration: bd.Generation,
+	}
+
+	switch {
+	case !access.verified:
+		cond.Reason = "VerificationNotSupported"
+		cond.Message = "the access of robot accounts can only be verified with Harbor v2.2 or later, the declared permissions are granted to a robot account of the binding"
+	case len(access.excess) > 0:
+		names := make([]string, 0, len(access.excess))
+		for _, p := range access.excess {
+			names = append(names, p.String())
+		}
+
+		cond.Status = corev1.ConditionFalse
+		cond.Reason = "ExcessiveAccess"
+		cond.Message = fmt.Sprintf("robot account %s has access beyond the declared permissions: %s, delete it or set a robot credential TTL to replace it", bd.Spec.RobotID, strings.Join(names, ", "))
+	default:
+		cond.Status = corev1.ConditionTrue
+		cond.Reason = "AccessDeclared"
+		cond.Message = fmt.Sprintf("robot account %s only has the declared permissions", bd.Spec.RobotID)
+	}
+
+	return cond
+}
+
+// conditionChanged checks if the condition differs from the one with the same type in the conditions
+func conditionChanged(conditions []goharborv1alpha1.Condition, cond goharborv1alpha1.Condition) bool {
+	current := goharborv1alpha1.FindCondition(conditions, cond.Type)
+	return current == nil || current.Status != cond.Status || current.Reason != cond.Reason || current.Message != cond.Message
+}
@@ -0,0 +1,198 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	pullAccess = model.Permission{Resource: "repository", Action: "pull"}
+	pushAccess = model.Permission{Resource: "repository", Action: "push"}
+)
+
+func TestRecordedAccess(t *testing.T) {
+	type testcase struct {
+		description string
+		annotation  string
+		expected    []model.Permission
+	}
+	tests := []testcase{
+		{
+			description: "robot account not created by the binding",
+			expected:    []model.Permission{pullAccess},
+		},
+		{
+			description: "robot account created by the binding",
+			annotation:  "3:pull,push",
+			expected:    []model.Permission{pullAccess, pushAccess},
+		},
+		{
+			description: "permissions recorded for the replaced robot account",
+			annotation:  "2:pull,push",
+			expected:    []model.Permission{pullAccess},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			if len(tc.annotation) > 0 {
+				bd.Annotations = map[string]string{utils.AnnotationRobotPermissions: tc.annotation}
+			}
+
+			require.Equal(t, tc.expected, recordedAccess(&bd))
+		})
+	}
+}
+
+func TestRecordRobotPermissions(t *testing.T) {
+	bd := binding("team", "binding-default", "default", "harbor", "3")
+	bd.Spec.Permissions = []goharborv1alpha1.RobotPermission{goharborv1alpha1.RobotPermissionPush}
+
+	recordRobotPermissions(&bd)
+	require.Equal(t, "3:pull,push", bd.Annotations[utils.AnnotationRobotPermissions])
+	require.Empty(t, model.MissingPermissions(model.RobotAccess(bd.Spec.GetPermissions()), recordedAccess(&bd)))
+}
+
+func TestVerifyRobotAccess(t *testing.T) {
+	type testcase struct {
+		description string
+		permissions []goharborv1alpha1.RobotPermission
+		granted     string
+		failed      bool
+		missing     []model.Permission
+		excess      []model.Permission
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "robot account has the declared permissions",
+			granted:     `[{"resource":"repository","action":"pull"}]`,
+		},
+		{
+			description: "robot account misses the declared permissions",
+			permissions: []goharborv1alpha1.RobotPermission{goharborv1alpha1.RobotPermissionPush},
+			granted:     `[{"resource":"repository","action":"pull"}]`,
+			missing:     []model.Permission{pushAccess},
+		},
+		{
+			description: "robot account has more access than declared",
+			granted:     `[{"resource":"repository","action":"pull"},{"resource":"repository","action":"push"}]`,
+			excess:      []model.Permission{pushAccess},
+		},
+		{
+			description: "robot account can not be read",
+			failed:      true,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			reads := 0
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method != http.MethodGet || req.URL.Path != "/api/v2.0/robots/3" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				reads++
+				if tc.failed {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":3,"name":"robot$team","expires_at":-1,"permissions":[{"kind":"project","namespace":"team","access":` + tc.granted + `}]}`))
+			}))
+			defer server.Close()
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Spec.Permissions = tc.permissions
+
+			// The robot account and its access are read in one request
+			robot, err := getBoundRobot(harbor, &bd)
+			require.Equal(t, 1, reads)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, robot.ExpiresAt.IsZero())
+			access := verifyRobotAccess(&bd, robot)
+			require.True(t, access.verified)
+			require.ElementsMatch(t, tc.missing, access.missing)
+			require.ElementsMatch(t, tc.excess, access.excess)
+		})
+	}
+
+	// The access is not read from the older harbor servers, the recorded permissions are used instead
+	bd := binding("team", "binding-default", "default", "harbor", "3")
+	bd.Spec.Permissions = []goharborv1alpha1.RobotPermission{goharborv1alpha1.RobotPermissionPush}
+	access := verifyRobotAccess(&bd, &model.Robot{ID: 3})
+	require.False(t, access.verified)
+	require.ElementsMatch(t, []model.Permission{pushAccess}, access.missing)
+}
+
+func TestCheckRobotAccess(t *testing.T) {
+	bd := binding("team", "binding-default", "default", "harbor", "3")
+
+	type testcase struct {
+		description string
+		access      *robotAccess
+		status      corev1.ConditionStatus
+		reason      string
+	}
+	tests := []testcase{
+		{
+			description: "declared access",
+			access:      &robotAccess{verified: true},
+			status:      corev1.ConditionTrue,
+			reason:      "AccessDeclared",
+		},
+		{
+			description: "excessive access",
+			access:      &robotAccess{verified: true, excess: []model.Permission{pushAccess}},
+			status:      corev1.ConditionFalse,
+			reason:      "ExcessiveAccess",
+		},
+		{
+			description: "verification not supported",
+			access:      &robotAccess{},
+			status:      corev1.ConditionUnknown,
+			reason:      "VerificationNotSupported",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			cond := checkRobotAccess(&bd, tc.access)
+			require.Equal(t, tc.status, cond.Status)
+			require.Equal(t, tc.reason, cond.Reason)
+		})
+	}
+
+}
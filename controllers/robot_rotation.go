@@ -0,0 +1,381 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// robotCredentialPolicy returns the lifetime policy of the robot accounts of the binding,
+// the policy of the binding overrides the one of the harbor server configuration
+func robotCredentialPolicy(hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding) *goharborv1alpha1.RobotCredentialPolicy {
+	if bd != nil && bd.Spec.RobotCredential != nil {
+		return bd.Spec.RobotCredential
+	}
+
+	if hsc.Spec.RobotCredential != nil {
+		return hsc.Spec.RobotCredential
+	}
+
+	return &goharborv1alpha1.RobotCredentialPolicy{}
+}
+
+// rotationDue checks if the robot account expiring at the time should be replaced now.
+// The robot accounts never expiring or living longer than the TTL are replaced once the TTL is set,
+// the others are replaced in the rotation window before their expiry.
+func rotationDue(policy *goharborv1alpha1.RobotCredentialPolicy, expiresAt, now time.Time) bool {
+	ttl := policy.GetTTL()
+	if expiresAt.IsZero() {
+		return ttl > 0
+	}
+
+	if ttl > 0 && expiresAt.After(now.Add(ttl)) {
+		return true
+	}
+
+	return !now.Add(policy.GetRotationWindow()).Before(expiresAt)
+}
+
+// rotateRobots replaces the robot accounts of the binding which are due to rotate, the pull secret is updated in place with the new credentials.
+// The replaced robot accounts are revoked once their grace period is over, and the expiry of the robot account is shown in the status.
+// The robot account of the binding is the one already read from harbor in this cycle.
+func (r *PullSecretBindingReconciler) rotateRobots(ctx context.Context, log logr.Logger, harbor *harborClient.Clients, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding, robot *model.Robot, access *robotAccess) error {
+	policy := robotCredentialPolicy(hsc, bd)
+	now := time.Now()
+
+	robots, err := harbor.Robots()
+	if err != nil {
+		return err
+	}
+
+	projID, robotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID)
+
+	// The robot account missing the declared permissions is replaced at once
+	if rotationDue(policy, robot.ExpiresAt, now) || len(access.missing) > 0 {
+		if robot, err = r.rotateRobot(ctx, log, robots, hsc, bd, policy); err != nil {
+			return err
+		}
+
+		// The new robot account is granted the declared permissions only
+		*access = robotAccess{verified: harbor.HasCapability(model.CapabilitySystemRobot)}
+
+		// The replaced robot account is recorded at once, so it is revoked even if the following rotations fail
+		retired := goharborv1alpha1.RetiredRobot{ProjectID: projID, RobotID: robotID, RevokeAfter: metav1.NewTime(now.Add(policy.GetGracePeriod()))}
+		expiresAt := expiryTime(robot.ExpiresAt)
+		if err := r.recordRobots(ctx, bd, func(st *goharborv1alpha1.PullSecretBindingStatus) {
+			st.RobotExpiresAt = expiresAt
+			st.RetiredRobots = append(st.RetiredRobots, retired)
+		}); err != nil {
+			return fmt.Errorf("record retired robot account %d error: %w", robotID, err)
+		}
+	}
+
+	pullRobots := append([]goharborv1alpha1.PullRobot(nil), bd.Status.PullRobots...)
+	for _, pr := range pullRobots {
+		var expiresAt time.Time
+		if pr.ExpiresAt != nil {
+			expiresAt = pr.ExpiresAt.Time
+		}
+
+		if !rotationDue(policy, expiresAt, now) {
+			continue
+		}
+
+		pullRobot, err := robots.CreatePullRobotAccount(pr.ProjectID, policy.GetTTL())
+		if err != nil {
+			return fmt.Errorf("create pull robot account in project %s error: %w", pr.Project, err)
+		}
+
+		keys := make([]string, 0)
+		for _, registry := range hsc.Spec.ServerURLs() {
+			keys = append(keys, secret.ProjectKey(registry, pr.Project))
+		}
+
+		// The pull robot account is swapped and the replaced one is retired in the same status update
+		retired := goharborv1alpha1.RetiredRobot{ProjectID: pr.ProjectID, RobotID: pr.RobotID, RevokeAfter: metav1.NewTime(now.Add(policy.GetGracePeriod()))}
+		if err := r.swapRobot(ctx, log, robots, bd, keys, pr.ProjectID, pullRobot, func() error {
+			return r.recordRobots(ctx, bd, func(st *goharborv1alpha1.PullSecretBindingStatus) {
+				for i := range st.PullRobots {
+					if st.PullRobots[i].ProjectID == pr.ProjectID && st.PullRobots[i].RobotID == pr.RobotID {
+						st.PullRobots[i].RobotID = pullRobot.ID
+						st.PullRobots[i].ExpiresAt = expiryTime(pullRobot.ExpiresAt)
+					}
+				}
+				st.RetiredRobots = append(st.RetiredRobots, retired)
+			})
+		}); err != nil {
+			return err
+		}
+
+		log.Info("pull robot account is rotated", "project", pr.Project, "from", pr.RobotID, "to", pullRobot.ID)
+	}
+
+	st := bd.Status.DeepCopy()
+	st.RobotExpiresAt = expiryTime(robot.ExpiresAt)
+	st.RetiredRobots, err = r.revokeRetiredRobots(ctx, log, robots, bd, st.RetiredRobots, false)
+	if err != nil {
+		return err
+	}
+
+	if len(st.RetiredRobots) == 0 {
+		st.RetiredRobots = nil
+	}
+
+	if st.RobotExpiresAt.Equal(bd.Status.RobotExpiresAt) && len(st.RetiredRobots) == len(bd.Status.RetiredRobots) {
+		return nil
+	}
+
+	bd.Status = *st
+	if err := r.Status().Update(ctx, bd, &client.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update robot accounts of binding error: %w", err)
+	}
+
+	return nil
+}
+
+// rotateRobot replaces the robot account of the binding with a new one and swaps the credential in the pull secret,
+// it returns the new robot account
+func (r *PullSecretBindingReconciler) rotateRobot(ctx context.Context, log logr.Logger, robots rest.RobotClient, hsc *goharborv1alpha1.HarborServerConfiguration, bd *goharborv1alpha1.PullSecretBinding, policy *goharborv1alpha1.RobotCredentialPolicy) (*model.Robot, error) {
+	projID, oldRobotID := parseIntID(bd.Spec.ProjectID), parseIntID(bd.Spec.RobotID)
+
+	robot, err := robots.CreateRobotAccount(projID, model.RobotAccess(bd.Spec.GetPermissions()), policy.GetTTL())
+	if err != nil {
+		return nil, fmt.Errorf("create robot account error: %w", err)
+	}
+
+	// The binding refers the new robot account before the next reconcile, otherwise another one is created for the still due robot account
+	if err := r.swapRobot(ctx, log, robots, bd, hsc.Spec.ServerURLs(), projID, robot, func() error {
+		previous := bd.DeepCopy()
+		bd.Spec.RobotID = strconv.FormatInt(robot.ID, 10)
+		recordRobotPermissions(bd)
+		if err := r.update(ctx, bd); err != nil {
+			previous.DeepCopyInto(bd)
+			return fmt.Errorf("update binding error: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	// The deprecated robot annotation of the namespace keeps referring a live robot account, so it is revoked with the project
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: bd.Namespace}, ns); err != nil {
+		log.Error(err, "failed to get namespace")
+	} else {
+		r.replaceNamespaceRobot(ctx, log, ns, bd, oldRobotID)
+	}
+
+	log.Info("robot account is rotated", "from", oldRobotID, "to", robot.ID)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(bd, corev1.EventTypeNormal, "RobotRotated", "robot account %d is replaced by robot account %d, the pull secret is updated", oldRobotID, robot.ID)
+	}
+
+	return robot, nil
+}
+
+// swapRobot swaps the credential of the robot account into the auth entries of the pull secret and persists the swap.
+// If the swap can not be persisted, the pull secret is restored and the robot account is deleted, so it is not orphaned in Harbor.
+func (r *PullSecretBindingReconciler) swapRobot(ctx context.Context, log logr.Logger, robots rest.RobotClient, bd *goharborv1alpha1.PullSecretBinding, keys []string, projectID int64, robot *model.Robot, persist func() error) error {
+	previous, err := r.updateRegSecAuths(ctx, bd, keys, robot)
+	if err == nil {
+		if err = persist(); err == nil {
+			return nil
+		}
+
+		if rerr := r.restoreRegSec(ctx, bd, previous); rerr != nil {
+			// The robot account is kept as the pull secret still uses it
+			log.Error(rerr, "failed to restore registry secret", "projectID", projectID, "robotID", robot.ID)
+			return err
+		}
+	}
+
+	if err := robots.DeleteRobotAccount(projectID, robot.ID); err != nil {
+		log.Error(err, "failed to delete the unused robot account", "projectID", projectID, "robotID", robot.ID)
+	}
+
+	return err
+}
+
+// recordRobots updates the robot accounts in the status of the binding. The update is retried on any error,
+// the binding is read again before each retry.
+func (r *PullSecretBindingReconciler) recordRobots(ctx context.Context, bd *goharborv1alpha1.PullSecretBinding, mutate func(st *goharborv1alpha1.PullSecretBindingStatus)) error {
+	current := bd.DeepCopy()
+	if err := retry.OnError(retry.DefaultBackoff, func(error) bool { return true }, func() error {
+		if current == nil {
+			current = &goharborv1alpha1.PullSecretBinding{}
+			if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Name}, current); err != nil {
+				current = nil
+				return err
+			}
+		}
+
+		mutate(&current.Status)
+		if err := r.Status().Update(ctx, current, &client.UpdateOptions{}); err != nil {
+			// Read it again before the retry
+			current = nil
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	current.DeepCopyInto(bd)
+	return nil
+}
+
+// updateRegSecAuths replaces the credentials of the auth entries of the pull secret with the robot account,
+// it returns the content of the pull secret before the update
+func (r *PullSecretBindingReconciler) updateRegSecAuths(ctx context.Context, bd *goharborv1alpha1.PullSecretBinding, keys []string, robot *model.Robot) ([]byte, error) {
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		return nil, fmt.Errorf("get registry secret error: %w", err)
+	}
+
+	previous := regsec.Data[datakey]
+	auths, err := secret.Decode(previous)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		auths.Auths[key] = &secret.Auth{
+			Username: robot.Name,
+			Password: robot.Token,
+			Email:    fmt.Sprintf("%s@goharbor.io", robot.Name),
+		}
+	}
+
+	regsec.Data[datakey] = auths.Encode()
+	if err := r.Client.Update(ctx, regsec, &client.UpdateOptions{}); err != nil {
+		return nil, fmt.Errorf("update registry secret error: %w", err)
+	}
+
+	return previous, nil
+}
+
+// restoreRegSec writes the content back to the pull secret of the binding
+func (r *PullSecretBindingReconciler) restoreRegSec(ctx context.Context, bd *goharborv1alpha1.PullSecretBinding, content []byte) error {
+	regsec := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: bd.Namespace, Name: bd.Annotations[utils.AnnotationRobotSecretRef]}, regsec); err != nil {
+		return fmt.Errorf("get registry secret error: %w", err)
+	}
+
+	regsec.Data[datakey] = content
+	if err := r.Client.Update(ctx, regsec, &client.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update registry secret error: %w", err)
+	}
+
+	return nil
+}
+
+// revokeRetiredRobots deletes the retired robot accounts whose grace period is over, or all of them if the binding is being deleted.
+// It returns the ones still kept. The robot accounts still used by the other bindings of the namespace are left to them.
+func (r *PullSecretBindingReconciler) revokeRetiredRobots(ctx context.Context, log logr.Logger, robots rest.RobotClient, bd *goharborv1alpha1.PullSecretBinding, retired []goharborv1alpha1.RetiredRobot, all bool) ([]goharborv1alpha1.RetiredRobot, error) {
+	now := time.Now()
+	kept := make([]goharborv1alpha1.RetiredRobot, 0, len(retired))
+	var bindings *goharborv1alpha1.PullSecretBindingList
+	for _, rr := range retired {
+		if !all && now.Before(rr.RevokeAfter.Time) {
+			kept = append(kept, rr)
+			continue
+		}
+
+		if bindings == nil {
+			bindings = &goharborv1alpha1.PullSecretBindingList{}
+			if err := r.Client.List(ctx, bindings, client.InNamespace(bd.Namespace)); err != nil {
+				return nil, fmt.Errorf("list bindings error: %w", err)
+			}
+		}
+
+		if robotInUse(bindings, bd, rr.RobotID) {
+			log.Info("retired robot account is still used by other bindings", "robotID", rr.RobotID)
+			continue
+		}
+
+		if err := robots.DeleteRobotAccount(rr.ProjectID, rr.RobotID); err != nil {
+			log.Error(err, "failed to revoke the retired robot account", "projectID", rr.ProjectID, "robotID", rr.RobotID)
+			kept = append(kept, rr)
+			continue
+		}
+
+		log.Info("retired robot account is revoked", "projectID", rr.ProjectID, "robotID", rr.RobotID)
+	}
+
+	return kept, nil
+}
+
+// robotInUse checks if the robot account is used by the bindings other than the given one
+func robotInUse(bindings *goharborv1alpha1.PullSecretBindingList, bd *goharborv1alpha1.PullSecretBinding, robotID int64) bool {
+	for _, other := range bindings.Items {
+		if other.UID == bd.UID {
+			continue
+		}
+
+		if parseIntID(other.Spec.RobotID) == robotID {
+			return true
+		}
+
+		for _, pr := range other.Status.PullRobots {
+			if pr.RobotID == robotID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// replaceNamespaceRobot updates the deprecated robot annotation of the namespace with the robot account of the binding if it still refers the replaced one.
+// The robot account of the namespace is pull-only, it is not replaced by the ones granted more permissions.
+func (r *PullSecretBindingReconciler) replaceNamespaceRobot(ctx context.Context, log logr.Logger, ns *corev1.Namespace, bd *goharborv1alpha1.PullSecretBinding, oldRobotID int64) {
+	if ns.Annotations[utils.AnnotationRobot] != strconv.FormatInt(oldRobotID, 10) || len(bd.Spec.GetPermissions()) > 1 {
+		return
+	}
+
+	ns.Annotations[utils.AnnotationRobot] = bd.Spec.RobotID
+	if err := r.Client.Update(ctx, ns, &client.UpdateOptions{}); err != nil {
+		log.Error(err, "failed to update robot annotation of namespace", "robotID", bd.Spec.RobotID)
+	}
+}
+
+// expiryTime returns the expiry in the status, nil if the robot account never expires
+func expiryTime(expiresAt time.Time) *metav1.Time {
+	if expiresAt.IsZero() {
+		return nil
+	}
+
+	t := metav1.NewTime(expiresAt)
+	return &t
+}
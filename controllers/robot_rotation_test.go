@@ -0,0 +1,264 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/registry/secret"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRotationDue(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	ttl := &goharborv1alpha1.RobotCredentialPolicy{TTL: &metav1.Duration{Duration: 72 * time.Hour}}
+
+	type testcase struct {
+		description string
+		policy      *goharborv1alpha1.RobotCredentialPolicy
+		expiresAt   time.Time
+		expected    bool
+	}
+	tests := []testcase{
+		{
+			description: "robot account never expiring without ttl",
+			policy:      &goharborv1alpha1.RobotCredentialPolicy{},
+		},
+		{
+			description: "robot account never expiring with ttl",
+			policy:      ttl,
+			expected:    true,
+		},
+		{
+			description: "robot account living longer than ttl",
+			policy:      ttl,
+			expiresAt:   now.Add(96 * time.Hour),
+			expected:    true,
+		},
+		{
+			description: "robot account out of rotation window",
+			policy:      ttl,
+			expiresAt:   now.Add(48 * time.Hour),
+		},
+		{
+			description: "robot account in rotation window",
+			policy:      ttl,
+			expiresAt:   now.Add(12 * time.Hour),
+			expected:    true,
+		},
+		{
+			description: "expired robot account",
+			policy:      ttl,
+			expiresAt:   now.Add(-time.Hour),
+			expected:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, rotationDue(tc.policy, tc.expiresAt, now))
+		})
+	}
+}
+
+func TestRobotInUse(t *testing.T) {
+	bd := binding("team", "binding-default", "default", "harbor", "3")
+	withPullRobot := binding("team", "binding-backend", "backend", "harbor", "5")
+	withPullRobot.Status.PullRobots = []goharborv1alpha1.PullRobot{{Project: "shared", ProjectID: 21, RobotID: 121}}
+	// The copy of the binding itself is not another binding
+	self := bd.DeepCopy()
+	self.Name = "renamed"
+
+	type testcase struct {
+		description string
+		bindings    []goharborv1alpha1.PullSecretBinding
+		robotID     int64
+		expected    bool
+	}
+	tests := []testcase{
+		{
+			description: "robot account of the binding only",
+			bindings:    []goharborv1alpha1.PullSecretBinding{bd, *self},
+			robotID:     3,
+		},
+		{
+			description: "robot account shared with another binding",
+			bindings:    []goharborv1alpha1.PullSecretBinding{bd, binding("team", "binding-frontend", "frontend", "harbor", "3")},
+			robotID:     3,
+			expected:    true,
+		},
+		{
+			description: "pull robot account of another binding",
+			bindings:    []goharborv1alpha1.PullSecretBinding{bd, withPullRobot},
+			robotID:     121,
+			expected:    true,
+		},
+		{
+			description: "robot account used by no binding",
+			bindings:    []goharborv1alpha1.PullSecretBinding{bd, withPullRobot},
+			robotID:     7,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, robotInUse(&goharborv1alpha1.PullSecretBindingList{Items: tc.bindings}, &bd, tc.robotID))
+		})
+	}
+}
+
+func TestRevokeRetiredRobots(t *testing.T) {
+	past, future := metav1.NewTime(time.Now().Add(-time.Minute)), metav1.NewTime(time.Now().Add(time.Hour))
+	expired := goharborv1alpha1.RetiredRobot{ProjectID: 12, RobotID: 1, RevokeAfter: past}
+	inGrace := goharborv1alpha1.RetiredRobot{ProjectID: 12, RobotID: 2, RevokeAfter: future}
+	shared := goharborv1alpha1.RetiredRobot{ProjectID: 12, RobotID: 5, RevokeAfter: past}
+
+	type testcase struct {
+		description string
+		retired     []goharborv1alpha1.RetiredRobot
+		all         bool
+		failed      bool
+		calls       []string
+		kept        []goharborv1alpha1.RetiredRobot
+	}
+	tests := []testcase{
+		{
+			description: "robot account in grace period is kept",
+			retired:     []goharborv1alpha1.RetiredRobot{expired, inGrace},
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/1"},
+			kept:        []goharborv1alpha1.RetiredRobot{inGrace},
+		},
+		{
+			description: "all the robot accounts are revoked with the binding",
+			retired:     []goharborv1alpha1.RetiredRobot{expired, inGrace},
+			all:         true,
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/1", "DELETE /api/v2.0/projects/12/robots/2"},
+			kept:        []goharborv1alpha1.RetiredRobot{},
+		},
+		{
+			description: "robot account used by another binding is left to it",
+			retired:     []goharborv1alpha1.RetiredRobot{shared},
+			calls:       []string{},
+			kept:        []goharborv1alpha1.RetiredRobot{},
+		},
+		{
+			description: "failed revocation is retried later",
+			retired:     []goharborv1alpha1.RetiredRobot{expired},
+			failed:      true,
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/1"},
+			kept:        []goharborv1alpha1.RetiredRobot{expired},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			robots, server := newTestHarbor(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+				if tc.failed {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			defer server.Close()
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			other := binding("team", "binding-backend", "backend", "harbor", "5")
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), &bd, &other)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+
+			kept, err := r.revokeRetiredRobots(context.Background(), logf.Log, robots, &bd, tc.retired, tc.all)
+			require.NoError(t, err)
+			require.Equal(t, tc.kept, kept)
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func TestSwapRobot(t *testing.T) {
+	oldAuths := &secret.Object{Auths: map[string]*secret.Auth{"harbor.local": {Username: "robot$old", Password: "old"}}}
+	robot := &model.Robot{ID: 7, Name: "robot$new", Token: "new"}
+
+	type testcase struct {
+		description string
+		persistErr  error
+		username    string
+		calls       []string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "swap is persisted",
+			username:    "robot$new",
+			calls:       []string{},
+		},
+		{
+			description: "pull secret is restored and robot account is deleted if the swap is not persisted",
+			persistErr:  errors.New("conflict"),
+			username:    "robot$old",
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/7"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			robots, server := newTestHarbor(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+			}))
+			defer server.Close()
+
+			bd := binding("team", "binding-default", "default", "harbor", "3")
+			bd.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret"}
+			regsec := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "regsecret"},
+				Data:       map[string][]byte{datakey: oldAuths.Encode()},
+			}
+			c := fake.NewFakeClientWithScheme(newTestScheme(t), &bd, regsec)
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log}
+
+			err := r.swapRobot(context.Background(), logf.Log, robots, &bd, []string{"harbor.local"}, 12, robot, func() error {
+				return tc.persistErr
+			})
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.calls, calls)
+
+			got := &corev1.Secret{}
+			require.NoError(t, c.Get(context.Background(), client.ObjectKey{Namespace: "team", Name: "regsecret"}, got))
+			auths, err := secret.Decode(got.Data[datakey])
+			require.NoError(t, err)
+			require.Equal(t, tc.username, auths.Auths["harbor.local"].Username)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceAccountNames returns the names of the service accounts binding the pull secrets in the namespace.
+// The accounts are selected by the label selector annotation or listed in the service account annotation,
+// the default service account is used if neither is set.
+func (r *NamespaceReconciler) serviceAccountNames(ctx context.Context, ns *corev1.Namespace) ([]string, error) {
+	if value, ok := ns.Annotations[utils.AnnotationAccountSelector]; ok {
+		selector, err := labels.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid annotation %s: %w", utils.AnnotationAccountSelector, err)
+		}
+
+		accounts := &corev1.ServiceAccountList{}
+		if err := r.Client.List(ctx, accounts, client.InNamespace(ns.Name), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("list service accounts error: %w", err)
+		}
+
+		names := make([]string, 0, len(accounts.Items))
+		for _, sa := range accounts.Items {
+			names = append(names, sa.Name)
+		}
+		sort.Strings(names)
+
+		return names, nil
+	}
+
+	names := make([]string, 0)
+	for _, name := range strings.Split(ns.Annotations[utils.AnnotationAccount], ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 && !utils.ContainsString(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		names = append(names, defaultSaName)
+	}
+
+	return names, nil
+}
+
+// unboundServiceAccounts returns the existing service accounts without the bindings of the harbor server configuration.
+// The missing accounts are skipped, they are bound once they are created.
+func (r *NamespaceReconciler) unboundServiceAccounts(ctx context.Context, log logr.Logger, ns *corev1.Namespace, harborCfg string, names []string, bindings *goharborv1alpha1.PullSecretBindingList) ([]string, error) {
+	unbound := make([]string, 0)
+	for _, name := range names {
+		if bindingOfServiceAccount(bindings, harborCfg, name) != nil {
+			continue
+		}
+
+		sa := &corev1.ServiceAccount{}
+		if err := r.Client.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: name}, sa); err != nil {
+			if apierr.IsNotFound(err) {
+				log.Info("service account does not exist yet, skip binding", "serviceAccount", name)
+				continue
+			}
+
+			return nil, fmt.Errorf("get service account %s in namespace %s error: %w", name, ns.Name, err)
+		}
+
+		unbound = append(unbound, name)
+	}
+
+	return unbound, nil
+}
+
+// removeUnselectedPSBs removes the bindings created for the namespace whose service accounts are no longer selected,
+// the bindings created by the users are left as they are
+func (r *NamespaceReconciler) removeUnselectedPSBs(ctx context.Context, log logr.Logger, ns *corev1.Namespace, harborCfg string, names []string, bindings *goharborv1alpha1.PullSecretBindingList) error {
+	for i := range bindings.Items {
+		bd := &bindings.Items[i]
+		if bd.Spec.HarborServerConfig != harborCfg || utils.ContainsString(names, bd.Spec.ServiceAccount) || !metav1.IsControlledBy(bd, ns) {
+			continue
+		}
+
+		log.Info("remove binding of unselected service account", "binding", bd.Name, "serviceAccount", bd.Spec.ServiceAccount)
+		if err := r.Client.Delete(ctx, bd, &client.DeleteOptions{}); err != nil {
+			return fmt.Errorf("remove binding %s error: %w", bd.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// bindingOfServiceAccount returns the binding of the service account to the harbor server configuration
+func bindingOfServiceAccount(bindings *goharborv1alpha1.PullSecretBindingList, harborCfg, sa string) *goharborv1alpha1.PullSecretBinding {
+	for i := range bindings.Items {
+		if bindings.Items[i].Spec.HarborServerConfig == harborCfg && bindings.Items[i].Spec.ServiceAccount == sa {
+			return &bindings.Items[i]
+		}
+	}
+
+	return nil
+}
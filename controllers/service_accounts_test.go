@@ -0,0 +1,205 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestServiceAccountNames(t *testing.T) {
+	type testcase struct {
+		description string
+		annotations map[string]string
+		expected    []string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "default service account is used without annotations",
+			expected:    []string{defaultSaName},
+		},
+		{
+			description: "listed service accounts are deduplicated",
+			annotations: map[string]string{utils.AnnotationAccount: "frontend, backend,frontend,"},
+			expected:    []string{"frontend", "backend"},
+		},
+		{
+			description: "selector takes precedence over the list",
+			annotations: map[string]string{utils.AnnotationAccount: "frontend", utils.AnnotationAccountSelector: "pull=true"},
+			expected:    []string{"backend", "builder"},
+		},
+		{
+			description: "nothing is selected",
+			annotations: map[string]string{utils.AnnotationAccountSelector: "pull=never"},
+			expected:    []string{},
+		},
+		{
+			description: "invalid selector",
+			annotations: map[string]string{utils.AnnotationAccountSelector: "pull in"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			c := fake.NewFakeClient(
+				serviceAccount("team", "frontend", nil),
+				serviceAccount("team", "builder", map[string]string{"pull": "true"}),
+				serviceAccount("team", "backend", map[string]string{"pull": "true"}),
+				serviceAccount("other", "tools", map[string]string{"pull": "true"}),
+			)
+			r := &NamespaceReconciler{Client: c, Log: logf.Log}
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", Annotations: tc.annotations}}
+
+			names, err := r.serviceAccountNames(context.Background(), ns)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, names)
+		})
+	}
+}
+
+func TestUnboundServiceAccounts(t *testing.T) {
+	bindings := &goharborv1alpha1.PullSecretBindingList{
+		Items: []goharborv1alpha1.PullSecretBinding{
+			binding("team", "binding-frontend", "frontend", "harbor", "3"),
+			binding("team", "binding-backend", "backend", "other-harbor", "3"),
+		},
+	}
+	c := fake.NewFakeClient(serviceAccount("team", "frontend", nil), serviceAccount("team", "backend", nil))
+	r := &NamespaceReconciler{Client: c, Log: logf.Log}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team"}}
+
+	// The binding of the other harbor server does not count and the missing service account waits
+	unbound, err := r.unboundServiceAccounts(context.Background(), logf.Log, ns, "harbor", []string{"frontend", "backend", "builder"}, bindings)
+	require.NoError(t, err)
+	require.Equal(t, []string{"backend"}, unbound)
+}
+
+func TestBindServiceAccounts(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", UID: "ns-uid"}}
+	s := newTestScheme(t)
+	c := fake.NewFakeClientWithScheme(s, ns)
+	r := &NamespaceReconciler{Client: c, Log: logf.Log, Scheme: s}
+
+	require.NoError(t, r.bindServiceAccounts(context.Background(), logf.Log, ns, "harbor", []string{"frontend", "backend"}, "team", "12", []string{"shared"}))
+
+	bindings := &goharborv1alpha1.PullSecretBindingList{}
+	require.NoError(t, c.List(context.Background(), bindings, client.InNamespace("team")))
+	require.Len(t, bindings.Items, 2)
+
+	accounts := make([]string, 0, len(bindings.Items))
+	for _, bd := range bindings.Items {
+		accounts = append(accounts, bd.Spec.ServiceAccount)
+		// Each binding creates a robot account of its own once it is reconciled
+		require.Empty(t, bd.Spec.RobotID)
+		require.Equal(t, "12", bd.Spec.ProjectID)
+		require.Equal(t, "team", bd.Annotations[utils.AnnotationBoundProject])
+		require.Equal(t, []string{"shared"}, bd.Spec.PullProjects)
+		require.True(t, metav1.IsControlledBy(&bd, ns))
+	}
+	require.ElementsMatch(t, []string{"frontend", "backend"}, accounts)
+}
+
+func TestRemoveUnselectedPSBs(t *testing.T) {
+	type testcase struct {
+		description string
+		keptRobot   string
+		calls       []string
+	}
+	tests := []testcase{
+		{
+			description: "robot account of the removed binding is revoked",
+			keptRobot:   "5",
+			calls:       []string{"DELETE /api/v2.0/projects/12/robots/3"},
+		},
+		{
+			description: "robot account shared with the kept binding is kept",
+			keptRobot:   "3",
+			calls:       []string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := make([]string, 0)
+			harbor, server := newTestClients(t, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+			}))
+			defer server.Close()
+
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", UID: "ns-uid"}}
+			owner := []metav1.OwnerReference{*metav1.NewControllerRef(ns, corev1.SchemeGroupVersion.WithKind("Namespace"))}
+			kept := binding("team", "binding-frontend", "frontend", "harbor", tc.keptRobot)
+			kept.OwnerReferences = owner
+			kept.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret-frontend"}
+			removed := binding("team", "binding-backend", "backend", "harbor", "3")
+			removed.OwnerReferences = owner
+			removed.Annotations = map[string]string{utils.AnnotationRobotSecretRef: "regsecret-backend"}
+
+			s := newTestScheme(t)
+			c := fake.NewFakeClientWithScheme(s, ns, &kept, &removed)
+			nr := &NamespaceReconciler{Client: c, Log: logf.Log, Scheme: s}
+			bindings := &goharborv1alpha1.PullSecretBindingList{Items: []goharborv1alpha1.PullSecretBinding{kept, removed}}
+
+			// The backend service account is no longer selected
+			require.NoError(t, nr.removeUnselectedPSBs(context.Background(), logf.Log, ns, "harbor", []string{"frontend"}, bindings))
+			left := &goharborv1alpha1.PullSecretBindingList{}
+			require.NoError(t, c.List(context.Background(), left, client.InNamespace("team")))
+			require.Len(t, left.Items, 1)
+
+			// The finalizer of the removed binding revokes its robot account
+			r := &PullSecretBindingReconciler{Client: c, Log: logf.Log, Scheme: s}
+			require.NoError(t, r.deleteExternalResources(context.Background(), logf.Log, harbor, &removed))
+			require.Equal(t, tc.calls, calls)
+		})
+	}
+}
+
+func serviceAccount(namespace, name string, labels map[string]string) runtime.Object {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func binding(namespace, name, sa, hsc, robotID string) goharborv1alpha1.PullSecretBinding {
+	return goharborv1alpha1.PullSecretBinding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(name)},
+		Spec: goharborv1alpha1.PullSecretBindingSpec{
+			HarborServerConfig: hsc,
+			ServiceAccount:     sa,
+			ProjectID:          "12",
+			RobotID:            robotID,
+		},
+	}
+}
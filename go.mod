@@ -12,8 +12,11 @@ require (
 	github.com/go-openapi/validate v0.19.5
 	github.com/onsi/ginkgo v1.11.0
 	github.com/onsi/gomega v1.8.1
+	github.com/prometheus/client_golang v1.1.0
 	github.com/stretchr/testify v1.6.1
 	github.com/umisama/go-regexpcache v0.0.0-20150417035358-2444a542492f
+	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
+	golang.org/x/time v0.0.0-20191024005414-555d28b269f0
 	k8s.io/api v0.18.2
 	k8s.io/apimachinery v0.18.2
 	k8s.io/client-go v0.18.2
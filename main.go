@@ -17,22 +17,26 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	_ "sigs.k8s.io/controller-tools/pkg/crd"
 
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
 	"github.com/szlabs/harbor-automation-4k8s/controllers"
-	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
-	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
 	"github.com/szlabs/harbor-automation-4k8s/webhooks/hsc"
 	"github.com/szlabs/harbor-automation-4k8s/webhooks/pod"
 	// +kubebuilder:scaffold:imports
@@ -54,10 +58,23 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var maxConcurrentReconciles int
+	var maxConcurrentReconcilesPerServer int
+	var clusterName string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 4,
+		"The max number of concurrent reconciles of each controller.")
+	flag.IntVar(&maxConcurrentReconcilesPerServer, "max-concurrent-reconciles-per-server", 2,
+		"The max number of concurrent reconciles of the namespaces and pull secret bindings served by the same Harbor server. "+
+			"The reconciles exceeding it are requeued, so a slow Harbor server can not occupy all the workers. "+
+			"No limit other than max-concurrent-reconciles if it is not positive.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"The name of the cluster, it is used to name the projects created for the namespaces "+
+			"when multiple clusters share the same Harbor server. It is unique for each cluster, "+
+			"derived from the UID of the kube-system namespace if it is not set.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -74,29 +91,54 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The cache is not started yet, the cluster name is read directly from the API server
+	if clusterName, err = resolveClusterName(context.Background(), mgr.GetAPIReader(), clusterName); err != nil {
+		setupLog.Error(err, "unable to resolve the cluster name")
+		os.Exit(1)
+	}
+	setupLog.Info("cluster name of the projects", "cluster", clusterName)
+
+	if err = controllers.SetupIndexes(context.Background(), mgr); err != nil {
+		setupLog.Error(err, "unable to set up field indexes")
+		os.Exit(1)
+	}
 	if err = (&controllers.HarborServerConfigurationReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("HarborServerConfiguration"),
-		Scheme: mgr.GetScheme(),
-		Harbor: legacy.New(),
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("HarborServerConfiguration"),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HarborServerConfiguration")
 		os.Exit(1)
 	}
+	if err = (&controllers.HarborServerReconciler{
+		Client:                  mgr.GetClient(),
+		Log:                     ctrl.Log.WithName("controllers").WithName("HarborServer"),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HarborServer")
+		os.Exit(1)
+	}
 	if err = (&controllers.NamespaceReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Namespace"),
-		Scheme: mgr.GetScheme(),
+		Client:                           mgr.GetClient(),
+		Log:                              ctrl.Log.WithName("controllers").WithName("Namespace"),
+		Scheme:                           mgr.GetScheme(),
+		MaxConcurrentReconciles:          maxConcurrentReconciles,
+		MaxConcurrentReconcilesPerServer: maxConcurrentReconcilesPerServer,
+		ClusterName:                      clusterName,
+		Recorder:                         mgr.GetEventRecorderFor("namespace-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
 		os.Exit(1)
 	}
 	if err = (&controllers.PullSecretBindingReconciler{
-		Client:   mgr.GetClient(),
-		Log:      ctrl.Log.WithName("controllers").WithName("PullSecretBinding"),
-		Scheme:   mgr.GetScheme(),
-		HarborV2: v2.New(),
-		Harbor:   legacy.New(),
+		Client:                           mgr.GetClient(),
+		Log:                              ctrl.Log.WithName("controllers").WithName("PullSecretBinding"),
+		Scheme:                           mgr.GetScheme(),
+		MaxConcurrentReconciles:          maxConcurrentReconciles,
+		MaxConcurrentReconcilesPerServer: maxConcurrentReconcilesPerServer,
+		Recorder:                         mgr.GetEventRecorderFor("pullsecretbinding-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PullSecretBinding")
 		os.Exit(1)
@@ -113,6 +155,10 @@ func main() {
 			Client: mgr.GetClient(),
 			Log:    ctrl.Log.WithName("webhooks").WithName("HarborServerConfigurationValidator"),
 		}})
+	mgr.GetWebhookServer().Register("/validate-hs", &webhook.Admission{
+		Handler: &hsc.HarborServerValidator{
+			Log: ctrl.Log.WithName("webhooks").WithName("HarborServerValidator"),
+		}})
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -121,3 +167,27 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// resolveClusterName returns the cluster name set with the flag, or derives it from the UID of the kube-system namespace,
+// so the clusters sharing the same Harbor server never render the same project names with the default settings
+func resolveClusterName(ctx context.Context, reader client.Reader, name string) (string, error) {
+	if len(name) > 0 {
+		if len(strings.TrimSpace(name)) == 0 {
+			return "", errors.New("empty cluster name")
+		}
+
+		return name, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: metav1.NamespaceSystem}, ns); err != nil {
+		return "", fmt.Errorf("get namespace %s error: %w", metav1.NamespaceSystem, err)
+	}
+
+	if len(ns.UID) == 0 {
+		return "", fmt.Errorf("no UID of namespace %s", metav1.NamespaceSystem)
+	}
+
+	// The first group of the UID is enough to tell the clusters apart and keeps the project names short
+	return fmt.Sprintf("cluster-%s", strings.SplitN(string(ns.UID), "-", 2)[0]), nil
+}
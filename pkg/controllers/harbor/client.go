@@ -5,57 +5,389 @@ import (
 	"fmt"
 
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
 	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func CreateHarborClients(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*v2.Client, *legacy.Client, error) {
-	server, err := createHarborServer(ctx, client, hsc)
+const (
+	defaultCABundleKey = "ca.crt"
+)
+
+// Clients are the clients talking to the harbor server of a harbor server configuration.
+// The client serving each kind of operations is selected with the capabilities detected from the harbor server.
+type Clients struct {
+	V2     *v2.Client
+	Legacy *legacy.Client
+	// Capabilities detected from the harbor server
+	capabilities []string
+	// Indicate if the capabilities have been detected
+	detected bool
+}
+
+// HasCapability checks if the harbor server has the capability.
+// It is assumed to be true if the capabilities are not detected yet.
+func (c *Clients) HasCapability(capability string) bool {
+	if !c.detected {
+		return true
+	}
+
+	return utils.ContainsString(c.capabilities, capability)
+}
+
+// Projects returns the client for managing projects, the legacy project APIs are used by the harbor servers older than v2.1
+func (c *Clients) Projects() (rest.ProjectClient, error) {
+	if c.HasCapability(model.CapabilityProjectAPIV2) {
+		return c.V2, nil
+	}
+
+	if c.HasCapability(model.CapabilityProjectAPI) {
+		return c.Legacy, nil
+	}
+
+	return nil, fmt.Errorf("project management is %w, capability %s is required", model.ErrNotSupported, model.CapabilityProjectAPIV2)
+}
+
+// Robots returns the client for managing the robot accounts of projects
+func (c *Clients) Robots() (rest.RobotClient, error) {
+	if c.HasCapability(model.CapabilityProjectRobot) {
+		return c.Legacy, nil
+	}
+
+	return nil, fmt.Errorf("robot account management is %w, capability %s is required", model.ErrNotSupported, model.CapabilityProjectRobot)
+}
+
+// Members returns the client for managing the members of projects
+func (c *Clients) Members() (rest.MemberClient, error) {
+	if c.HasCapability(model.CapabilityProjectMember) {
+		return c.Legacy, nil
+	}
+
+	return nil, fmt.Errorf("project member management is %w, capability %s is required", model.ErrNotSupported, model.CapabilityProjectMember)
+}
+
+// Labels returns the client for managing the labels of projects
+func (c *Clients) Labels() (rest.LabelClient, error) {
+	return c.Legacy, nil
+}
+
+// Artifacts returns the client for managing the artifacts of projects, the artifact APIs are provided by the v2 API of Harbor 2.x
+func (c *Clients) Artifacts() (rest.ArtifactClient, error) {
+	if c.HasCapability(model.CapabilityProjectAPI) || c.HasCapability(model.CapabilityProjectAPIV2) {
+		return c.V2, nil
+	}
+
+	return nil, fmt.Errorf("artifact management is %w, capability %s is required", model.ErrNotSupported, model.CapabilityProjectAPI)
+}
+
+// Quotas returns the client for managing the quotas of projects
+func (c *Clients) Quotas() (rest.QuotaClient, error) {
+	return c.Legacy, nil
+}
+
+// Immutability returns the client for managing the immutability rules of projects
+func (c *Clients) Immutability() (rest.ImmutabilityClient, error) {
+	return c.Legacy, nil
+}
+
+// SyncProjectSettings updates the settings drifted on the project, the storage limit is updated with the quota API.
+// It returns true if the project is updated.
+func (c *Clients) SyncProjectSettings(name string, settings *model.ProjectSettings) (bool, error) {
+	if settings.IsEmpty() {
+		return false, nil
+	}
+
+	projects, err := c.Projects()
+	if err != nil {
+		return false, err
+	}
+
+	p, err := projects.GetProject(name)
+	if err != nil {
+		return false, fmt.Errorf("sync project settings error: %w", err)
+	}
+
+	projectID := int64(p.ProjectID)
+	var quotas rest.QuotaClient
+	var storageLimit *int64
+	if settings.StorageLimit != nil {
+		if quotas, err = c.Quotas(); err != nil {
+			return false, err
+		}
+
+		limit, err := quotas.GetProjectStorageLimit(projectID)
+		if err != nil {
+			return false, fmt.Errorf("sync project settings error: %w", err)
+		}
+		storageLimit = &limit
+	}
+
+	req := settings.Drift(p, storageLimit)
+	if req == nil {
+		return false, nil
+	}
+
+	if req.Metadata != nil {
+		if err := projects.UpdateProject(projectID, req); err != nil {
+			return false, fmt.Errorf("update project settings error: %w", err)
+		}
+	}
+
+	if req.StorageLimit != nil {
+		if err := quotas.UpdateProjectStorageLimit(projectID, *req.StorageLimit); err != nil {
+			return false, fmt.Errorf("update project storage limit error: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// Registries returns the client for managing the registry endpoints of the proxy caches
+func (c *Clients) Registries() (rest.RegistryClient, error) {
+	if c.HasCapability(model.CapabilityProxyCache) {
+		return c.Legacy, nil
+	}
+
+	return nil, fmt.Errorf("proxy cache is %w, capability %s is required", model.ErrNotSupported, model.CapabilityProxyCache)
+}
+
+// WithContext sets the context of all the clients
+func (c *Clients) WithContext(ctx context.Context) *Clients {
+	c.V2.WithContext(ctx)
+	c.Legacy.WithContext(ctx)
+
+	return c
+}
+
+// CreateHarborClients creates the clients talking to the harbor server of the configuration
+func CreateHarborClients(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*Clients, error) {
+	server, err := CreateHarborServer(ctx, client, hsc)
+	if err != nil {
+		return nil, err
+	}
+
+	harborV2, err := v2.NewWithServer(server)
+	if err != nil {
+		return nil, err
+	}
+
+	harborLegacy, err := legacy.NewWithServer(server)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := &Clients{
+		V2:     harborV2,
+		Legacy: harborLegacy,
+	}
+	if info := hsc.Status.ServerInfo; info != nil && info.Version != "" {
+		clients.capabilities = info.Capabilities
+		clients.detected = true
+	}
+
+	return clients.WithContext(ctx), nil
+}
+
+// CreateHarborLegacyClientWithURL creates the legacy client talking to the specified endpoint of the configuration
+func CreateHarborLegacyClientWithURL(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string) (*legacy.Client, error) {
+	server, err := CreateHarborServerWithURL(ctx, client, hsc, serverURL)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	return v2.NewWithServer(server), legacy.NewWithServer(server), nil
+	return legacy.NewWithServer(server)
 }
 
 func CreateHarborV2Client(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*v2.Client, error) {
-	server, err := createHarborServer(ctx, client, hsc)
+	server, err := CreateHarborServer(ctx, client, hsc)
 	if err != nil {
 		return nil, err
 	}
-	return v2.NewWithServer(server), nil
+	return v2.NewWithServer(server)
 }
 
 func CreateHarborLegacyClient(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*legacy.Client, error) {
-	server, err := createHarborServer(ctx, client, hsc)
+	server, err := CreateHarborServer(ctx, client, hsc)
 	if err != nil {
 		return nil, err
 	}
-	return legacy.NewWithServer(server), nil
+	return legacy.NewWithServer(server)
 }
 
-// Check if the server configuration is valid.
-// That is checking if the admin password secret object is valid.
-func createHarborServer(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*model.HarborServer, error) {
-	// contruct accessCreds from Secret
+// CreateHarborServer checks if the server configuration is valid.
+// That is checking if the admin password secret object, the CA bundle and the client certificate are valid.
+// The server points to the active endpoint of the configuration.
+func CreateHarborServer(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*model.HarborServer, error) {
+	return CreateHarborServerWithURL(ctx, client, hsc, hsc.ActiveServerURL())
+}
 
-	secretNSedName := types.NamespacedName{
-		Namespace: hsc.Spec.AccessCredential.Namespace,
-		Name:      hsc.Spec.AccessCredential.AccessSecretRef,
+// CreateHarborServerWithURL is the same as CreateHarborServer but points to the specified endpoint of the configuration
+func CreateHarborServerWithURL(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string) (*model.HarborServer, error) {
+	return createHarborServer(ctx, client, hsc, serverURL, false)
+}
+
+// CreateBootstrapHarborLegacyClient creates the legacy client talking to the specified endpoint with the access credential,
+// the managed robot account is not used even if it is ready
+func CreateBootstrapHarborLegacyClient(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string) (*legacy.Client, error) {
+	server, err := createHarborServer(ctx, client, hsc, serverURL, true)
+	if err != nil {
+		return nil, err
+	}
+	return legacy.NewWithServer(server)
+}
+
+func createHarborServer(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string, bootstrap bool) (*model.HarborServer, error) {
+	var (
+		cred *model.AccessCred
+		err  error
+	)
+	if !bootstrap {
+		if cred, err = getManagedCred(ctx, client, hsc); err != nil {
+			return nil, err
+		}
+	}
+
+	if cred == nil {
+		// contruct accessCreds from Secret
+		secretNSedName := types.NamespacedName{
+			Namespace: hsc.Spec.AccessCredential.Namespace,
+			Name:      hsc.Spec.AccessCredential.AccessSecretRef,
+		}
+		if cred, err = createAccessCredsFromSecret(ctx, client, hsc.Spec.AccessCredential.Type, secretNSedName); err != nil {
+			return nil, err
+		}
 	}
-	cred, err := createAccessCredsFromSecret(ctx, client, secretNSedName)
+
+	caBundle, err := getCABundle(ctx, client, hsc)
 	if err != nil {
 		return nil, err
 	}
+
 	// put server config into client
-	return model.NewHarborServer(hsc.Spec.ServerURL, cred, hsc.Spec.InSecure), nil
+	server := model.NewHarborServer(serverURL, cred, hsc.Spec.InSecure)
+	server.CABundle = caBundle
+	server.Throttle = throttleFor(hsc, serverURL)
+	server.Owner = hsc.Key()
+
+	if cc := hsc.Spec.ClientCertificate; cc != nil {
+		certNSedName := types.NamespacedName{
+			Namespace: cc.Namespace,
+			Name:      cc.SecretRef,
+		}
+		if server.ClientCertificate, server.ClientKey, err = getClientCertificate(ctx, client, certNSedName); err != nil {
+			return nil, err
+		}
+	}
+
+	if p := hsc.Spec.Proxy; p != nil {
+		if server.Proxy, err = getProxy(ctx, client, p); err != nil {
+			return nil, err
+		}
+	}
+
+	return server, nil
+}
+
+// SecretRefs returns all the secrets referred by the server configuration,
+// including the access credential, the CA bundle, the client certificate, the proxy credential and the registry credential secrets
+func SecretRefs(hsc *goharborv1alpha1.HarborServerConfiguration) []types.NamespacedName {
+	refs := []types.NamespacedName{
+		{
+			Namespace: hsc.Spec.AccessCredential.Namespace,
+			Name:      hsc.Spec.AccessCredential.AccessSecretRef,
+		},
+	}
+
+	if ref := hsc.Spec.CABundleRef; ref != nil && ref.Kind == goharborv1alpha1.CABundleRefKindSecret {
+		refs = append(refs, types.NamespacedName{
+			Namespace: ref.Namespace,
+			Name:      ref.Name,
+		})
+	}
 
+	if cc := hsc.Spec.ClientCertificate; cc != nil {
+		refs = append(refs, types.NamespacedName{
+			Namespace: cc.Namespace,
+			Name:      cc.SecretRef,
+		})
+	}
+
+	if p := hsc.Spec.Proxy; p != nil && p.Credential != nil {
+		refs = append(refs, types.NamespacedName{
+			Namespace: p.Credential.Namespace,
+			Name:      p.Credential.SecretRef,
+		})
+	}
+
+	if hsc.Spec.ManagedCredential != nil {
+		refs = append(refs, ManagedCredentialRef(hsc))
+	}
+
+	for _, pc := range hsc.Spec.ProxyCaches {
+		if pc.Credential != nil {
+			refs = append(refs, types.NamespacedName{
+				Namespace: pc.Credential.Namespace,
+				Name:      pc.Credential.SecretRef,
+			})
+		}
+	}
+
+	return refs
+}
+
+// ConfigMapRefs returns the configmaps referred by the server configuration, that is the CA bundle configmap
+func ConfigMapRefs(hsc *goharborv1alpha1.HarborServerConfiguration) []types.NamespacedName {
+	refs := make([]types.NamespacedName, 0)
+	if ref := hsc.Spec.CABundleRef; ref != nil && ref.Kind == goharborv1alpha1.CABundleRefKindConfigMap {
+		refs = append(refs, types.NamespacedName{
+			Namespace: ref.Namespace,
+			Name:      ref.Name,
+		})
+	}
+
+	return refs
+}
+
+// ManagedCredentialRef returns the secret keeping the robot account managed by the operator,
+// it is in the namespace of the access credential
+func ManagedCredentialRef(hsc *goharborv1alpha1.HarborServerConfiguration) types.NamespacedName {
+	return types.NamespacedName{
+		Namespace: hsc.Spec.AccessCredential.Namespace,
+		Name:      hsc.Spec.ManagedCredential.SecretRef,
+	}
 }
 
-func createAccessCredsFromSecret(ctx context.Context, client client.Client, secretNSedName types.NamespacedName) (*model.AccessCred, error) {
+// getManagedCred returns the credential of the managed robot account, nil is returned if it is not ready
+func getManagedCred(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) (*model.AccessCred, error) {
+	if hsc.Spec.ManagedCredential == nil {
+		return nil, nil
+	}
+
+	sec := &corev1.Secret{}
+	if err := client.Get(ctx, ManagedCredentialRef(hsc), sec); err != nil {
+		if apierr.IsNotFound(err) {
+			// The robot account is not created yet
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("get managed credential secret error: %w", err)
+	}
+
+	cred := &model.AccessCred{Type: goharborv1alpha1.AccessCredentialTypeRobot}
+	if err := cred.FillIn(sec); err != nil {
+		return nil, fmt.Errorf("fill in managed credential secret %s error: %w", ManagedCredentialRef(hsc), err)
+	}
+
+	return cred, nil
+}
+
+func createAccessCredsFromSecret(ctx context.Context, client client.Client, credType string, secretNSedName types.NamespacedName) (*model.AccessCred, error) {
 	accessSecret := &corev1.Secret{}
 	if err := client.Get(ctx, secretNSedName, accessSecret); err != nil {
 		// No matter what errors (including not found) occurred, the server configuration is invalid
@@ -63,10 +395,105 @@ func createAccessCredsFromSecret(ctx context.Context, client client.Client, secr
 	}
 
 	// convert secrets to AccessCred
-	cred := &model.AccessCred{}
+	cred := &model.AccessCred{Type: credType}
 	if err := cred.FillIn(accessSecret); err != nil {
-		return nil, fmt.Errorf("fill in secret error: %w", err)
+		return nil, fmt.Errorf("fill in secret %s error: %w", secretNSedName, err)
 	}
 
 	return cred, nil
 }
+
+// getCABundle returns the CA bundle set inline or kept in the referred Secret/ConfigMap.
+// Nil is returned if no CA bundle is configured.
+func getCABundle(ctx context.Context, client client.Client, hsc *goharborv1alpha1.HarborServerConfiguration) ([]byte, error) {
+	if len(hsc.Spec.CABundle) > 0 {
+		return []byte(hsc.Spec.CABundle), nil
+	}
+
+	ref := hsc.Spec.CABundleRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	key := ref.Key
+	if key == "" {
+		key = defaultCABundleKey
+	}
+
+	namespacedName := types.NamespacedName{
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+	}
+
+	switch ref.Kind {
+	case goharborv1alpha1.CABundleRefKindSecret:
+		sec := &corev1.Secret{}
+		if err := client.Get(ctx, namespacedName, sec); err != nil {
+			return nil, fmt.Errorf("get CA bundle secret error: %w", err)
+		}
+
+		if data, ok := sec.Data[key]; ok {
+			return data, nil
+		}
+	case goharborv1alpha1.CABundleRefKindConfigMap:
+		cm := &corev1.ConfigMap{}
+		if err := client.Get(ctx, namespacedName, cm); err != nil {
+			return nil, fmt.Errorf("get CA bundle configmap error: %w", err)
+		}
+
+		if data, ok := cm.Data[key]; ok {
+			return []byte(data), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported CA bundle reference kind %q", ref.Kind)
+	}
+
+	return nil, fmt.Errorf("key %s is not found in %s %s", key, ref.Kind, namespacedName)
+}
+
+// getClientCertificate returns the certificate and key kept in the referred kubernetes.io/tls secret
+func getClientCertificate(ctx context.Context, client client.Client, secretNSedName types.NamespacedName) ([]byte, []byte, error) {
+	sec := &corev1.Secret{}
+	if err := client.Get(ctx, secretNSedName, sec); err != nil {
+		return nil, nil, fmt.Errorf("get client certificate secret error: %w", err)
+	}
+
+	cert, ok1 := sec.Data[corev1.TLSCertKey]
+	key, ok2 := sec.Data[corev1.TLSPrivateKeyKey]
+	if !(ok1 && ok2) {
+		return nil, nil, fmt.Errorf("client certificate secret %s should contain both %s and %s", secretNSedName, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	return cert, key, nil
+}
+
+func getProxy(ctx context.Context, client client.Client, p *goharborv1alpha1.Proxy) (*ghttp.Proxy, error) {
+	proxy := &ghttp.Proxy{
+		URL:     p.URL,
+		NoProxy: p.NoProxy,
+	}
+
+	if p.Credential == nil {
+		return proxy, nil
+	}
+
+	secretNSedName := types.NamespacedName{
+		Namespace: p.Credential.Namespace,
+		Name:      p.Credential.SecretRef,
+	}
+	sec := &corev1.Secret{}
+	if err := client.Get(ctx, secretNSedName, sec); err != nil {
+		return nil, fmt.Errorf("get proxy credential secret error: %w", err)
+	}
+
+	username, ok1 := sec.Data[corev1.BasicAuthUsernameKey]
+	password, ok2 := sec.Data[corev1.BasicAuthPasswordKey]
+	if !(ok1 && ok2) {
+		return nil, fmt.Errorf("proxy credential secret %s should contain both %s and %s", secretNSedName, corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
+	}
+
+	proxy.Username = string(username)
+	proxy.Password = string(password)
+
+	return proxy, nil
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestClientsProjects(t *testing.T) {
+	v2Client, legacyClient := v2.New(), legacy.New()
+
+	type testcase struct {
+		description string
+		info        *model.ServerInfo
+		expected    interface{}
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "v2 project APIs are used by harbor v2.1",
+			info:        &model.ServerInfo{Version: "v2.1.0"},
+			expected:    v2Client,
+		},
+		{
+			description: "legacy project APIs are used by harbor v2.0",
+			info:        &model.ServerInfo{Version: "v2.0.2"},
+			expected:    legacyClient,
+		},
+		{
+			description: "project APIs are not supported by harbor v1.10",
+			info:        &model.ServerInfo{Version: "v1.10.0"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			caps, err := tc.info.Capabilities()
+			require.NoError(t, err)
+
+			clients := &Clients{V2: v2Client, Legacy: legacyClient, capabilities: caps, detected: true}
+			projects, err := clients.Projects()
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, projects == tc.expected)
+		})
+	}
+}
+
+func TestConfigMapRefs(t *testing.T) {
+	hsc := &goharborv1alpha1.HarborServerConfiguration{}
+	hsc.Spec.AccessCredential = &goharborv1alpha1.AccessCredential{Namespace: "kube-system", AccessSecretRef: "admin"}
+	require.Empty(t, ConfigMapRefs(hsc))
+
+	hsc.Spec.CABundleRef = &goharborv1alpha1.CABundleReference{Kind: goharborv1alpha1.CABundleRefKindConfigMap, Namespace: "kube-system", Name: "ca"}
+	require.Equal(t, []types.NamespacedName{{Namespace: "kube-system", Name: "ca"}}, ConfigMapRefs(hsc))
+	require.NotContains(t, SecretRefs(hsc), types.NamespacedName{Namespace: "kube-system", Name: "ca"})
+
+	hsc.Spec.CABundleRef.Kind = goharborv1alpha1.CABundleRefKindSecret
+	require.Empty(t, ConfigMapRefs(hsc))
+	require.Contains(t, SecretRefs(hsc), types.NamespacedName{Namespace: "kube-system", Name: "ca"})
+}
+
+func TestClientsSyncProjectSettings(t *testing.T) {
+	gib, unlimited, public := int64(1<<30), int64(-1), true
+
+	type testcase struct {
+		description string
+		settings    *model.ProjectSettings
+		updated     bool
+		updates     []string
+	}
+	tests := []testcase{
+		{
+			description: "settings are in sync",
+			settings:    &model.ProjectSettings{StorageLimit: &gib},
+		},
+		{
+			description: "storage limit is updated with the quota API",
+			settings:    &model.ProjectSettings{StorageLimit: &unlimited},
+			updated:     true,
+			updates:     []string{"/api/v2.0/quotas/5"},
+		},
+		{
+			description: "metadata is updated with the project API",
+			settings:    &model.ProjectSettings{Public: &public, StorageLimit: &gib},
+			updated:     true,
+			updates:     []string{"/api/v2.0/projects/1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			updates := make([]string, 0)
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Method == http.MethodPut {
+					updates = append(updates, req.URL.Path)
+					return
+				}
+
+				var body interface{}
+				switch req.URL.Path {
+				case "/api/v2.0/projects":
+					body = []map[string]interface{}{{"project_id": 1, "name": "library", "metadata": map[string]string{"public": "false"}}}
+				case "/api/v2.0/quotas":
+					body = []map[string]interface{}{{"id": 5, "hard": map[string]int64{"storage": gib}}}
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("X-Total-Count", "1")
+				_ = json.NewEncoder(w).Encode(body)
+			}))
+			defer server.Close()
+
+			cred := &model.AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"}
+			legacyClient, err := legacy.NewWithServer(model.NewHarborServer(strings.TrimPrefix(server.URL, "https://"), cred, true))
+			require.NoError(t, err)
+			caps, err := (&model.ServerInfo{Version: "v2.0.2"}).Capabilities()
+			require.NoError(t, err)
+
+			clients := &Clients{V2: v2.New(), Legacy: legacyClient, capabilities: caps, detected: true}
+			updated, err := clients.SyncProjectSettings("library", tc.settings)
+			require.NoError(t, err)
+			require.Equal(t, tc.updated, updated)
+			require.ElementsMatch(t, tc.updates, updates)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// BusyRetryDelay is the delay of requeuing the reconcile rejected by the gate of a busy harbor server
+const BusyRetryDelay = 3 * time.Second
+
+// ReconcileGate limits the concurrent reconciles of each harbor server configuration in a controller,
+// so a slow harbor server only occupies part of the workers and the reconciles of the other servers keep going.
+// The nil gate does not limit the reconciles.
+type ReconcileGate struct {
+	limit int
+
+	lock    sync.Mutex
+	running map[string]int
+}
+
+// NewReconcileGate creates a gate allowing the limit number of concurrent reconciles of each harbor server configuration,
+// nil is returned if the limit is not positive
+func NewReconcileGate(limit int) *ReconcileGate {
+	if limit <= 0 {
+		return nil
+	}
+
+	return &ReconcileGate{
+		limit:   limit,
+		running: make(map[string]int),
+	}
+}
+
+// TryAcquire takes a reconcile slot of the harbor server configuration with the key without waiting,
+// the returned func releases the slot. False is returned if all the slots of the configuration are taken.
+func (g *ReconcileGate) TryAcquire(key string) (func(), bool) {
+	if g == nil {
+		return func() {}, true
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.running[key] >= g.limit {
+		return nil, false
+	}
+	g.running[key]++
+
+	return func() {
+		g.lock.Lock()
+		defer g.lock.Unlock()
+
+		if g.running[key]--; g.running[key] <= 0 {
+			delete(g.running, key)
+		}
+	}, true
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileGate(t *testing.T) {
+	g := NewReconcileGate(2)
+
+	release1, ok := g.TryAcquire("slow")
+	require.True(t, ok)
+	_, ok = g.TryAcquire("slow")
+	require.True(t, ok)
+
+	_, ok = g.TryAcquire("slow")
+	require.False(t, ok, "the slots of the slow server are taken")
+
+	release3, ok := g.TryAcquire("other")
+	require.True(t, ok, "the other server is not blocked by the slow one")
+	release3()
+
+	release1()
+	_, ok = g.TryAcquire("slow")
+	require.True(t, ok, "the released slot is reused")
+
+	var unlimited *ReconcileGate
+	require.Nil(t, NewReconcileGate(0))
+	for i := 0; i < 10; i++ {
+		_, ok := unlimited.TryAcquire("slow")
+		require.True(t, ok)
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	apierr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GetServerConfiguration gets the harbor server referred by the name from the namespace.
+// The HarborServer in the namespace is preferred, then the cluster scoped HarborServerConfiguration.
+// The not found error of the HarborServerConfiguration is returned if neither of them exists.
+func GetServerConfiguration(ctx context.Context, client client.Client, namespace string, name string) (*goharborv1alpha1.HarborServerConfiguration, error) {
+	if len(namespace) > 0 {
+		hs := &goharborv1alpha1.HarborServer{}
+		err := client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, hs)
+		if err == nil {
+			return hs.ToServerConfiguration(), nil
+		}
+
+		// The HarborServer CRD may be not installed yet
+		if !apierr.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return nil, err
+		}
+	}
+
+	hsc := &goharborv1alpha1.HarborServerConfiguration{}
+	// HarborServerConfiguration is cluster scoped resource
+	if err := client.Get(ctx, types.NamespacedName{Name: name}, hsc); err != nil {
+		return nil, err
+	}
+
+	return hsc, nil
+}
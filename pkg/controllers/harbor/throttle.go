@@ -0,0 +1,95 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
+)
+
+const (
+	defaultQPS              = 10
+	defaultBurst            = 20
+	defaultMaxInFlight      = 10
+	defaultFailureThreshold = 5
+	defaultOpenSeconds      = 30
+	// maxThrottleWait is the max time an API call waits for the throttle,
+	// the reconcile is requeued instead of occupying the worker longer
+	maxThrottleWait = 2 * time.Second
+)
+
+// throttles keeps the throttle of each endpoint of the harbor server configurations,
+// all the clients of the same endpoint share its limits
+var throttles sync.Map
+
+type throttleKey struct {
+	hsc       string
+	serverURL string
+}
+
+// throttleFor returns the throttle of the endpoint of the harbor server configuration,
+// a new one is created if the settings are changed
+func throttleFor(hsc *goharborv1alpha1.HarborServerConfiguration, serverURL string) *ghttp.Throttle {
+	key := throttleKey{hsc: hsc.Key(), serverURL: serverURL}
+	opts := throttleOptions(hsc)
+	if t, ok := throttles.Load(key); ok && t.(*ghttp.Throttle).Options() == opts {
+		return t.(*ghttp.Throttle)
+	}
+
+	t := ghttp.NewThrottle(opts)
+	throttles.Store(key, t)
+
+	return t
+}
+
+// Release drops the throttles and the HTTP client of the deleted harbor server configuration, the name is the key of the configuration
+func Release(name string) {
+	ReleaseThrottle(name)
+	ghttp.ReleaseClient(name)
+}
+
+// ReleaseThrottle drops the throttles of the deleted harbor server configuration, the name is the key of the configuration
+func ReleaseThrottle(name string) {
+	throttles.Range(func(key, _ interface{}) bool {
+		if key.(throttleKey).hsc == name {
+			throttles.Delete(key)
+		}
+
+		return true
+	})
+}
+
+func throttleOptions(hsc *goharborv1alpha1.HarborServerConfiguration) ghttp.ThrottleOptions {
+	opts := ghttp.ThrottleOptions{
+		QPS:              defaultQPS,
+		Burst:            defaultBurst,
+		MaxInFlight:      defaultMaxInFlight,
+		MaxWait:          maxThrottleWait,
+		FailureThreshold: defaultFailureThreshold,
+		OpenTimeout:      defaultOpenSeconds * time.Second,
+	}
+
+	if rl := hsc.Spec.RateLimit; rl != nil {
+		if rl.QPS > 0 {
+			opts.QPS = float64(rl.QPS)
+		}
+		if rl.Burst > 0 {
+			opts.Burst = int(rl.Burst)
+		}
+		if rl.MaxInFlight > 0 {
+			opts.MaxInFlight = int(rl.MaxInFlight)
+		}
+	}
+
+	if cb := hsc.Spec.CircuitBreaker; cb != nil {
+		if cb.FailureThreshold > 0 {
+			opts.FailureThreshold = int(cb.FailureThreshold)
+		}
+		if cb.OpenSeconds > 0 {
+			opts.OpenTimeout = time.Duration(cb.OpenSeconds) * time.Second
+		}
+	}
+
+	return opts
+}
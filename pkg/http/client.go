@@ -15,28 +15,232 @@
 package http
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"net"
 	nhttp "net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options of the HTTP client for talking to a specific Harbor server
+type Options struct {
+	// InSecure skips the verification of the server certificate
+	InSecure bool
+	// CABundle is the PEM encoded CA bundle used to verify the server certificate.
+	// The system trust store is used if it is empty.
+	CABundle []byte
+	// ClientCertificate is the PEM encoded certificate presented to the server for mutual TLS authentication
+	ClientCertificate []byte
+	// ClientKey is the PEM encoded private key of the client certificate
+	ClientKey []byte
+	// Proxy used to reach the server, the proxy settings of the environment are used if it is nil
+	Proxy *Proxy
+	// Owner identifies the server the client belongs to. The client is cached for the owner and
+	// replaced once the options change, it is not cached if the owner is empty.
+	Owner string
+}
+
+// Proxy settings of the HTTP client
+type Proxy struct {
+	// URL of the HTTP(S) proxy, the server is reached directly if it is empty
+	URL string
+	// NoProxy is the list of hosts, domains, IPs or CIDRs which are reached directly
+	NoProxy []string
+	// Username and Password authenticating to the proxy
+	Username string
+	Password string
+}
+
+// key identifies the options, the client of the owner is reused until the options change
+func (o *Options) key() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "insecure=%t;", o.InSecure)
+	for _, data := range [][]byte{o.CABundle, o.ClientCertificate, o.ClientKey} {
+		_, _ = fmt.Fprintf(h, "%d:", len(data))
+		_, _ = h.Write(data)
+	}
+	if p := o.Proxy; p != nil {
+		for _, v := range append([]string{p.URL, p.Username, p.Password}, p.NoProxy...) {
+			_, _ = fmt.Fprintf(h, "%d:%s", len(v), v)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedClient is the client of an owner with the key of the options it is built from
+type cachedClient struct {
+	key    string
+	client *nhttp.Client
+}
+
+var (
+	// clients caches the HTTP clients by the owner to reuse the connections of the same server
+	clients = make(map[string]*cachedClient)
+	lock    sync.Mutex
 )
 
-// insecureTransport provides a insecure RoundTripper and disable the HTTP2 try
-var insecureTransport nhttp.RoundTripper = &nhttp.Transport{
-	Proxy: nhttp.ProxyFromEnvironment,
-	DialContext: (&net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-		DualStack: true,
-	}).DialContext,
-	TLSClientConfig: &tls.Config{
-		InsecureSkipVerify: true,
-	},
-	MaxIdleConns:          100,
-	IdleConnTimeout:       90 * time.Second,
-	TLSHandshakeTimeout:   10 * time.Second,
-	ExpectContinueTimeout: 1 * time.Second,
-}
-
-// Client is an insecure HTTP client
-var Client *nhttp.Client = &nhttp.Client{Transport: insecureTransport}
+// NewClient returns a HTTP client with a transport built from the options.
+// The client is shared by the callers of the same owner until the options change.
+func NewClient(opts *Options) (*nhttp.Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if len(opts.Owner) == 0 {
+		return newClient(opts)
+	}
+
+	key := opts.key()
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	cached, ok := clients[opts.Owner]
+	if ok && cached.key == key {
+		return cached.client, nil
+	}
+
+	c, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		// The options are changed, e.g. the CA bundle or the client certificate is rotated
+		cached.client.CloseIdleConnections()
+	}
+	clients[opts.Owner] = &cachedClient{key: key, client: c}
+
+	return c, nil
+}
+
+// ReleaseClient drops the cached client of the owner and closes its idle connections
+func ReleaseClient(owner string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if cached, ok := clients[owner]; ok {
+		cached.client.CloseIdleConnections()
+		delete(clients, owner)
+	}
+}
+
+// Validate checks the TLS and proxy settings of the options without building a client
+func (o *Options) Validate() error {
+	if _, err := newTLSConfig(o); err != nil {
+		return err
+	}
+
+	_, err := newProxyFunc(o.Proxy)
+
+	return err
+}
+
+func newClient(opts *Options) (*nhttp.Client, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxyFunc(opts.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nhttp.Client{Transport: newTransport(tlsConfig, proxy)}, nil
+}
+
+// newTransport provides a RoundTripper with the given TLS config and disable the HTTP2 try
+func newTransport(tlsConfig *tls.Config, proxy func(*nhttp.Request) (*url.URL, error)) nhttp.RoundTripper {
+	return &nhttp.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			DualStack: true,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newProxyFunc returns the proxy function of the transport.
+// The proxy settings of the environment are used if no proxy is configured.
+func newProxyFunc(proxy *Proxy) (func(*nhttp.Request) (*url.URL, error), error) {
+	if proxy == nil {
+		return nhttp.ProxyFromEnvironment, nil
+	}
+
+	if len(proxy.URL) == 0 {
+		// Reach the server directly
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(proxy.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("invalid proxy URL %s: the scheme should be http or https", proxy.URL)
+	}
+	if len(proxy.Username) > 0 {
+		proxyURL.User = url.UserPassword(proxy.Username, proxy.Password)
+	}
+
+	proxyFunc := (&httpproxy.Config{
+		HTTPProxy:  proxyURL.String(),
+		HTTPSProxy: proxyURL.String(),
+		NoProxy:    strings.Join(proxy.NoProxy, ","),
+	}).ProxyFunc()
+
+	return func(req *nhttp.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+func newTLSConfig(opts *Options) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if len(opts.ClientCertificate) > 0 || len(opts.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(opts.ClientCertificate, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.InSecure {
+		tlsConfig.InsecureSkipVerify = true
+		return tlsConfig, nil
+	}
+
+	if len(opts.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			// Fall back to an empty pool when the system pool is not available
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(opts.CABundle) {
+			return nil, errors.New("no valid PEM encoded certificate found in the CA bundle")
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,268 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"io"
+	nhttp "net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ThrottleOptions of the requests sent to a specific Harbor server
+type ThrottleOptions struct {
+	// QPS is the sustained number of requests per second, no rate limit if it is not positive
+	QPS float64
+	// Burst is the max number of requests sent at once
+	Burst int
+	// MaxInFlight is the max number of concurrent requests, no limit if it is not positive
+	MaxInFlight int
+	// MaxWait is the max time a request waits for the rate limiter or the in-flight slot before being rejected
+	MaxWait time.Duration
+	// FailureThreshold is the number of consecutive failures opening the circuit, no circuit breaker if it is not positive
+	FailureThreshold int
+	// OpenTimeout is how long the circuit keeps open before a trial request is sent
+	OpenTimeout time.Duration
+}
+
+// ThrottledError is returned when the request is rejected by the throttle without being sent to the server
+type ThrottledError struct {
+	// Reason of rejecting the request
+	Reason string
+	// RetryAfter is the suggested delay before retrying
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("request throttled: %s, retry after %s", e.Reason, e.RetryAfter)
+}
+
+// Throttle limits the rate and the concurrency of the requests sent to a Harbor server,
+// and stops sending requests when the server keeps failing.
+type Throttle struct {
+	opts     ThrottleOptions
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+
+	lock     sync.Mutex
+	failures int
+	// openedAt is the time the circuit opened, zero if the circuit is closed
+	openedAt time.Time
+	// trial indicates a trial request is being sent when the circuit is half open
+	trial bool
+}
+
+// NewThrottle creates a throttle with the options
+func NewThrottle(opts ThrottleOptions) *Throttle {
+	t := &Throttle{opts: opts}
+	if opts.QPS > 0 {
+		burst := opts.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(opts.QPS), burst)
+	}
+	if opts.MaxInFlight > 0 {
+		t.inFlight = make(chan struct{}, opts.MaxInFlight)
+	}
+
+	return t
+}
+
+// Options returns the options of the throttle
+func (t *Throttle) Options() ThrottleOptions {
+	return t.opts
+}
+
+// Wrap returns a copy of the HTTP client whose requests are throttled
+func (t *Throttle) Wrap(c *nhttp.Client) *nhttp.Client {
+	next := c.Transport
+	if next == nil {
+		next = nhttp.DefaultTransport
+	}
+
+	wrapped := *c
+	wrapped.Transport = &throttledTransport{throttle: t, next: next}
+
+	return &wrapped
+}
+
+type throttledTransport struct {
+	throttle *Throttle
+	next     nhttp.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (tt *throttledTransport) RoundTrip(req *nhttp.Request) (*nhttp.Response, error) {
+	t := tt.throttle
+	trial, err := t.allow()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.acquire(req); err != nil {
+		if trial {
+			t.abortTrial()
+		}
+		return nil, err
+	}
+
+	res, err := tt.next.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// Canceled by the caller, it says nothing about the server
+		t.release()
+		if trial {
+			t.abortTrial()
+		}
+		return res, err
+	}
+
+	t.record(err == nil && res.StatusCode < nhttp.StatusInternalServerError)
+
+	if err != nil || res.Body == nil {
+		t.release()
+		return res, err
+	}
+
+	// Keep the in-flight slot until the body is consumed and closed by the caller
+	res.Body = &releasingBody{ReadCloser: res.Body, release: t.release}
+
+	return res, nil
+}
+
+// releasingBody releases the in-flight slot of the request when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close implements io.Closer
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+
+	return err
+}
+
+// allow checks if the circuit allows sending the request, it returns true if the request is a trial one
+func (t *Throttle) allow() (bool, error) {
+	if t.opts.FailureThreshold <= 0 {
+		return false, nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.openedAt.IsZero() {
+		return false, nil
+	}
+
+	if wait := time.Until(t.openedAt.Add(t.opts.OpenTimeout)); wait > 0 {
+		return false, &ThrottledError{Reason: "circuit breaker is open", RetryAfter: wait}
+	}
+
+	if t.trial {
+		return false, &ThrottledError{Reason: "circuit breaker is half open", RetryAfter: t.opts.OpenTimeout}
+	}
+
+	t.trial = true
+
+	return true, nil
+}
+
+func (t *Throttle) abortTrial() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.trial = false
+}
+
+// record records the result of the request for the circuit breaker
+func (t *Throttle) record(success bool) {
+	if t.opts.FailureThreshold <= 0 {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if success {
+		t.failures = 0
+		t.openedAt = time.Time{}
+		t.trial = false
+		return
+	}
+
+	t.failures++
+	if t.trial || t.failures >= t.opts.FailureThreshold {
+		t.openedAt = time.Now()
+		t.trial = false
+	}
+}
+
+// acquire waits for the rate limiter and the in-flight slot,
+// the request is rejected if it has to wait longer than the max wait time
+func (t *Throttle) acquire(req *nhttp.Request) error {
+	if t.limiter != nil {
+		r := t.limiter.Reserve()
+		delay := r.Delay()
+		if delay > t.opts.MaxWait {
+			r.Cancel()
+			return &ThrottledError{Reason: "rate limit exceeded", RetryAfter: delay}
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				r.Cancel()
+				return req.Context().Err()
+			}
+		}
+	}
+
+	if t.inFlight != nil {
+		select {
+		case t.inFlight <- struct{}{}:
+			return nil
+		default:
+		}
+
+		timer := time.NewTimer(t.opts.MaxWait)
+		defer timer.Stop()
+
+		select {
+		case t.inFlight <- struct{}{}:
+		case <-timer.C:
+			return &ThrottledError{Reason: "too many requests in flight", RetryAfter: t.opts.MaxWait}
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+
+	return nil
+}
+
+func (t *Throttle) release() {
+	if t.inFlight != nil {
+		<-t.inFlight
+	}
+}
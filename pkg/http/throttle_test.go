@@ -0,0 +1,139 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"errors"
+	nhttp "net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		if failing {
+			w.WriteHeader(nhttp.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(nhttp.StatusOK)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name    string
+		opts    ThrottleOptions
+		failing bool
+		// results of the sequential requests, true means the request is rejected by the throttle
+		throttled []bool
+	}{
+		{
+			name:      "no limit",
+			opts:      ThrottleOptions{},
+			throttled: []bool{false, false, false},
+		},
+		{
+			name:      "rate limited",
+			opts:      ThrottleOptions{QPS: 1, Burst: 2},
+			throttled: []bool{false, false, true},
+		},
+		{
+			name:      "circuit opened",
+			opts:      ThrottleOptions{FailureThreshold: 2, OpenTimeout: time.Minute},
+			failing:   true,
+			throttled: []bool{false, false, true},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			failing = c.failing
+			client := NewThrottle(c.opts).Wrap(server.Client())
+
+			for i, throttled := range c.throttled {
+				res, err := client.Get(server.URL)
+				var te *ThrottledError
+				require.Equal(t, throttled, errors.As(err, &te), "request %d", i)
+				if throttled {
+					require.True(t, te.RetryAfter > 0)
+					continue
+				}
+
+				require.NoError(t, err)
+				_ = res.Body.Close()
+			}
+		})
+	}
+}
+
+func TestThrottle_HalfOpen(t *testing.T) {
+	failing := true
+	server := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		if failing {
+			w.WriteHeader(nhttp.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(nhttp.StatusOK)
+	}))
+	defer server.Close()
+
+	throttle := NewThrottle(ThrottleOptions{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond})
+	client := throttle.Wrap(server.Client())
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+
+	_, err = client.Get(server.URL)
+	require.Error(t, err)
+
+	// The trial request closes the circuit once the server recovers
+	failing = false
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		res, err = client.Get(server.URL)
+		require.NoError(t, err)
+		_ = res.Body.Close()
+	}
+}
+
+func TestThrottle_InFlightUntilBodyClosed(t *testing.T) {
+	server := httptest.NewServer(nhttp.HandlerFunc(func(w nhttp.ResponseWriter, r *nhttp.Request) {
+		w.WriteHeader(nhttp.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	throttle := NewThrottle(ThrottleOptions{MaxInFlight: 1, MaxWait: 10 * time.Millisecond})
+	client := throttle.Wrap(server.Client())
+
+	res, err := client.Get(server.URL)
+	require.NoError(t, err)
+
+	// The slot is held while the body of the first response is not closed
+	_, err = client.Get(server.URL)
+	var te *ThrottledError
+	require.True(t, errors.As(err, &te))
+
+	require.NoError(t, res.Body.Close())
+	require.NoError(t, res.Body.Close())
+
+	res, err = client.Get(server.URL)
+	require.NoError(t, err)
+	_ = res.Body.Close()
+}
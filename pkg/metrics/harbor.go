@@ -0,0 +1,170 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	namespace = "harbor_automation"
+	subsystem = "harbor_server"
+
+	hscLabel       = "hsc"
+	componentLabel = "component"
+	endpointLabel  = "endpoint"
+)
+
+var (
+	// ServerHealth is 1 if the harbor server of the configuration is healthy, otherwise 0
+	ServerHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "healthy",
+		Help:      "Whether the Harbor server of the HarborServerConfiguration is healthy (1) or not (0).",
+	}, []string{hscLabel})
+
+	// ComponentHealth is 1 if the component of the harbor server is healthy, otherwise 0
+	ComponentHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "component_healthy",
+		Help:      "Whether the component of the Harbor server is healthy (1) or not (0).",
+	}, []string{hscLabel, componentLabel})
+
+	// HealthCheckDuration is the duration of the last health check of the harbor server
+	HealthCheckDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "health_check_duration_seconds",
+		Help:      "Duration of the last health check of the Harbor server in seconds.",
+	}, []string{hscLabel})
+
+	// LastSuccessfulHealthCheck is the unix timestamp of the last successful health check of the harbor server
+	LastSuccessfulHealthCheck = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "last_successful_health_check_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful health check of the Harbor server.",
+	}, []string{hscLabel})
+
+	// EndpointHealth is 1 if the endpoint of the harbor server is healthy, otherwise 0
+	EndpointHealth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "endpoint_healthy",
+		Help:      "Whether the endpoint of the Harbor server is healthy (1) or not (0).",
+	}, []string{hscLabel, endpointLabel})
+
+	// components keeps the components reported for each configuration to drop the stale series
+	components = newLabelSet(ComponentHealth)
+	// endpoints keeps the endpoints reported for each configuration to drop the stale series
+	endpoints = newLabelSet(EndpointHealth)
+)
+
+func init() {
+	metrics.Registry.MustRegister(ServerHealth, ComponentHealth, HealthCheckDuration, LastSuccessfulHealthCheck, EndpointHealth)
+}
+
+// RecordHealthCheck records the result of the health check of the harbor server.
+// The componentHealth is nil if the health check is failed.
+func RecordHealthCheck(hsc string, healthy bool, componentHealth map[string]bool, duration time.Duration) {
+	ServerHealth.WithLabelValues(hsc).Set(boolToFloat(healthy))
+	HealthCheckDuration.WithLabelValues(hsc).Set(duration.Seconds())
+
+	if componentHealth == nil {
+		// The status of components is unknown
+		components.reset(hsc, nil)
+		return
+	}
+
+	LastSuccessfulHealthCheck.WithLabelValues(hsc).SetToCurrentTime()
+
+	names := make([]string, 0, len(componentHealth))
+	for name, ok := range componentHealth {
+		ComponentHealth.WithLabelValues(hsc, name).Set(boolToFloat(ok))
+		names = append(names, name)
+	}
+	components.reset(hsc, names)
+}
+
+// RecordEndpointHealth records the health of each endpoint of the harbor server
+func RecordEndpointHealth(hsc string, endpointHealth map[string]bool) {
+	names := make([]string, 0, len(endpointHealth))
+	for endpoint, ok := range endpointHealth {
+		EndpointHealth.WithLabelValues(hsc, endpoint).Set(boolToFloat(ok))
+		names = append(names, endpoint)
+	}
+	endpoints.reset(hsc, names)
+}
+
+// DeleteServer removes all the series of the configuration
+func DeleteServer(hsc string) {
+	ServerHealth.DeleteLabelValues(hsc)
+	HealthCheckDuration.DeleteLabelValues(hsc)
+	LastSuccessfulHealthCheck.DeleteLabelValues(hsc)
+	components.reset(hsc, nil)
+	endpoints.reset(hsc, nil)
+}
+
+// labelSet keeps the values of the second label of the vector for each configuration
+type labelSet struct {
+	vec   *prometheus.GaugeVec
+	lock  sync.Mutex
+	names map[string][]string
+}
+
+func newLabelSet(vec *prometheus.GaugeVec) *labelSet {
+	return &labelSet{
+		vec:   vec,
+		names: make(map[string][]string),
+	}
+}
+
+// reset replaces the label values of the configuration and removes the series of the stale ones
+func (c *labelSet) reset(hsc string, names []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	current := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		current[name] = struct{}{}
+	}
+
+	for _, name := range c.names[hsc] {
+		if _, ok := current[name]; !ok {
+			c.vec.DeleteLabelValues(hsc, name)
+		}
+	}
+
+	if len(names) == 0 {
+		delete(c.names, hsc)
+		return
+	}
+
+	c.names[hsc] = names
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,74 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordHealthCheck(t *testing.T) {
+	RecordHealthCheck("hsc1", true, map[string]bool{"core": true, "registry": true}, time.Second)
+	require.Equal(t, float64(1), testutil.ToFloat64(ServerHealth.WithLabelValues("hsc1")))
+	require.Equal(t, float64(1), testutil.ToFloat64(HealthCheckDuration.WithLabelValues("hsc1")))
+	require.NotZero(t, testutil.ToFloat64(LastSuccessfulHealthCheck.WithLabelValues("hsc1")))
+	require.Equal(t, 2, count(ComponentHealth))
+
+	// The stale component is removed
+	RecordHealthCheck("hsc1", false, map[string]bool{"core": false}, time.Second)
+	require.Equal(t, float64(0), testutil.ToFloat64(ServerHealth.WithLabelValues("hsc1")))
+	require.Equal(t, float64(0), testutil.ToFloat64(ComponentHealth.WithLabelValues("hsc1", "core")))
+	require.Equal(t, 1, count(ComponentHealth))
+
+	// The components are unknown if the check is failed
+	RecordHealthCheck("hsc1", false, nil, time.Second)
+	require.Equal(t, 0, count(ComponentHealth))
+
+	DeleteServer("hsc1")
+	require.Equal(t, 0, count(ServerHealth))
+	require.Equal(t, 0, count(LastSuccessfulHealthCheck))
+}
+
+func TestRecordEndpointHealth(t *testing.T) {
+	RecordEndpointHealth("hsc1", map[string]bool{"https://a": true, "https://b": false})
+	require.Equal(t, float64(1), testutil.ToFloat64(EndpointHealth.WithLabelValues("hsc1", "https://a")))
+	require.Equal(t, float64(0), testutil.ToFloat64(EndpointHealth.WithLabelValues("hsc1", "https://b")))
+
+	// The removed endpoint is dropped
+	RecordEndpointHealth("hsc1", map[string]bool{"https://a": true})
+	require.Equal(t, 1, count(EndpointHealth))
+
+	DeleteServer("hsc1")
+	require.Equal(t, 0, count(EndpointHealth))
+}
+
+func count(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	n := 0
+	for range ch {
+		n++
+	}
+
+	return n
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strings"
 )
 
 type Object struct {
@@ -40,3 +41,22 @@ func (o *Object) Encode() []byte {
 
 	return bytes
 }
+
+// Decode decodes the content of the docker config json secret
+func Decode(data []byte) (*Object, error) {
+	o := &Object{}
+	if err := json.Unmarshal(data, o); err != nil {
+		return nil, fmt.Errorf("decode docker config json error: %w", err)
+	}
+
+	if o.Auths == nil {
+		o.Auths = make(map[string]*Auth)
+	}
+
+	return o, nil
+}
+
+// ProjectKey returns the key of the auth entry matching the images of the project in the registry
+func ProjectKey(registry, project string) string {
+	return strings.TrimSuffix(registry, "/") + "/" + project
+}
@@ -0,0 +1,90 @@
+package secret
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	type testcase struct {
+		description string
+		data        string
+		expected    map[string]*Auth
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "docker config json",
+			data:        `{"auths":{"harbor.local":{"username":"robot$team","password":"secret","email":"robot$team@goharbor.io"}}}`,
+			expected: map[string]*Auth{
+				"harbor.local": {Username: "robot$team", Password: "secret", Email: "robot$team@goharbor.io"},
+			},
+		},
+		{
+			description: "no auths",
+			data:        `{}`,
+			expected:    map[string]*Auth{},
+		},
+		{
+			description: "invalid json",
+			data:        `{"auths":`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			o, err := Decode([]byte(tc.data))
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, o.Auths)
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	o := &Object{Auths: map[string]*Auth{
+		"harbor.local":        {Username: "robot$team", Password: "secret"},
+		"harbor.local/shared": {Username: "robot$shared", Password: "shared-secret"},
+	}}
+
+	decoded, err := Decode(o.Encode())
+	require.NoError(t, err)
+	require.Equal(t, o.Auths, decoded.Auths)
+	require.Equal(t, base64.StdEncoding.EncodeToString([]byte("robot$shared:shared-secret")), decoded.Auths["harbor.local/shared"].Auth)
+}
+
+func TestProjectKey(t *testing.T) {
+	type testcase struct {
+		description string
+		registry    string
+		project     string
+		expected    string
+	}
+	tests := []testcase{
+		{
+			description: "registry host",
+			registry:    "harbor.local",
+			project:     "shared",
+			expected:    "harbor.local/shared",
+		},
+		{
+			description: "registry with trailing slash",
+			registry:    "harbor.local:8443/",
+			project:     "shared",
+			expected:    "harbor.local:8443/shared",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			require.Equal(t, tc.expected, ProjectKey(tc.registry, tc.project))
+		})
+	}
+}
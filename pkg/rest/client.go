@@ -0,0 +1,117 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"time"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/legacy"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	v2 "github.com/szlabs/harbor-automation-4k8s/pkg/rest/v2"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
+)
+
+// ProjectClient manages the harbor projects
+type ProjectClient interface {
+	// EnsureProject ensures the project exists and returns its ID, the project is created with the settings
+	EnsureProject(name string, settings *model.ProjectSettings) (int64, error)
+	// UpdateProject updates the project with the request
+	UpdateProject(projectID int64, req *v2models.ProjectReq) error
+	// GetProject gets the project by name, model.ErrNotFound is returned if it does not exist
+	GetProject(name string) (*v2models.Project, error)
+	// GetProjectByID gets the project by ID, model.ErrNotFound is returned if it does not exist
+	GetProjectByID(projectID int64) (*v2models.Project, error)
+	// DeleteProject deletes the project by name
+	DeleteProject(name string) error
+	// EnsureProxyCacheProject ensures the proxy cache project of the registry endpoint exists and returns its ID
+	EnsureProxyCacheProject(name string, registryID int64, public bool) (int64, error)
+	// IsProjectDeletable checks if the project can be deleted, the reason is returned if it can not
+	IsProjectDeletable(projectID int64) (bool, string, error)
+}
+
+// RobotClient manages the robot accounts of the harbor projects
+type RobotClient interface {
+	// CreateRobotAccount creates a robot account with the access on the project, it expires after the TTL or never expires if the TTL is zero
+	CreateRobotAccount(projectID int64, access []model.Permission, ttl time.Duration) (*model.Robot, error)
+	// CreatePullRobotAccount creates a robot account which can only pull the images of the project, it expires like CreateRobotAccount
+	CreatePullRobotAccount(projectID int64, ttl time.Duration) (*model.Robot, error)
+	// GetRobotAccount gets the robot account of the project, model.ErrNotFound is returned if it does not exist
+	GetRobotAccount(projectID, robotID int64) (*model.Robot, error)
+	// GetRobotAccountWithAccess gets the robot account with its access in one request, it requires Harbor v2.2 or later
+	GetRobotAccountWithAccess(robotID int64) (*model.Robot, error)
+	// DeleteRobotAccount deletes the robot account of the project
+	DeleteRobotAccount(projectID, robotID int64) error
+}
+
+// MemberClient manages the members of the harbor projects
+type MemberClient interface {
+	// ListProjectMembers lists all the members of the project
+	ListProjectMembers(projectID int64) ([]*models.ProjectMemberEntity, error)
+	// AddProjectMember adds the user or group to the project, the group is of the specified group type
+	AddProjectMember(projectID int64, member *model.ProjectMember, groupType int64) error
+	// UpdateProjectMemberRole changes the role of the project member
+	UpdateProjectMemberRole(projectID, memberID, roleID int64) error
+	// DeleteProjectMember removes the member from the project
+	DeleteProjectMember(projectID, memberID int64) error
+	// GetCurrentUser gets the user authenticated with the access credential
+	GetCurrentUser() (*models.User, error)
+}
+
+// LabelClient manages the labels of the harbor projects
+type LabelClient interface {
+	// EnsureProjectLabel ensures the project level label exists in the project and returns its ID
+	EnsureProjectLabel(projectID int64, name, description string) (int64, error)
+}
+
+// ArtifactClient manages the artifacts of the harbor projects
+type ArtifactClient interface {
+	// LabelProjectArtifacts attaches the label to all the artifacts of the project, it returns the number of the newly labelled artifacts
+	LabelProjectArtifacts(projectName string, labelID int64) (int, error)
+}
+
+// QuotaClient manages the quotas of the harbor projects
+type QuotaClient interface {
+	// GetProjectStorageLimit returns the storage limit of the project in bytes, -1 means unlimited
+	GetProjectStorageLimit(projectID int64) (int64, error)
+	// UpdateProjectStorageLimit updates the storage limit of the project in bytes, -1 means unlimited
+	UpdateProjectStorageLimit(projectID int64, limit int64) error
+	// FreezeProjectStorage limits the storage of the project to the used storage, it returns the limit in bytes
+	FreezeProjectStorage(projectID int64) (int64, error)
+}
+
+// ImmutabilityClient manages the immutability rules of the harbor projects
+type ImmutabilityClient interface {
+	// EnsureProjectImmutable ensures all the tags of the project are immutable, it returns true if the rule is added
+	EnsureProjectImmutable(projectID int64) (bool, error)
+}
+
+// RegistryClient manages the registry endpoints of the harbor server
+type RegistryClient interface {
+	// PingRegistry checks the registry can be reached from the harbor server with the credential
+	PingRegistry(reg *model.Registry) error
+	// EnsureRegistry creates or updates the registry endpoint with the name, it returns the ID of the endpoint
+	EnsureRegistry(reg *model.Registry) (int64, error)
+}
+
+var _ ProjectClient = (*v2.Client)(nil)
+var _ ProjectClient = (*legacy.Client)(nil)
+var _ RobotClient = (*legacy.Client)(nil)
+var _ MemberClient = (*legacy.Client)(nil)
+var _ LabelClient = (*legacy.Client)(nil)
+var _ ArtifactClient = (*v2.Client)(nil)
+var _ QuotaClient = (*legacy.Client)(nil)
+var _ ImmutabilityClient = (*legacy.Client)(nil)
+var _ RegistryClient = (*legacy.Client)(nil)
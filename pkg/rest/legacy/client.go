@@ -18,14 +18,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/go-logr/logr"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 	ctrl "sigs.k8s.io/controller-runtime"
 
-	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
@@ -40,8 +39,6 @@ type Client struct {
 	timeout time.Duration
 	// Context for doing client connection
 	context context.Context
-	// Insecure HTTP client
-	insecureClient *http.Client
 	// Harbor API client
 	harborClient *model.HarborClient
 	// Logger
@@ -52,30 +49,34 @@ type Client struct {
 func New() *Client {
 	// Initialize with default settings
 	return &Client{
-		timeout:        30 * time.Second,
-		context:        context.Background(),
-		insecureClient: ghttp.Client,
-		log:            ctrl.Log.WithName("legacy").WithName("client"),
+		timeout: 30 * time.Second,
+		context: context.Background(),
+		log:     ctrl.Log.WithName("legacy").WithName("client"),
 	}
 }
 
 // NewWithServer new client with provided server
-func NewWithServer(s *model.HarborServer) *Client {
+func NewWithServer(s *model.HarborServer) (*Client, error) {
+	hc, err := s.Client()
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize with default settings
 	c := New()
 	c.server = s
-	c.harborClient = s.Client()
+	c.harborClient = hc
 
-	return c
+	return c, nil
 }
 
-func (c *Client) WithServer(s *model.HarborServer) *Client {
-	if s != nil {
-		c.server = s
-		c.harborClient = s.Client()
+// CredentialType returns the type of the credential used to talk to the harbor server
+func (c *Client) CredentialType() string {
+	if c.server == nil || c.server.AccessCred == nil || len(c.server.AccessCred.Type) == 0 {
+		return goharborv1alpha1.AccessCredentialTypeBasic
 	}
 
-	return c
+	return c.server.AccessCred.Type
 }
 
 func (c *Client) WithContext(ctx context.Context) *Client {
@@ -93,8 +94,7 @@ func (c *Client) WithTimeout(timeout time.Duration) *Client {
 
 func (c *Client) CheckHealth() (*models.OverallHealthStatus, error) {
 	params := products.NewGetHealthParamsWithContext(c.context).
-		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient)
+		WithTimeout(c.timeout)
 
 	res, err := c.harborClient.Client.Products.GetHealth(params, c.harborClient.Auth)
 	if err != nil {
@@ -104,7 +104,121 @@ func (c *Client) CheckHealth() (*models.OverallHealthStatus, error) {
 	return res.Payload, nil
 }
 
-func (c *Client) CreateRobotAccount(projectID int64) (*model.Robot, error) {
+// GetSystemInfo gets the general info of the harbor server
+func (c *Client) GetSystemInfo() (*model.ServerInfo, error) {
+	if c.harborClient == nil {
+		return nil, errors.New("nil harbor client")
+	}
+
+	params := products.NewGetSysteminfoParamsWithContext(c.context).
+		WithTimeout(c.timeout)
+
+	res, err := c.harborClient.Client.Products.GetSysteminfo(params, c.harborClient.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &model.ServerInfo{
+		Version:         res.Payload.HarborVersion,
+		AuthMode:        res.Payload.AuthMode,
+		RegistryURL:     res.Payload.RegistryURL,
+		WithNotary:      res.Payload.WithNotary,
+		WithChartmuseum: res.Payload.WithChartmuseum,
+
+		ProjectCreationRestriction: res.Payload.ProjectCreationRestriction,
+	}
+
+	// The read only flag is a system configuration that requires the system admin permission
+	readOnly, err := c.IsReadOnly()
+	if err != nil {
+		c.log.Info("skip detecting read only mode", "cause", err.Error())
+	} else {
+		info.ReadOnly = &readOnly
+	}
+
+	return info, nil
+}
+
+// GetCurrentUser gets the user authenticated with the access credential
+func (c *Client) GetCurrentUser() (*models.User, error) {
+	if c.harborClient == nil {
+		return nil, errors.New("nil harbor client")
+	}
+
+	params := products.NewGetUsersCurrentParamsWithContext(c.context).
+		WithTimeout(c.timeout)
+
+	res, err := c.harborClient.Client.Products.GetUsersCurrent(params, c.harborClient.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Payload, nil
+}
+
+// GetCurrentUserPermissions gets the permissions of the current user in the scope, the resources are relative to the scope
+func (c *Client) GetCurrentUserPermissions(scope string) ([]model.Permission, error) {
+	if c.harborClient == nil {
+		return nil, errors.New("nil harbor client")
+	}
+
+	relative := true
+	params := products.NewGetUsersCurrentPermissionsParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithScope(&scope).
+		WithRelative(&relative)
+
+	res, err := c.harborClient.Client.Products.GetUsersCurrentPermissions(params, c.harborClient.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]model.Permission, 0, len(res.Payload))
+	for _, p := range res.Payload {
+		if p != nil {
+			perms = append(perms, model.Permission{Resource: p.Resource, Action: p.Action})
+		}
+	}
+
+	return perms, nil
+}
+
+// IsReadOnly checks if the harbor server is in read only mode
+func (c *Client) IsReadOnly() (bool, error) {
+	if c.harborClient == nil {
+		return false, errors.New("nil harbor client")
+	}
+
+	params := products.NewGetConfigurationsParamsWithContext(c.context).
+		WithTimeout(c.timeout)
+
+	res, err := c.harborClient.Client.Products.GetConfigurations(params, c.harborClient.Auth)
+	if err != nil {
+		return false, err
+	}
+
+	if res.Payload == nil || res.Payload.ReadOnly == nil {
+		return false, nil
+	}
+
+	return res.Payload.ReadOnly.Value, nil
+}
+
+// CreateRobotAccount creates a robot account with the access on the project, it expires after the TTL or never expires if the TTL is zero
+func (c *Client) CreateRobotAccount(projectID int64, access []model.Permission, ttl time.Duration) (*model.Robot, error) {
+	if len(access) == 0 {
+		return nil, errors.New("empty robot access")
+	}
+
+	return c.createRobotAccount(projectID, access, ttl)
+}
+
+// CreatePullRobotAccount creates a robot account which can only pull the images of the project
+func (c *Client) CreatePullRobotAccount(projectID int64, ttl time.Duration) (*model.Robot, error) {
+	return c.createRobotAccount(projectID, model.RobotAccess(nil), ttl)
+}
+
+func (c *Client) createRobotAccount(projectID int64, access []model.Permission, ttl time.Duration) (*model.Robot, error) {
 	if projectID <= 0 {
 		return nil, errors.New("invalid project id")
 	}
@@ -113,19 +227,29 @@ func (c *Client) CreateRobotAccount(projectID int64) (*model.Robot, error) {
 		return nil, errors.New("nil harbor client")
 	}
 
+	// The resources are scoped to the project
+	entries := make([]*models.RobotAccountAccess, 0, len(access))
+	for _, a := range access {
+		entries = append(entries, &models.RobotAccountAccess{
+			Action:   a.Action,
+			Resource: fmt.Sprintf("/project/%d/%s", projectID, a.Resource),
+		})
+	}
+
+	// The expiry is the unix time after which the token is rejected
+	expiresAt, expiry := int64(-1), time.Time{} // never
+	if ttl > 0 {
+		expiry = time.Now().Add(ttl).Truncate(time.Second)
+		expiresAt = expiry.Unix()
+	}
+
 	params := products.NewPostProjectsProjectIDRobotsParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
 		WithProjectID(projectID).
 		WithRobot(&models.RobotAccountCreate{
-			Access: []*models.RobotAccountAccess{
-				{
-					Action:   "push",
-					Resource: fmt.Sprintf("/project/%d/repository", projectID),
-				},
-			},
+			Access:      entries,
 			Description: "automated by harbor automation operator",
-			ExpiresAt:   -1, // never
+			ExpiresAt:   expiresAt,
 			Name:        utils.RandomName("4k8s"),
 		})
 
@@ -141,9 +265,10 @@ func (c *Client) CreateRobotAccount(projectID int64) (*model.Robot, error) {
 	}
 
 	return &model.Robot{
-		ID:    rid,
-		Name:  res.Payload.Name,
-		Token: res.Payload.Token,
+		ID:        rid,
+		Name:      res.Payload.Name,
+		Token:     res.Payload.Token,
+		ExpiresAt: expiry,
 	}, nil
 }
 
@@ -162,11 +287,16 @@ func (c *Client) DeleteRobotAccount(projectID, robotID int64) error {
 
 	params := products.NewDeleteProjectsProjectIDRobotsRobotIDParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
 		WithProjectID(projectID).
 		WithRobotID(robotID)
 
 	if _, err := c.harborClient.Client.Products.DeleteProjectsProjectIDRobotsRobotID(params, c.harborClient.Auth); err != nil {
+		// Already deleted
+		var notFound *products.DeleteProjectsProjectIDRobotsRobotIDNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+
 		return err
 	}
 
@@ -188,17 +318,27 @@ func (c *Client) GetRobotAccount(projectID, robotID int64) (*model.Robot, error)
 
 	params := products.NewGetProjectsProjectIDRobotsRobotIDParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
 		WithProjectID(projectID).
 		WithRobotID(robotID)
 
 	res, err := c.harborClient.Client.Products.GetProjectsProjectIDRobotsRobotID(params, c.harborClient.Auth)
 	if err != nil {
+		var notFound *products.GetProjectsProjectIDRobotsRobotIDNotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("robot account %d: %w", robotID, model.ErrNotFound)
+		}
+
 		return nil, err
 	}
 
-	return &model.Robot{
-		ID:   robotID,
-		Name: res.Payload.Name,
-	}, nil
+	robot := &model.Robot{
+		ID:       robotID,
+		Name:     res.Payload.Name,
+		Disabled: res.Payload.Disabled,
+	}
+	if res.Payload.ExpiresAt > 0 {
+		robot.ExpiresAt = time.Unix(res.Payload.ExpiresAt, 0)
+	}
+
+	return robot, nil
 }
@@ -0,0 +1,108 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+)
+
+const (
+	// immutableAction is the action of the immutability rules
+	immutableAction = "immutable"
+	// immutableTemplate is the template of the immutability rules
+	immutableTemplate = "immutable_template"
+	// immutableScope is the scope of the repository selectors of the immutability rules
+	immutableScope = "repository"
+	// immutableSelectorKind is the kind of the selectors matching the doublestar patterns
+	immutableSelectorKind = "doublestar"
+	// immutableTagDecoration and immutableRepoDecoration select the tags and repositories matching the patterns
+	immutableTagDecoration  = "matches"
+	immutableRepoDecoration = "repoMatches"
+	// immutableMatchAll is the doublestar pattern matching all the repositories or tags
+	immutableMatchAll = "**"
+)
+
+// EnsureProjectImmutable ensures the immutability rule matching all the repositories and tags of the project exists,
+// so no tag can be pushed again or deleted. It returns true if the rule is added.
+func (c *Client) EnsureProjectImmutable(projectID int64) (bool, error) {
+	if projectID <= 0 {
+		return false, errors.New("invalid project id")
+	}
+
+	if c.harborClient == nil {
+		return false, errors.New("nil harbor client")
+	}
+
+	params := products.NewGetProjectsProjectIDImmutabletagrulesParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID)
+	res, err := c.harborClient.Client.Products.GetProjectsProjectIDImmutabletagrules(params, c.harborClient.Auth)
+	if err != nil {
+		return false, fmt.Errorf("list immutability rules of project %d error: %w", projectID, err)
+	}
+
+	for _, rule := range res.Payload {
+		if rule != nil && !rule.Disabled && immutableAll(rule) {
+			return false, nil
+		}
+	}
+
+	cparams := products.NewPostProjectsProjectIDImmutabletagrulesParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID).
+		WithRetentionRule(&models.RetentionRule{
+			Action:   immutableAction,
+			Template: immutableTemplate,
+			TagSelectors: []*models.RetentionSelector{{
+				Kind:       immutableSelectorKind,
+				Decoration: immutableTagDecoration,
+				Pattern:    immutableMatchAll,
+			}},
+			ScopeSelectors: map[string][]models.RetentionSelector{
+				immutableScope: {{
+					Kind:       immutableSelectorKind,
+					Decoration: immutableRepoDecoration,
+					Pattern:    immutableMatchAll,
+				}},
+			},
+		})
+	if _, err := c.harborClient.Client.Products.PostProjectsProjectIDImmutabletagrules(cparams, c.harborClient.Auth); err != nil {
+		return false, fmt.Errorf("create immutability rule of project %d error: %w", projectID, err)
+	}
+
+	return true, nil
+}
+
+// immutableAll checks if the immutability rule matches all the repositories and tags
+func immutableAll(rule *models.RetentionRule) bool {
+	tags, repositories := false, false
+	for _, s := range rule.TagSelectors {
+		if s != nil && s.Decoration == immutableTagDecoration && s.Pattern == immutableMatchAll {
+			tags = true
+		}
+	}
+
+	for _, s := range rule.ScopeSelectors[immutableScope] {
+		if s.Decoration == immutableRepoDecoration && s.Pattern == immutableMatchAll {
+			repositories = true
+		}
+	}
+
+	return tags && repositories
+}
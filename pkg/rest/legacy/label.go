@@ -0,0 +1,85 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+)
+
+// labelScopeProject is the scope of the project level labels
+const labelScopeProject = "p"
+
+// EnsureProjectLabel ensures the project level label exists in the project and returns its ID
+func (c *Client) EnsureProjectLabel(projectID int64, name, description string) (int64, error) {
+	if projectID <= 0 {
+		return 0, errors.New("invalid project id")
+	}
+
+	if c.harborClient == nil {
+		return 0, errors.New("nil harbor client")
+	}
+
+	labelID, err := c.getProjectLabel(projectID, name)
+	if err != nil || labelID > 0 {
+		return labelID, err
+	}
+
+	cparams := products.NewPostLabelsParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithLabel(&models.Label{
+			Name:        name,
+			Description: description,
+			Scope:       labelScopeProject,
+			ProjectID:   projectID,
+		})
+	res, err := c.harborClient.Client.Products.PostLabels(cparams, c.harborClient.Auth)
+	if err != nil {
+		// Created by others
+		var conflict *products.PostLabelsConflict
+		if errors.As(err, &conflict) {
+			return c.getProjectLabel(projectID, name)
+		}
+
+		return 0, fmt.Errorf("create project label error: %w", err)
+	}
+
+	return utils.ExtractID(res.Location)
+}
+
+// getProjectLabel returns the ID of the project level label, zero is returned if it does not exist
+func (c *Client) getProjectLabel(projectID int64, name string) (int64, error) {
+	params := products.NewGetLabelsParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithScope(labelScopeProject).
+		WithProjectID(&projectID).
+		WithName(&name)
+	res, err := c.harborClient.Client.Products.GetLabels(params, c.harborClient.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("get project labels error: %w", err)
+	}
+
+	for _, l := range res.Payload {
+		if l != nil && l.Name == name {
+			return l.ID, nil
+		}
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,115 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+)
+
+// memberPageSize is the page size of listing the project members
+const memberPageSize = 100
+
+// ListProjectMembers lists all the members of the project.
+// The sdk does not cover the pagination of the API, so the pages are requested directly.
+func (c *Client) ListProjectMembers(projectID int64) ([]*models.ProjectMemberEntity, error) {
+	if projectID <= 0 {
+		return nil, errors.New("invalid project id")
+	}
+
+	members := make([]*models.ProjectMemberEntity, 0)
+	for page := 1; ; page++ {
+		items := make([]*models.ProjectMemberEntity, 0)
+		if err := c.submit("ListProjectMembers", http.MethodGet, "/projects/{project_id}/members",
+			map[string]string{"project_id": strconv.FormatInt(projectID, 10)},
+			map[string]string{"page": strconv.Itoa(page), "page_size": strconv.Itoa(memberPageSize)},
+			nil, &items); err != nil {
+			return nil, fmt.Errorf("list project members error: %w", err)
+		}
+
+		members = append(members, items...)
+		if len(items) < memberPageSize {
+			return members, nil
+		}
+	}
+}
+
+// AddProjectMember adds the user or group to the project, the group is of the specified group type
+func (c *Client) AddProjectMember(projectID int64, member *model.ProjectMember, groupType int64) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	pm := &models.ProjectMember{RoleID: member.RoleID}
+	switch member.EntityType {
+	case model.MemberUser:
+		pm.MemberUser = &models.UserEntity{Username: member.Name}
+	case model.MemberGroup:
+		pm.MemberGroup = &models.UserGroup{GroupName: member.Name, GroupType: groupType}
+	default:
+		return fmt.Errorf("unsupported member entity type %q", member.EntityType)
+	}
+
+	params := products.NewPostProjectsProjectIDMembersParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID).
+		WithProjectMember(pm)
+	if _, err := c.harborClient.Client.Products.PostProjectsProjectIDMembers(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("add project member %s error: %w", member.Name, err)
+	}
+
+	return nil
+}
+
+// UpdateProjectMemberRole changes the role of the project member
+func (c *Client) UpdateProjectMemberRole(projectID, memberID, roleID int64) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	params := products.NewPutProjectsProjectIDMembersMidParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID).
+		WithMid(memberID).
+		WithRole(&models.RoleRequest{RoleID: roleID})
+	if _, err := c.harborClient.Client.Products.PutProjectsProjectIDMembersMid(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("update project member %d error: %w", memberID, err)
+	}
+
+	return nil
+}
+
+// DeleteProjectMember removes the member from the project
+func (c *Client) DeleteProjectMember(projectID, memberID int64) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	params := products.NewDeleteProjectsProjectIDMembersMidParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID).
+		WithMid(memberID)
+	if _, err := c.harborClient.Client.Products.DeleteProjectsProjectIDMembersMid(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("delete project member %d error: %w", memberID, err)
+	}
+
+	return nil
+}
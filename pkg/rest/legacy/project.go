@@ -0,0 +1,133 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-openapi/runtime"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
+)
+
+// The project APIs of Harbor v2.0 are not generated into the legacy sdk, they are called directly.
+// The payloads of the projects are compatible with the ones of the v2 API.
+
+// EnsureProject ensures the project exists and returns its ID, the project is created with the settings
+func (c *Client) EnsureProject(name string, settings *model.ProjectSettings) (int64, error) {
+	p, err := c.GetProject(name)
+	if err == nil {
+		return int64(p.ProjectID), nil
+	}
+
+	if !errors.Is(err, model.ErrNotFound) {
+		return 0, fmt.Errorf("error when getting project %s: %w", name, err)
+	}
+
+	if err := c.submit("CreateProject", http.MethodPost, "/projects", nil, nil, settings.ProjectReq(name), nil); err != nil {
+		return 0, fmt.Errorf("ensure project error: %w", err)
+	}
+
+	// The location of the created project is not returned by submit, read it back
+	p, err = c.GetProject(name)
+	if err != nil {
+		return 0, fmt.Errorf("get created project %s error: %w", name, err)
+	}
+
+	return int64(p.ProjectID), nil
+}
+
+// EnsureProxyCacheProject is not supported by the Harbor servers served with the legacy project APIs
+func (c *Client) EnsureProxyCacheProject(name string, registryID int64, public bool) (int64, error) {
+	return 0, fmt.Errorf("proxy cache project %s can not be created, it is %w, capability %s is required", name, model.ErrNotSupported, model.CapabilityProxyCache)
+}
+
+// UpdateProject updates the project with the request
+func (c *Client) UpdateProject(projectID int64, req *v2models.ProjectReq) error {
+	if err := c.submit("UpdateProject", http.MethodPut, "/projects/{project_id}", projectPathParams(projectID), nil, req, nil); err != nil {
+		return fmt.Errorf("update project error: %w", err)
+	}
+
+	return nil
+}
+
+// GetProject gets the project by name, model.ErrNotFound is returned if it does not exist
+func (c *Client) GetProject(name string) (*v2models.Project, error) {
+	if len(name) == 0 {
+		return nil, errors.New("project name is empty")
+	}
+
+	projects := make([]*v2models.Project, 0)
+	if err := c.submit("ListProjects", http.MethodGet, "/projects", nil, map[string]string{"name": name}, nil, &projects); err != nil {
+		return nil, fmt.Errorf("get project error: %w", err)
+	}
+
+	// The name is fuzzy matched by the legacy API
+	for _, p := range projects {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no project with name %s exists: %w", name, model.ErrNotFound)
+}
+
+// GetProjectByID gets the project by ID, model.ErrNotFound is returned if it does not exist
+func (c *Client) GetProjectByID(projectID int64) (*v2models.Project, error) {
+	if projectID <= 0 {
+		return nil, errors.New("invalid project id")
+	}
+
+	p := &v2models.Project{}
+	if err := c.submit("GetProject", http.MethodGet, "/projects/{project_id}", projectPathParams(projectID), nil, nil, p); err != nil {
+		var apiErr *runtime.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, fmt.Errorf("project %d: %w", projectID, model.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("get project error: %w", err)
+	}
+
+	return p, nil
+}
+
+// DeleteProject deletes the project by name
+func (c *Client) DeleteProject(name string) error {
+	p, err := c.GetProject(name)
+	if err != nil {
+		return fmt.Errorf("delete project error: %w", err)
+	}
+
+	return c.submit("DeleteProject", http.MethodDelete, "/projects/{project_id}", projectPathParams(int64(p.ProjectID)), nil, nil, nil)
+}
+
+// IsProjectDeletable checks if the project can be deleted, the reason is returned if it can not
+func (c *Client) IsProjectDeletable(projectID int64) (bool, string, error) {
+	res := &v2models.ProjectDeletable{}
+	if err := c.submit("GetProjectDeletable", http.MethodGet, "/projects/{project_id}/_deletable", projectPathParams(projectID), nil, nil, res); err != nil {
+		return false, "", fmt.Errorf("check project deletable error: %w", err)
+	}
+
+	return res.Deletable, res.Message, nil
+}
+
+func projectPathParams(projectID int64) map[string]string {
+	return map[string]string{
+		"project_id": strconv.FormatInt(projectID, 10),
+	}
+}
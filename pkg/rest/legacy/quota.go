@@ -0,0 +1,125 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+)
+
+const (
+	// quotaReferenceProject is the reference of the project quotas
+	quotaReferenceProject = "project"
+	// quotaResourceStorage is the storage resource of the quota
+	quotaResourceStorage = "storage"
+)
+
+// GetProjectStorageLimit returns the storage limit of the project in bytes, -1 means unlimited
+func (c *Client) GetProjectStorageLimit(projectID int64) (int64, error) {
+	q, err := c.getProjectQuota(projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	limit, ok := q.Hard[quotaResourceStorage]
+	if !ok {
+		return 0, fmt.Errorf("no storage limit in the quota of project %d", projectID)
+	}
+
+	return limit, nil
+}
+
+// UpdateProjectStorageLimit updates the storage limit of the project in bytes, -1 means unlimited
+func (c *Client) UpdateProjectStorageLimit(projectID int64, limit int64) error {
+	q, err := c.getProjectQuota(projectID)
+	if err != nil {
+		return err
+	}
+
+	params := products.NewPutQuotasIDParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithID(q.ID).
+		WithHard(&models.QuotaUpdateReq{
+			Hard: models.ResourceList{quotaResourceStorage: limit},
+		})
+	if _, err := c.harborClient.Client.Products.PutQuotasID(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("update quota of project %d error: %w", projectID, err)
+	}
+
+	return nil
+}
+
+// FreezeProjectStorage limits the storage of the project to the used storage, so nothing more can be pushed.
+// It returns the limit in bytes, the empty project is limited to one byte as the zero limit is not a valid quota.
+func (c *Client) FreezeProjectStorage(projectID int64) (int64, error) {
+	q, err := c.getProjectQuota(projectID)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := q.Used[quotaResourceStorage]
+	if limit < 1 {
+		limit = 1
+	}
+
+	if q.Hard[quotaResourceStorage] == limit {
+		return limit, nil
+	}
+
+	params := products.NewPutQuotasIDParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithID(q.ID).
+		WithHard(&models.QuotaUpdateReq{
+			Hard: models.ResourceList{quotaResourceStorage: limit},
+		})
+	if _, err := c.harborClient.Client.Products.PutQuotasID(params, c.harborClient.Auth); err != nil {
+		return 0, fmt.Errorf("update quota of project %d error: %w", projectID, err)
+	}
+
+	return limit, nil
+}
+
+func (c *Client) getProjectQuota(projectID int64) (*models.Quota, error) {
+	if projectID <= 0 {
+		return nil, errors.New("invalid project id")
+	}
+
+	if c.harborClient == nil {
+		return nil, errors.New("nil harbor client")
+	}
+
+	reference, referenceID := quotaReferenceProject, strconv.FormatInt(projectID, 10)
+	params := products.NewGetQuotasParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithReference(&reference).
+		WithReferenceID(&referenceID)
+	res, err := c.harborClient.Client.Products.GetQuotas(params, c.harborClient.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("get quota of project %d error: %w", projectID, err)
+	}
+
+	for _, q := range res.Payload {
+		if q != nil {
+			return q, nil
+		}
+	}
+
+	return nil, fmt.Errorf("quota of project %d: %w", projectID, model.ErrNotFound)
+}
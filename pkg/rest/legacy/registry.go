@@ -0,0 +1,169 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client/products"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+)
+
+const (
+	// registryCredentialType is the type of the credential of the registry endpoints
+	registryCredentialType = "basic"
+	// registryDescription is the description of the registry endpoints managed by the operator
+	registryDescription = "managed by harbor-automation-4k8s"
+)
+
+// PingRegistry checks the registry can be reached from the harbor server with the credential
+func (c *Client) PingRegistry(reg *model.Registry) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	params := products.NewPostRegistriesPingParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithRegistry(registryModel(reg))
+	if _, err := c.harborClient.Client.Products.PostRegistriesPing(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("ping registry %s error: %w", reg.URL, err)
+	}
+
+	return nil
+}
+
+// EnsureRegistry creates the registry endpoint or updates the existing one with the same name, it returns the ID of the endpoint.
+// The existing endpoint is only updated if it is managed by the operator, and its type can not be changed.
+func (c *Client) EnsureRegistry(reg *model.Registry) (int64, error) {
+	if c.harborClient == nil {
+		return 0, errors.New("nil harbor client")
+	}
+
+	params := products.NewGetRegistriesParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithName(&reg.Name)
+	res, err := c.harborClient.Client.Products.GetRegistries(params, c.harborClient.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("list registries error: %w", err)
+	}
+
+	for _, existing := range res.Payload {
+		// The name is fuzzy matched
+		if existing == nil || existing.Name != reg.Name {
+			continue
+		}
+
+		// The endpoints created by the others are never taken over
+		if !registryManaged(existing) {
+			return 0, fmt.Errorf("registry %s exists and is not managed by the operator", reg.Name)
+		}
+
+		if existing.Type != reg.Type {
+			return 0, fmt.Errorf("registry %s exists with type %s", reg.Name, existing.Type)
+		}
+
+		if registryUpToDate(existing, reg) {
+			return existing.ID, nil
+		}
+
+		if err := c.submit("UpdateRegistry", http.MethodPut, "/registries/{id}", map[string]string{"id": strconv.FormatInt(existing.ID, 10)},
+			nil, registryUpdateOf(reg), nil); err != nil {
+			return 0, fmt.Errorf("update registry %s error: %w", reg.Name, err)
+		}
+
+		return existing.ID, nil
+	}
+
+	cparams := products.NewPostRegistriesParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithRegistry(registryModel(reg))
+	created, err := c.harborClient.Client.Products.PostRegistries(cparams, c.harborClient.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("create registry %s error: %w", reg.Name, err)
+	}
+
+	return utils.ExtractID(created.Location)
+}
+
+// registryManaged checks if the registry endpoint is created by the operator,
+// the description of the endpoints created by the earlier versions is followed by the credential fingerprint
+func registryManaged(existing *models.Registry) bool {
+	return strings.HasPrefix(existing.Description, registryDescription)
+}
+
+// registryUpToDate checks if the existing registry endpoint is the same as the declared one.
+// The secret of the credential is masked by harbor, its change is told by the declared registry.
+func registryUpToDate(existing *models.Registry, reg *model.Registry) bool {
+	accessKey := ""
+	if existing.Credential != nil {
+		accessKey = existing.Credential.AccessKey
+	}
+
+	return !reg.CredentialChanged &&
+		existing.URL == reg.URL &&
+		existing.Insecure == reg.Insecure &&
+		accessKey == reg.AccessKey &&
+		existing.Description == registryDescription
+}
+
+// registryUpdate is the update of the registry endpoint. Unlike the sdk model, the empty fields are sent as well,
+// so the credential removed from the secret is cleared and the certificate verification is turned on again.
+type registryUpdate struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	Insecure       bool   `json:"insecure"`
+	Description    string `json:"description"`
+	CredentialType string `json:"credential_type"`
+	AccessKey      string `json:"access_key"`
+	AccessSecret   string `json:"access_secret"`
+}
+
+func registryUpdateOf(reg *model.Registry) *registryUpdate {
+	return &registryUpdate{
+		Name:           reg.Name,
+		URL:            reg.URL,
+		Insecure:       reg.Insecure,
+		Description:    registryDescription,
+		CredentialType: registryCredentialType,
+		AccessKey:      reg.AccessKey,
+		AccessSecret:   reg.AccessSecret,
+	}
+}
+
+func registryModel(reg *model.Registry) *models.Registry {
+	m := &models.Registry{
+		Name:        reg.Name,
+		Type:        reg.Type,
+		URL:         reg.URL,
+		Insecure:    reg.Insecure,
+		Description: registryDescription,
+	}
+
+	if len(reg.AccessKey) > 0 {
+		m.Credential = &models.RegistryCredential{
+			Type:         registryCredentialType,
+			AccessKey:    reg.AccessKey,
+			AccessSecret: reg.AccessSecret,
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,117 @@
+package legacy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+)
+
+func TestEnsureRegistry(t *testing.T) {
+	reg := &model.Registry{
+		Name:         "docker-hub",
+		Type:         "docker-hub",
+		URL:          "https://hub.docker.com",
+		AccessKey:    "user",
+		AccessSecret: "secret",
+	}
+	anonymous := &model.Registry{Name: "docker-hub", Type: "docker-hub", URL: "https://hub.docker.com"}
+	existing := func(description string) *models.Registry {
+		return &models.Registry{
+			ID:          3,
+			Name:        "docker-hub",
+			Type:        "docker-hub",
+			URL:         "https://hub.docker.com",
+			Description: description,
+			Credential:  &models.RegistryCredential{Type: "basic", AccessKey: "user", AccessSecret: "*****"},
+		}
+	}
+
+	type testcase struct {
+		description string
+		reg         *model.Registry
+		existing    []*models.Registry
+		updated     bool
+		accessKey   string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "unchanged registry is not updated",
+			reg:         reg,
+			existing:    []*models.Registry{existing(registryDescription)},
+		},
+		{
+			description: "changed secret is updated",
+			reg:         &model.Registry{Name: "docker-hub", Type: "docker-hub", URL: "https://hub.docker.com", AccessKey: "user", AccessSecret: "new", CredentialChanged: true},
+			existing:    []*models.Registry{existing(registryDescription)},
+			updated:     true,
+			accessKey:   "user",
+		},
+		{
+			description: "registry created by the earlier versions is updated without the credential fingerprint",
+			reg:         reg,
+			existing:    []*models.Registry{existing(registryDescription + ", credential 0123456789abcdef")},
+			updated:     true,
+			accessKey:   "user",
+		},
+		{
+			description: "registry created by others is not taken over",
+			reg:         reg,
+			existing:    []*models.Registry{existing("")},
+			expectedErr: true,
+		},
+		{
+			description: "removed credential is cleared",
+			reg:         anonymous,
+			existing:    []*models.Registry{existing(registryDescription)},
+			updated:     true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var update map[string]interface{}
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/registries"):
+					require.NoError(t, json.NewEncoder(w).Encode(tc.existing))
+				case req.Method == http.MethodPut && strings.HasSuffix(req.URL.Path, "/registries/3"):
+					update = map[string]interface{}{}
+					require.NoError(t, json.NewDecoder(req.Body).Decode(&update))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			c, err := NewWithServer(model.NewHarborServer(strings.TrimPrefix(server.URL, "https://"), &model.AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"}, true))
+			require.NoError(t, err)
+
+			id, err := c.EnsureRegistry(tc.reg)
+			if tc.expectedErr {
+				require.Error(t, err)
+				require.Nil(t, update)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, int64(3), id)
+			require.Equal(t, tc.updated, update != nil)
+			if update != nil {
+				// The empty credential and insecure flag are sent to clear the existing ones
+				require.Equal(t, "basic", update["credential_type"])
+				require.Equal(t, tc.accessKey, update["access_key"])
+				require.Contains(t, update, "access_secret")
+				require.Equal(t, false, update["insecure"])
+				require.Equal(t, registryDescription, update["description"])
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package legacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/runtime"
+	"github.com/go-openapi/strfmt"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/models"
+)
+
+// The system level robot accounts are introduced in Harbor v2.2, the APIs are not covered by the legacy sdk.
+
+const (
+	robotLevelSystem = "system"
+	// robotKindSystem is the kind of the permissions covering the system resources
+	robotKindSystem = "system"
+	// robotSystemNamespace is the namespace of the system permissions
+	robotSystemNamespace = "/"
+	// robotKindProject is the kind of the permissions covering the projects
+	robotKindProject = "project"
+	// robotAllProjects is the namespace of the permissions covering all the projects
+	robotAllProjects = "*"
+)
+
+type systemRobotCreate struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Level       string                   `json:"level"`
+	Duration    int64                    `json:"duration"`
+	Permissions []*systemRobotPermission `json:"permissions"`
+}
+
+type systemRobotPermission struct {
+	Kind      string                       `json:"kind"`
+	Namespace string                       `json:"namespace"`
+	Access    []*models.RobotAccountAccess `json:"access"`
+}
+
+type systemRobotCreated struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Secret string `json:"secret"`
+}
+
+type robotPermissions struct {
+	Permissions []*systemRobotPermission `json:"permissions"`
+}
+
+type robotWithPermissions struct {
+	robotPermissions
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Disable bool   `json:"disable"`
+	// ExpiresAt is the unix time of the expiry, -1 if the robot account never expires
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+type systemRobotSecret struct {
+	Secret string `json:"secret"`
+}
+
+// CreateSystemRobotAccount creates a system level robot account never expiring,
+// the system permissions are granted on the system resources and the project permissions are granted on all the projects
+func (c *Client) CreateSystemRobotAccount(name, description string, system, project []model.Permission) (*model.Robot, error) {
+	if len(name) == 0 {
+		return nil, errors.New("empty robot name")
+	}
+
+	permissions := systemRobotPermissions(system, project)
+
+	created := &systemRobotCreated{}
+	if err := c.submit("CreateRobot", http.MethodPost, "/robots", nil, nil, &systemRobotCreate{
+		Name:        name,
+		Description: description,
+		Level:       robotLevelSystem,
+		Duration:    -1, // never
+		Permissions: permissions,
+	}, created); err != nil {
+		return nil, err
+	}
+
+	return &model.Robot{
+		ID:    created.ID,
+		Name:  created.Name,
+		Token: created.Secret,
+	}, nil
+}
+
+// EnsureSystemRobotAccountPermissions grants the system level robot account exactly the system and project permissions,
+// the robot account is only updated if its permissions are different. It returns true if the robot account is updated.
+func (c *Client) EnsureSystemRobotAccountPermissions(robotID int64, system, project []model.Permission) (bool, error) {
+	if robotID <= 0 {
+		return false, errors.New("invalid robot id")
+	}
+
+	// The other fields of the robot account are sent back as they are
+	robot := make(map[string]json.RawMessage)
+	err := c.submit("GetRobotByID", http.MethodGet, "/robots/{robot_id}", robotPathParams(robotID), nil, nil, &robot)
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return false, fmt.Errorf("robot account %d: %w", robotID, model.ErrNotFound)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	current := make([]*systemRobotPermission, 0)
+	if raw, ok := robot["permissions"]; ok {
+		if err := json.Unmarshal(raw, &current); err != nil {
+			return false, fmt.Errorf("decode permissions of robot account %d error: %w", robotID, err)
+		}
+	}
+
+	desired := systemRobotPermissions(system, project)
+	if reflect.DeepEqual(permissionKeys(current), permissionKeys(desired)) {
+		return false, nil
+	}
+
+	if robot["permissions"], err = json.Marshal(desired); err != nil {
+		return false, err
+	}
+
+	if err := c.submit("UpdateRobot", http.MethodPut, "/robots/{robot_id}", robotPathParams(robotID), nil, robot, nil); err != nil {
+		return false, fmt.Errorf("update permissions of robot account %d error: %w", robotID, err)
+	}
+
+	return true, nil
+}
+
+// RefreshSystemRobotAccountSecret generates a new secret for the system level robot account.
+// A robot account can refresh its own secret.
+func (c *Client) RefreshSystemRobotAccountSecret(robotID int64) (string, error) {
+	if robotID <= 0 {
+		return "", errors.New("invalid robot id")
+	}
+
+	sec := &systemRobotSecret{}
+	// The secret is generated by the server if it is empty
+	if err := c.submit("RefreshSec", http.MethodPatch, "/robots/{robot_id}", robotPathParams(robotID), nil, &systemRobotSecret{}, sec); err != nil {
+		return "", err
+	}
+
+	if len(sec.Secret) == 0 {
+		return "", errors.New("empty secret returned")
+	}
+
+	return sec.Secret, nil
+}
+
+// DeleteSystemRobotAccount deletes the system level robot account, it is not an error if the robot does not exist
+func (c *Client) DeleteSystemRobotAccount(robotID int64) error {
+	if robotID <= 0 {
+		return errors.New("invalid robot id")
+	}
+
+	err := c.submit("DeleteRobot", http.MethodDelete, "/robots/{robot_id}", robotPathParams(robotID), nil, nil, nil)
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		// Already deleted
+		return nil
+	}
+
+	return err
+}
+
+// GetRobotAccountWithAccess gets the robot account with its access, the project level robot accounts are covered as well.
+// The resources are returned without the project scope, e.g: repository. model.ErrNotFound is returned if it does not exist.
+func (c *Client) GetRobotAccountWithAccess(robotID int64) (*model.Robot, error) {
+	if robotID <= 0 {
+		return nil, errors.New("invalid robot id")
+	}
+
+	res := &robotWithPermissions{}
+	err := c.submit("GetRobotByID", http.MethodGet, "/robots/{robot_id}", robotPathParams(robotID), nil, nil, res)
+	var apiErr *runtime.APIError
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return nil, fmt.Errorf("robot account %d: %w", robotID, model.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	robot := &model.Robot{
+		ID:       robotID,
+		Name:     res.Name,
+		Disabled: res.Disable,
+		Access:   make([]model.Permission, 0),
+	}
+	if res.ExpiresAt > 0 {
+		robot.ExpiresAt = time.Unix(res.ExpiresAt, 0)
+	}
+
+	for _, p := range res.Permissions {
+		if p == nil {
+			continue
+		}
+
+		for _, a := range p.Access {
+			if a == nil {
+				continue
+			}
+
+			// The robot accounts created with the legacy API may keep the resources in the form of /project/{id}/repository
+			resource := a.Resource
+			if i := strings.LastIndex(resource, "/"); i >= 0 {
+				resource = resource[i+1:]
+			}
+			robot.Access = append(robot.Access, model.Permission{Resource: resource, Action: a.Action})
+		}
+	}
+
+	return robot, nil
+}
+
+func systemRobotPermissions(system, project []model.Permission) []*systemRobotPermission {
+	permissions := make([]*systemRobotPermission, 0, 2)
+	if len(system) > 0 {
+		permissions = append(permissions, &systemRobotPermission{
+			Kind:      robotKindSystem,
+			Namespace: robotSystemNamespace,
+			Access:    robotAccess(system),
+		})
+	}
+	if len(project) > 0 {
+		permissions = append(permissions, &systemRobotPermission{
+			Kind:      robotKindProject,
+			Namespace: robotAllProjects,
+			Access:    robotAccess(project),
+		})
+	}
+
+	return permissions
+}
+
+// permissionKeys returns the access of the permissions in the form of kind:namespace:resource:action, the order is ignored
+func permissionKeys(permissions []*systemRobotPermission) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, p := range permissions {
+		if p == nil {
+			continue
+		}
+
+		for _, a := range p.Access {
+			if a != nil {
+				keys[strings.Join([]string{p.Kind, p.Namespace, a.Resource, a.Action}, ":")] = struct{}{}
+			}
+		}
+	}
+
+	return keys
+}
+
+func robotAccess(permissions []model.Permission) []*models.RobotAccountAccess {
+	access := make([]*models.RobotAccountAccess, 0, len(permissions))
+	for _, p := range permissions {
+		access = append(access, &models.RobotAccountAccess{Resource: p.Resource, Action: p.Action})
+	}
+
+	return access
+}
+
+func robotPathParams(robotID int64) map[string]string {
+	return map[string]string{"robot_id": strconv.FormatInt(robotID, 10)}
+}
+
+// submit sends the request with the transport and the credential of the legacy sdk,
+// the response body is decoded into the result if it is not nil
+func (c *Client) submit(id, method, pathPattern string, pathParams, queryParams map[string]string, body interface{}, result interface{}) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	_, err := c.harborClient.Client.Transport.Submit(&runtime.ClientOperation{
+		ID:                 id,
+		Method:             method,
+		PathPattern:        pathPattern,
+		ProducesMediaTypes: []string{"application/json"},
+		ConsumesMediaTypes: []string{"application/json"},
+		Schemes:            []string{"http", "https"},
+		Params: runtime.ClientRequestWriterFunc(func(req runtime.ClientRequest, _ strfmt.Registry) error {
+			if err := req.SetTimeout(c.timeout); err != nil {
+				return err
+			}
+
+			for k, v := range pathParams {
+				if err := req.SetPathParam(k, v); err != nil {
+					return err
+				}
+			}
+
+			for k, v := range queryParams {
+				if err := req.SetQueryParam(k, v); err != nil {
+					return err
+				}
+			}
+
+			if body != nil {
+				return req.SetBodyParam(body)
+			}
+
+			return nil
+		}),
+		Reader: runtime.ClientResponseReaderFunc(func(res runtime.ClientResponse, consumer runtime.Consumer) (interface{}, error) {
+			if res.Code() < http.StatusOK || res.Code() >= http.StatusMultipleChoices {
+				return nil, runtime.NewAPIError(id, res.Message(), res.Code())
+			}
+
+			if result != nil {
+				if err := consumer.Consume(res.Body(), result); err != nil && err != io.EOF {
+					return nil, err
+				}
+			}
+
+			return result, nil
+		}),
+		AuthInfo: c.harborClient.Auth,
+		Context:  c.context,
+	})
+
+	return err
+}
@@ -0,0 +1,78 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
+)
+
+const (
+	// CapabilityProjectAPIV2 indicates the project APIs are provided by the v2 API (Harbor 2.1+)
+	CapabilityProjectAPIV2 = "ProjectAPIV2"
+	// CapabilityProjectAPI indicates the project APIs are provided by the legacy API (Harbor 2.x)
+	CapabilityProjectAPI = "ProjectAPI"
+	// CapabilityProjectRobot indicates the project level robot account APIs of the legacy API (Harbor 2.x)
+	CapabilityProjectRobot = "ProjectRobot"
+	// CapabilityProjectMember indicates the project member APIs of the legacy API (Harbor 2.x)
+	CapabilityProjectMember = "ProjectMember"
+	// CapabilitySystemRobot indicates the system level robot accounts are supported (Harbor 2.2+)
+	CapabilitySystemRobot = "SystemRobot"
+	// CapabilityProxyCache indicates the proxy cache projects are supported (Harbor 2.1+)
+	CapabilityProxyCache = "ProxyCache"
+	// CapabilityNotary indicates the harbor server is deployed with notary
+	CapabilityNotary = "Notary"
+	// CapabilityChartmuseum indicates the harbor server is deployed with chartmuseum
+	CapabilityChartmuseum = "Chartmuseum"
+)
+
+// ServerInfo contains the detected info of the harbor server
+type ServerInfo struct {
+	Version         string
+	AuthMode        string
+	RegistryURL     string
+	WithNotary      bool
+	WithChartmuseum bool
+	// ProjectCreationRestriction is adminonly or everyone
+	ProjectCreationRestriction string
+	// ReadOnly is nil if it can not be detected with the current credential
+	ReadOnly *bool
+}
+
+// Capabilities detects the capabilities of the harbor server from the server info
+func (s *ServerInfo) Capabilities() ([]string, error) {
+	v, err := utils.ParseVersion(s.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	caps := make([]string, 0)
+	if v.AtLeast(2, 0) {
+		caps = append(caps, CapabilityProjectAPI, CapabilityProjectRobot, CapabilityProjectMember)
+	}
+	if v.AtLeast(2, 1) {
+		caps = append(caps, CapabilityProjectAPIV2, CapabilityProxyCache)
+	}
+	if v.AtLeast(2, 2) {
+		caps = append(caps, CapabilitySystemRobot)
+	}
+	if s.WithNotary {
+		caps = append(caps, CapabilityNotary)
+	}
+	if s.WithChartmuseum {
+		caps = append(caps, CapabilityChartmuseum)
+	}
+
+	return caps, nil
+}
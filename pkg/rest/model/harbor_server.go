@@ -16,9 +16,15 @@ package model
 
 import (
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	gruntime "github.com/go-openapi/runtime"
 	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
 	hc "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor/client"
 	hc2 "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/client"
 	corev1 "k8s.io/api/core/v1"
@@ -27,40 +33,114 @@ import (
 const (
 	accessKey    = "accessKey"
 	accessSecret = "accessSecret"
+	robotName    = "robotName"
+	robotSecret  = "robotSecret"
+	username     = "username"
+	cliSecret    = "cliSecret"
+	token        = "token"
 )
 
 // AccessCred contains credential data for accessing the harbor server
 type AccessCred struct {
+	// Type of the credential, default to basic if it is empty
+	Type         string
 	AccessKey    string
 	AccessSecret string
+	// Token for the bearer credential
+	Token string
+}
+
+// RobotSecretData returns the secret data keeping the robot account as a robot access credential
+func RobotSecretData(name, secret string) map[string][]byte {
+	return map[string][]byte{
+		robotName:   []byte(name),
+		robotSecret: []byte(secret),
+	}
 }
 
-// FillIn put secret into AccessCred
+// FillIn put secret into AccessCred based on the credential type
 func (ac *AccessCred) FillIn(secret *corev1.Secret) error {
-	decodedAK, ok1 := secret.Data[accessKey]
-	decodedAS, ok2 := secret.Data[accessSecret]
+	switch ac.credType() {
+	case goharborv1alpha1.AccessCredentialTypeBasic:
+		return ac.fillInPair(secret, accessKey, accessSecret)
+	case goharborv1alpha1.AccessCredentialTypeRobot:
+		return ac.fillInPair(secret, robotName, robotSecret)
+	case goharborv1alpha1.AccessCredentialTypeOIDC:
+		return ac.fillInPair(secret, username, cliSecret)
+	case goharborv1alpha1.AccessCredentialTypeBearer:
+		decoded, ok := secret.Data[token]
+		if !ok || len(decoded) == 0 {
+			return fmt.Errorf("invalid access secret: credential type %s requires non-empty key %s", ac.credType(), token)
+		}
+
+		ac.Token = string(decoded)
+		return nil
+	default:
+		return fmt.Errorf("unsupported credential type %q", ac.Type)
+	}
+}
+
+func (ac *AccessCred) fillInPair(secret *corev1.Secret, key, value string) error {
+	decodedAK, ok1 := secret.Data[key]
+	decodedAS, ok2 := secret.Data[value]
 	if !(ok1 && ok2) {
-		return errors.New("invalid access secret")
+		return fmt.Errorf("invalid access secret: credential type %s requires keys %s and %s", ac.credType(), key, value)
 	}
 
 	ac.AccessKey = string(decodedAK)
 	ac.AccessSecret = string(decodedAS)
-	return nil
+	return ac.Validate(secret)
 }
 
 // Validate validates wether the key and secret has correct format
 func (ac *AccessCred) Validate(secret *corev1.Secret) error {
+	if ac.credType() == goharborv1alpha1.AccessCredentialTypeBearer {
+		if len(ac.Token) == 0 {
+			return errors.New("token can't be empty")
+		}
+		return nil
+	}
+
 	if len(ac.AccessKey) == 0 || len(ac.AccessSecret) == 0 {
 		return errors.New("access key and secret can't be empty")
 	}
 	return nil
 }
 
+// AuthInfoWriter returns the auth writer of the credential for the harbor API client
+func (ac *AccessCred) AuthInfoWriter() gruntime.ClientAuthInfoWriter {
+	if ac.credType() == goharborv1alpha1.AccessCredentialTypeBearer {
+		return httptransport.BearerToken(ac.Token)
+	}
+
+	// Harbor users, robots and OIDC users (with CLI secret) all use the basic auth
+	return httptransport.BasicAuth(ac.AccessKey, ac.AccessSecret)
+}
+
+func (ac *AccessCred) credType() string {
+	if len(ac.Type) == 0 {
+		return goharborv1alpha1.AccessCredentialTypeBasic
+	}
+
+	return ac.Type
+}
+
 // HarborServer contains connection data
 type HarborServer struct {
 	ServerURL  string
 	AccessCred *AccessCred
 	InSecure   bool
+	// PEM encoded CA bundle for verifying the server certificate
+	CABundle []byte
+	// PEM encoded client certificate and key for mutual TLS authentication
+	ClientCertificate []byte
+	ClientKey         []byte
+	// Proxy used to reach the server, nil means using the proxy settings of the environment
+	Proxy *ghttp.Proxy
+	// Throttle of the requests sent to the server, nil means no throttling
+	Throttle *ghttp.Throttle
+	// Owner is the key of the configuration of the server, the HTTP client is cached for it
+	Owner string
 }
 
 // NewHarborServer returns harbor server with inputs
@@ -85,44 +165,71 @@ type HarborClientV2 struct {
 }
 
 // Client created based on the server data
-func (h *HarborServer) Client() *HarborClient {
-	// New client
-	cfg := &hc.TransportConfig{
-		Host:     h.ServerURL,
-		BasePath: hc.DefaultBasePath,
-		Schemes:  hc.DefaultSchemes,
+func (h *HarborServer) Client() (*HarborClient, error) {
+	httpClient, err := h.httpClient()
+	if err != nil {
+		return nil, err
 	}
 
-	c := hc.NewHTTPClientWithConfig(nil, cfg)
-	auth := httptransport.BasicAuth(h.AccessCred.AccessKey, h.AccessCred.AccessSecret)
+	// New client
+	transport := httptransport.NewWithClient(h.ServerURL, hc.DefaultBasePath, hc.DefaultSchemes, httpClient)
+	c := hc.New(transport, strfmt.Default)
+	auth := h.AccessCred.AuthInfoWriter()
 
 	return &HarborClient{
 		Client: c,
 		Auth:   auth,
-	}
+	}, nil
 }
 
 // ClientV2 created based on the server data. Harbor V2 API
-func (h *HarborServer) ClientV2() *HarborClientV2 {
-	// New client
-	cfg := &hc2.TransportConfig{
-		Host:     h.ServerURL,
-		BasePath: hc2.DefaultBasePath,
-		Schemes:  hc2.DefaultSchemes,
+func (h *HarborServer) ClientV2() (*HarborClientV2, error) {
+	httpClient, err := h.httpClient()
+	if err != nil {
+		return nil, err
 	}
 
-	c := hc2.NewHTTPClientWithConfig(nil, cfg)
-	auth := httptransport.BasicAuth(h.AccessCred.AccessKey, h.AccessCred.AccessSecret)
+	// New client
+	transport := httptransport.NewWithClient(h.ServerURL, hc2.DefaultBasePath, hc2.DefaultSchemes, httpClient)
+	c := hc2.New(transport, strfmt.Default)
+	auth := h.AccessCred.AuthInfoWriter()
 
 	return &HarborClientV2{
 		Client: c,
 		Auth:   auth,
+	}, nil
+}
+
+// httpClient builds the HTTP client dedicated to this server.
+// The server certificate is only skipped verifying when the server is marked as insecure.
+func (h *HarborServer) httpClient() (*http.Client, error) {
+	c, err := ghttp.NewClient(&ghttp.Options{
+		InSecure:          h.InSecure,
+		CABundle:          h.CABundle,
+		ClientCertificate: h.ClientCertificate,
+		ClientKey:         h.ClientKey,
+		Proxy:             h.Proxy,
+		Owner:             h.Owner,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create http client for server %s error: %w", h.ServerURL, err)
 	}
+
+	if h.Throttle != nil {
+		c = h.Throttle.Wrap(c)
+	}
+
+	return c, nil
 }
 
 // Robot contains info of robot account
 type Robot struct {
-	ID    int64
-	Name  string
-	Token string
+	ID       int64
+	Name     string
+	Token    string
+	Disabled bool
+	// ExpiresAt is the expiry of the robot account, it is zero if the robot account never expires
+	ExpiresAt time.Time
+	// Access of the robot account without the project scope, it is nil if the access is not read, e.g: from the harbor servers older than v2.2
+	Access []Permission
 }
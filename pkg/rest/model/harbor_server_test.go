@@ -0,0 +1,88 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+)
+
+func TestAccessCred_FillIn(t *testing.T) {
+	type testcase struct {
+		description string
+		credType    string
+		data        map[string][]byte
+		expected    *AccessCred
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "empty type is treated as basic",
+			data:        map[string][]byte{"accessKey": []byte("admin"), "accessSecret": []byte("Harbor12345")},
+			expected:    &AccessCred{AccessKey: "admin", AccessSecret: "Harbor12345"},
+		},
+		{
+			description: "basic credential without secret key",
+			credType:    goharborv1alpha1.AccessCredentialTypeBasic,
+			data:        map[string][]byte{"accessKey": []byte("admin")},
+			expectedErr: true,
+		},
+		{
+			description: "robot credential",
+			credType:    goharborv1alpha1.AccessCredentialTypeRobot,
+			data:        map[string][]byte{"robotName": []byte("robot$automation"), "robotSecret": []byte("secret")},
+			expected:    &AccessCred{Type: goharborv1alpha1.AccessCredentialTypeRobot, AccessKey: "robot$automation", AccessSecret: "secret"},
+		},
+		{
+			description: "managed robot credential",
+			credType:    goharborv1alpha1.AccessCredentialTypeRobot,
+			data:        RobotSecretData("robot$4k8s-operator-abc", "secret"),
+			expected:    &AccessCred{Type: goharborv1alpha1.AccessCredentialTypeRobot, AccessKey: "robot$4k8s-operator-abc", AccessSecret: "secret"},
+		},
+		{
+			description: "robot credential with basic keys",
+			credType:    goharborv1alpha1.AccessCredentialTypeRobot,
+			data:        map[string][]byte{"accessKey": []byte("admin"), "accessSecret": []byte("Harbor12345")},
+			expectedErr: true,
+		},
+		{
+			description: "oidc credential with empty cli secret",
+			credType:    goharborv1alpha1.AccessCredentialTypeOIDC,
+			data:        map[string][]byte{"username": []byte("user"), "cliSecret": []byte("")},
+			expectedErr: true,
+		},
+		{
+			description: "oidc credential",
+			credType:    goharborv1alpha1.AccessCredentialTypeOIDC,
+			data:        map[string][]byte{"username": []byte("user"), "cliSecret": []byte("cli")},
+			expected:    &AccessCred{Type: goharborv1alpha1.AccessCredentialTypeOIDC, AccessKey: "user", AccessSecret: "cli"},
+		},
+		{
+			description: "bearer credential",
+			credType:    goharborv1alpha1.AccessCredentialTypeBearer,
+			data:        map[string][]byte{"token": []byte("abc")},
+			expected:    &AccessCred{Type: goharborv1alpha1.AccessCredentialTypeBearer, Token: "abc"},
+		},
+		{
+			description: "unknown credential type",
+			credType:    "unknown",
+			data:        map[string][]byte{"token": []byte("abc")},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			cred := &AccessCred{Type: tc.credType}
+			err := cred.FillIn(&corev1.Secret{Data: tc.data})
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, cred)
+		})
+	}
+}
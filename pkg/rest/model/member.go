@@ -0,0 +1,87 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+const (
+	// RoleProjectAdmin is the project admin role
+	RoleProjectAdmin = "projectAdmin"
+	// RoleMaintainer is the maintainer role
+	RoleMaintainer = "maintainer"
+	// RoleDeveloper is the developer role
+	RoleDeveloper = "developer"
+	// RoleGuest is the guest role
+	RoleGuest = "guest"
+
+	// MemberUser is the entity type of the user members
+	MemberUser = "u"
+	// MemberGroup is the entity type of the group members
+	MemberGroup = "g"
+)
+
+// projectRoles are the IDs of the project roles, in the order of the privileges
+var projectRoles = []struct {
+	name string
+	id   int64
+}{
+	{RoleProjectAdmin, 1},
+	{RoleMaintainer, 4},
+	{RoleDeveloper, 2},
+	{RoleGuest, 3},
+}
+
+// groupTypes are the types of the user groups of the auth modes
+var groupTypes = map[string]int64{
+	"http_auth": 2,
+	"oidc_auth": 3,
+}
+
+// ProjectMember is a user or group member of the project
+type ProjectMember struct {
+	// EntityType is MemberUser or MemberGroup
+	EntityType string
+	// Name of the user or group
+	Name string
+	// RoleID is the ID of the project role
+	RoleID int64
+}
+
+// ProjectRoleID returns the ID of the project role
+func ProjectRoleID(name string) (int64, bool) {
+	for _, r := range projectRoles {
+		if r.name == name {
+			return r.id, true
+		}
+	}
+
+	return 0, false
+}
+
+// HigherRole returns the role with more privileges
+func HigherRole(a, b int64) int64 {
+	for _, r := range projectRoles {
+		if r.id == a || r.id == b {
+			return r.id
+		}
+	}
+
+	return a
+}
+
+// GroupType returns the type of the user groups managed by the auth mode.
+// The groups identified by the name are supported, which excludes the LDAP groups identified by the DN.
+func GroupType(authMode string) (int64, bool) {
+	t, ok := groupTypes[authMode]
+	return t, ok
+}
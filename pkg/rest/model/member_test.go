@@ -0,0 +1,22 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHigherRole(t *testing.T) {
+	admin, _ := ProjectRoleID(RoleProjectAdmin)
+	maintainer, _ := ProjectRoleID(RoleMaintainer)
+	developer, _ := ProjectRoleID(RoleDeveloper)
+	guest, _ := ProjectRoleID(RoleGuest)
+
+	require.Equal(t, admin, HigherRole(guest, admin))
+	require.Equal(t, maintainer, HigherRole(maintainer, developer))
+	require.Equal(t, developer, HigherRole(guest, developer))
+	require.Equal(t, guest, HigherRole(guest, guest))
+
+	_, ok := ProjectRoleID("owner")
+	require.False(t, ok)
+}
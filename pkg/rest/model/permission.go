@@ -0,0 +1,84 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+)
+
+// ScopeSystem is the scope of the system level permissions
+const ScopeSystem = "/system"
+
+// ProjectCreationEveryone indicates all the users can create projects
+const ProjectCreationEveryone = "everyone"
+
+// PermissionCreateProject is required to create the projects for the namespaces
+var PermissionCreateProject = Permission{Resource: "project", Action: "create"}
+
+// PermissionCreateRegistry is required to create the registry endpoints of the proxy caches
+var PermissionCreateRegistry = Permission{Resource: "registry", Action: "create"}
+
+// PermissionUpdateRegistry is required to keep the registry endpoints of the proxy caches in sync
+var PermissionUpdateRegistry = Permission{Resource: "registry", Action: "update"}
+
+// PermissionListRegistry is required to find the existing registry endpoints of the proxy caches
+var PermissionListRegistry = Permission{Resource: "registry", Action: "list"}
+
+// robotPermissionAccess is the access on the project granted by each permission of the robot accounts of the pull secrets
+var robotPermissionAccess = map[goharborv1alpha1.RobotPermission]Permission{
+	goharborv1alpha1.RobotPermissionPull:          {Resource: "repository", Action: "pull"},
+	goharborv1alpha1.RobotPermissionPush:          {Resource: "repository", Action: "push"},
+	goharborv1alpha1.RobotPermissionHelmChartPull: {Resource: "helm-chart", Action: "read"},
+	goharborv1alpha1.RobotPermissionScan:          {Resource: "scan", Action: "create"},
+}
+
+// RobotAccess returns the access on the project granted by the permissions, the pull access is always granted
+func RobotAccess(permissions []goharborv1alpha1.RobotPermission) []Permission {
+	access := []Permission{robotPermissionAccess[goharborv1alpha1.RobotPermissionPull]}
+	for _, p := range permissions {
+		if a, ok := robotPermissionAccess[p]; ok && len(MissingPermissions([]Permission{a}, access)) > 0 {
+			access = append(access, a)
+		}
+	}
+
+	return access
+}
+
+// Permission is an action allowed on a resource
+type Permission struct {
+	Resource string
+	Action   string
+}
+
+func (p Permission) String() string {
+	return p.Resource + ":" + p.Action
+}
+
+// MissingPermissions returns the required permissions which are not granted
+func MissingPermissions(required []Permission, granted []Permission) []Permission {
+	grantedSet := make(map[Permission]struct{}, len(granted))
+	for _, p := range granted {
+		grantedSet[p] = struct{}{}
+	}
+
+	missing := make([]Permission, 0)
+	for _, p := range required {
+		if _, ok := grantedSet[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+)
+
+func TestMissingPermissions(t *testing.T) {
+	pullRepo := Permission{Resource: "repository", Action: "pull"}
+
+	cases := []struct {
+		name     string
+		required []Permission
+		granted  []Permission
+		expected []Permission
+	}{
+		{
+			name:     "all granted",
+			required: []Permission{PermissionCreateProject},
+			granted:  []Permission{pullRepo, PermissionCreateProject},
+			expected: []Permission{},
+		},
+		{
+			name:     "missing permissions",
+			required: []Permission{PermissionCreateProject, pullRepo},
+			granted:  []Permission{pullRepo},
+			expected: []Permission{PermissionCreateProject},
+		},
+		{
+			name:     "nothing granted",
+			required: []Permission{PermissionCreateProject},
+			expected: []Permission{PermissionCreateProject},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, MissingPermissions(c.required, c.granted))
+		})
+	}
+}
+
+func TestRobotAccess(t *testing.T) {
+	pullRepo := Permission{Resource: "repository", Action: "pull"}
+	pushRepo := Permission{Resource: "repository", Action: "push"}
+
+	cases := []struct {
+		name        string
+		permissions []goharborv1alpha1.RobotPermission
+		expected    []Permission
+	}{
+		{
+			name:     "pull only by default",
+			expected: []Permission{pullRepo},
+		},
+		{
+			name:        "opt-in permissions",
+			permissions: []goharborv1alpha1.RobotPermission{goharborv1alpha1.RobotPermissionPush, goharborv1alpha1.RobotPermissionScan},
+			expected:    []Permission{pullRepo, pushRepo, {Resource: "scan", Action: "create"}},
+		},
+		{
+			name:        "duplicated permissions",
+			permissions: []goharborv1alpha1.RobotPermission{goharborv1alpha1.RobotPermissionPull, goharborv1alpha1.RobotPermissionPush, goharborv1alpha1.RobotPermissionPush},
+			expected:    []Permission{pullRepo, pushRepo},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, RobotAccess(tc.permissions))
+		})
+	}
+}
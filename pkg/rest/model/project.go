@@ -0,0 +1,173 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sort"
+	"strconv"
+
+	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
+)
+
+// ProjectSettings are the declared settings of the harbor project, the nil ones are not managed
+type ProjectSettings struct {
+	Public             *bool
+	AutoScan           *bool
+	Severity           *string
+	PreventVul         *bool
+	EnableContentTrust *bool
+	// CVEAllowlist is the CVE allowlist of the project, the system allowlist is reused if it is nil
+	CVEAllowlist []string
+	// StorageLimit in bytes, -1 means unlimited. It is kept in sync with the quota of the project.
+	StorageLimit *int64
+}
+
+// IsEmpty checks if none of the settings is managed
+func (s *ProjectSettings) IsEmpty() bool {
+	return s == nil || (s.Public == nil && s.AutoScan == nil && s.Severity == nil && s.PreventVul == nil &&
+		s.EnableContentTrust == nil && s.CVEAllowlist == nil && s.StorageLimit == nil)
+}
+
+// ProjectReq returns the request creating the project with the settings, the project is private by default
+func (s *ProjectSettings) ProjectReq(name string) *v2models.ProjectReq {
+	req := &v2models.ProjectReq{
+		ProjectName: name,
+		Metadata: &v2models.ProjectMetadata{
+			Public: "false",
+		},
+	}
+
+	if s == nil {
+		return req
+	}
+
+	s.fillIn(req)
+	req.StorageLimit = s.StorageLimit
+
+	return req
+}
+
+// Drift returns the request updating the managed settings drifted on the project, nil is returned if all of them are in sync.
+// The storage limit is compared with the current one of the project quota, it is not compared if the current one is nil.
+// The metadata of the request is nil if only the storage limit is drifted, which is updated with the quota API.
+func (s *ProjectSettings) Drift(p *v2models.Project, storageLimit *int64) *v2models.ProjectReq {
+	if s.IsEmpty() {
+		return nil
+	}
+
+	meta := p.Metadata
+	if meta == nil {
+		meta = &v2models.ProjectMetadata{}
+	}
+
+	drifted := boolDrifted(s.Public, &meta.Public) ||
+		boolDrifted(s.AutoScan, meta.AutoScan) ||
+		boolDrifted(s.PreventVul, meta.PreventVul) ||
+		boolDrifted(s.EnableContentTrust, meta.EnableContentTrust) ||
+		(s.Severity != nil && (meta.Severity == nil || *meta.Severity != *s.Severity))
+
+	if s.CVEAllowlist != nil {
+		current := make([]string, 0)
+		if p.CveAllowlist != nil {
+			for _, item := range p.CveAllowlist.Items {
+				current = append(current, item.CveID)
+			}
+		}
+
+		drifted = drifted || boolDrifted(boolPtr(false), meta.ReuseSysCveAllowlist) || !sameSet(current, s.CVEAllowlist)
+	}
+
+	storageDrifted := s.StorageLimit != nil && storageLimit != nil && *storageLimit != *s.StorageLimit
+	if !drifted && !storageDrifted {
+		return nil
+	}
+
+	req := &v2models.ProjectReq{}
+	if drifted {
+		req.Metadata = &v2models.ProjectMetadata{}
+		s.fillIn(req)
+	}
+	if storageDrifted {
+		limit := *s.StorageLimit
+		req.StorageLimit = &limit
+	}
+
+	return req
+}
+
+func (s *ProjectSettings) fillIn(req *v2models.ProjectReq) {
+	meta := req.Metadata
+	if s.Public != nil {
+		meta.Public = strconv.FormatBool(*s.Public)
+	}
+	meta.AutoScan = boolString(s.AutoScan)
+	meta.PreventVul = boolString(s.PreventVul)
+	meta.EnableContentTrust = boolString(s.EnableContentTrust)
+	meta.Severity = s.Severity
+
+	if s.CVEAllowlist != nil {
+		meta.ReuseSysCveAllowlist = boolString(boolPtr(false))
+		items := make([]*v2models.CVEAllowlistItem, 0, len(s.CVEAllowlist))
+		for _, id := range s.CVEAllowlist {
+			items = append(items, &v2models.CVEAllowlistItem{CveID: id})
+		}
+		req.CveAllowlist = &v2models.CVEAllowlist{Items: items}
+	}
+}
+
+// boolDrifted checks if the declared value differs from the metadata value, the metadata missing in the project is false
+func boolDrifted(declared *bool, current *string) bool {
+	if declared == nil {
+		return false
+	}
+
+	value := false
+	if current != nil {
+		value, _ = strconv.ParseBool(*current)
+	}
+
+	return value != *declared
+}
+
+func boolString(b *bool) *string {
+	if b == nil {
+		return nil
+	}
+
+	s := strconv.FormatBool(*b)
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	x := append([]string{}, a...)
+	y := append([]string{}, b...)
+	sort.Strings(x)
+	sort.Strings(y)
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
+)
+
+func TestProjectSettings_Drift(t *testing.T) {
+	yes, no := true, false
+	high, low := "high", "low"
+	gib, unlimited := int64(1<<30), int64(-1)
+
+	type testcase struct {
+		description string
+		settings    *ProjectSettings
+		project     *v2models.Project
+		storage     *int64
+		drifted     bool
+		metadata    bool
+		quota       bool
+	}
+	tests := []testcase{
+		{
+			description: "no managed settings",
+			settings:    &ProjectSettings{},
+			project:     &v2models.Project{},
+		},
+		{
+			description: "missing metadata is false",
+			settings:    &ProjectSettings{Public: &no, AutoScan: &no},
+			project:     &v2models.Project{},
+		},
+		{
+			description: "auto scan is disabled in harbor",
+			settings:    &ProjectSettings{AutoScan: &yes},
+			project:     &v2models.Project{Metadata: &v2models.ProjectMetadata{AutoScan: boolString(&no)}},
+			drifted:     true,
+			metadata:    true,
+		},
+		{
+			description: "severity is in sync",
+			settings:    &ProjectSettings{Severity: &high, Public: &yes},
+			project:     &v2models.Project{Metadata: &v2models.ProjectMetadata{Severity: &high, Public: "true"}},
+		},
+		{
+			description: "severity is changed",
+			settings:    &ProjectSettings{Severity: &high},
+			project:     &v2models.Project{Metadata: &v2models.ProjectMetadata{Severity: &low}},
+			drifted:     true,
+			metadata:    true,
+		},
+		{
+			description: "cve allowlist in different order",
+			settings:    &ProjectSettings{CVEAllowlist: []string{"CVE-2", "CVE-1"}},
+			project: &v2models.Project{
+				Metadata:     &v2models.ProjectMetadata{ReuseSysCveAllowlist: boolString(&no)},
+				CveAllowlist: &v2models.CVEAllowlist{Items: []*v2models.CVEAllowlistItem{{CveID: "CVE-1"}, {CveID: "CVE-2"}}},
+			},
+		},
+		{
+			description: "system cve allowlist is reused",
+			settings:    &ProjectSettings{CVEAllowlist: []string{}},
+			project:     &v2models.Project{Metadata: &v2models.ProjectMetadata{ReuseSysCveAllowlist: boolString(&yes)}},
+			drifted:     true,
+			metadata:    true,
+		},
+		{
+			description: "unknown storage limit is not compared",
+			settings:    &ProjectSettings{StorageLimit: &gib},
+			project:     &v2models.Project{},
+		},
+		{
+			description: "storage limit is in sync",
+			settings:    &ProjectSettings{StorageLimit: &gib},
+			project:     &v2models.Project{},
+			storage:     &gib,
+		},
+		{
+			description: "storage limit is changed",
+			settings:    &ProjectSettings{StorageLimit: &gib},
+			project:     &v2models.Project{},
+			storage:     &unlimited,
+			drifted:     true,
+			quota:       true,
+		},
+		{
+			description: "storage limit and metadata are changed",
+			settings:    &ProjectSettings{StorageLimit: &unlimited, AutoScan: &yes},
+			project:     &v2models.Project{},
+			storage:     &gib,
+			drifted:     true,
+			metadata:    true,
+			quota:       true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			req := tc.settings.Drift(tc.project, tc.storage)
+			require.Equal(t, tc.drifted, req != nil)
+			if req != nil {
+				require.Equal(t, tc.metadata, req.Metadata != nil)
+				require.Equal(t, tc.quota, req.StorageLimit != nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// Registry is a registry endpoint of the harbor server
+type Registry struct {
+	// Name of the registry endpoint
+	Name string
+	// Type of the registry, e.g: docker-hub
+	Type string
+	// URL of the registry
+	URL string
+	// Insecure skips verifying the certificate of the registry
+	Insecure bool
+	// AccessKey and AccessSecret are the basic credential of the registry, it is accessed anonymously if they are empty
+	AccessKey    string
+	AccessSecret string
+	// CredentialChanged forces the existing registry endpoint to be updated, the secret of its credential is masked by harbor
+	// so the changed secret can not be detected by reading the endpoint
+	CredentialChanged bool
+}
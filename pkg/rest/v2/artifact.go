@@ -0,0 +1,126 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/client/artifact"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/client/repository"
+	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
+)
+
+// artifactPageSize is the page size of listing the repositories and artifacts
+const artifactPageSize int64 = 100
+
+// LabelProjectArtifacts attaches the label to all the artifacts of the project, it returns the number of the newly labelled artifacts
+func (c *Client) LabelProjectArtifacts(projectName string, labelID int64) (int, error) {
+	if len(projectName) == 0 {
+		return 0, errors.New("project name is empty")
+	}
+
+	if c.harborClient == nil {
+		return 0, errors.New("nil harbor client")
+	}
+
+	labelled := 0
+	pageSize := artifactPageSize
+	for page := int64(1); ; page++ {
+		p := page
+		params := repository.NewListRepositoriesParamsWithContext(c.context).
+			WithTimeout(c.timeout).
+			WithProjectName(projectName).
+			WithPage(&p).
+			WithPageSize(&pageSize)
+		res, err := c.harborClient.Client.Repository.ListRepositories(params, c.harborClient.Auth)
+		if err != nil {
+			return labelled, fmt.Errorf("list repositories of project %s error: %w", projectName, err)
+		}
+
+		for _, repo := range res.Payload {
+			n, err := c.labelRepositoryArtifacts(projectName, strings.TrimPrefix(repo.Name, projectName+"/"), labelID)
+			labelled += n
+			if err != nil {
+				return labelled, err
+			}
+		}
+
+		if int64(len(res.Payload)) < pageSize {
+			return labelled, nil
+		}
+	}
+}
+
+func (c *Client) labelRepositoryArtifacts(projectName, repoName string, labelID int64) (int, error) {
+	// The slashes in the repository name have to be escaped twice
+	escaped := url.PathEscape(repoName)
+
+	labelled := 0
+	pageSize, withLabel := artifactPageSize, true
+	for page := int64(1); ; page++ {
+		p := page
+		params := artifact.NewListArtifactsParamsWithContext(c.context).
+			WithTimeout(c.timeout).
+			WithProjectName(projectName).
+			WithRepositoryName(escaped).
+			WithWithLabel(&withLabel).
+			WithPage(&p).
+			WithPageSize(&pageSize)
+		res, err := c.harborClient.Client.Artifact.ListArtifacts(params, c.harborClient.Auth)
+		if err != nil {
+			return labelled, fmt.Errorf("list artifacts of repository %s/%s error: %w", projectName, repoName, err)
+		}
+
+		for _, a := range res.Payload {
+			if hasLabel(a, labelID) {
+				continue
+			}
+
+			lparams := artifact.NewAddLabelParamsWithContext(c.context).
+				WithTimeout(c.timeout).
+				WithProjectName(projectName).
+				WithRepositoryName(escaped).
+				WithReference(a.Digest).
+				WithLabel(&v2models.Label{ID: labelID})
+			if _, err := c.harborClient.Client.Artifact.AddLabel(lparams, c.harborClient.Auth); err != nil {
+				// Labelled by others
+				var conflict *artifact.AddLabelConflict
+				if errors.As(err, &conflict) {
+					continue
+				}
+
+				return labelled, fmt.Errorf("label artifact %s/%s@%s error: %w", projectName, repoName, a.Digest, err)
+			}
+			labelled++
+		}
+
+		if int64(len(res.Payload)) < pageSize {
+			return labelled, nil
+		}
+	}
+}
+
+func hasLabel(a *v2models.Artifact, labelID int64) bool {
+	for _, l := range a.Labels {
+		if l != nil && l.ID == labelID {
+			return true
+		}
+	}
+
+	return false
+}
@@ -19,12 +19,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/go-openapi/runtime"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 
-	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/client/project"
 	v2models "github.com/szlabs/harbor-automation-4k8s/pkg/sdk/harbor_v2/models"
@@ -39,8 +38,6 @@ type Client struct {
 	timeout time.Duration
 	// Context for doing client connection
 	context context.Context
-	// Insecure HTTP client
-	insecureClient *http.Client
 	// Harbor API client
 	harborClient *model.HarborClientV2
 }
@@ -49,29 +46,24 @@ type Client struct {
 func New() *Client {
 	// Initialize with default settings
 	return &Client{
-		timeout:        30 * time.Second,
-		context:        context.Background(),
-		insecureClient: ghttp.Client,
+		timeout: 30 * time.Second,
+		context: context.Background(),
 	}
 }
 
 // NewWithServer new V2 client with provided server
-func NewWithServer(s *model.HarborServer) *Client {
+func NewWithServer(s *model.HarborServer) (*Client, error) {
+	hc, err := s.ClientV2()
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize with default settings
 	c := New()
 	c.server = s
-	c.harborClient = s.ClientV2()
+	c.harborClient = hc
 
-	return c
-}
-
-func (c *Client) WithServer(s *model.HarborServer) *Client {
-	if s != nil {
-		c.server = s
-		c.harborClient = s.ClientV2()
-	}
-
-	return c
+	return c, nil
 }
 
 func (c *Client) WithContext(ctx context.Context) *Client {
@@ -87,9 +79,9 @@ func (c *Client) WithTimeout(timeout time.Duration) *Client {
 	return c
 }
 
-// EnsureProject ensures the specified project is on the harbor server
+// EnsureProject ensures the specified project is on the harbor server, the project is created with the settings.
 // If project with name is existing, then error will be nil
-func (c *Client) EnsureProject(name string) (int64, error) {
+func (c *Client) EnsureProject(name string, settings *model.ProjectSettings) (int64, error) {
 	if len(name) == 0 {
 		return -1, errors.New("project name is empty")
 	}
@@ -105,8 +97,8 @@ func (c *Client) EnsureProject(name string) (int64, error) {
 	}
 
 	if err != nil {
-		if !strings.Contains(err.Error(), "no project with name") {
-			return 0, fmt.Errorf("error when getting project %s: %s", name, err)
+		if !errors.Is(err, model.ErrNotFound) {
+			return 0, fmt.Errorf("error when getting project %s: %w", name, err)
 		}
 	}
 
@@ -115,13 +107,7 @@ func (c *Client) EnsureProject(name string) (int64, error) {
 	// Create one when the project does not exist
 	cparams := project.NewCreateProjectParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
-		WithProject(&v2models.ProjectReq{
-			ProjectName: name,
-			Metadata: &v2models.ProjectMetadata{
-				Public: "false",
-			},
-		})
+		WithProject(settings.ProjectReq(name))
 	cp, err := c.harborClient.Client.Project.CreateProject(cparams, c.harborClient.Auth)
 	if err != nil {
 		return -1, fmt.Errorf("ensure project error: %w", err)
@@ -130,7 +116,82 @@ func (c *Client) EnsureProject(name string) (int64, error) {
 	return utils.ExtractID(cp.Location)
 }
 
-// GetProject gets the project data
+// EnsureProxyCacheProject ensures the proxy cache project of the registry endpoint exists and returns its ID,
+// it is an error if the existing project with the name does not proxy the registry
+func (c *Client) EnsureProxyCacheProject(name string, registryID int64, public bool) (int64, error) {
+	if len(name) == 0 {
+		return 0, errors.New("project name is empty")
+	}
+
+	if c.harborClient == nil {
+		return 0, errors.New("nil harbor client")
+	}
+
+	p, err := c.GetProject(name)
+	if err == nil {
+		if p.RegistryID != registryID {
+			return 0, fmt.Errorf("project %s exists and is not the proxy cache of registry %d", name, registryID)
+		}
+
+		return int64(p.ProjectID), nil
+	}
+
+	if !errors.Is(err, model.ErrNotFound) {
+		return 0, fmt.Errorf("error when getting project %s: %w", name, err)
+	}
+
+	req := (&model.ProjectSettings{Public: &public}).ProjectReq(name)
+	req.RegistryID = &registryID
+	cparams := project.NewCreateProjectParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProject(req)
+	cp, err := c.harborClient.Client.Project.CreateProject(cparams, c.harborClient.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("create proxy cache project %s error: %w", name, err)
+	}
+
+	return utils.ExtractID(cp.Location)
+}
+
+// UpdateProject updates the project with the request
+func (c *Client) UpdateProject(projectID int64, req *v2models.ProjectReq) error {
+	if c.harborClient == nil {
+		return errors.New("nil harbor client")
+	}
+
+	params := project.NewUpdateProjectParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID).
+		WithProject(req)
+	if _, err := c.harborClient.Client.Project.UpdateProject(params, c.harborClient.Auth); err != nil {
+		return fmt.Errorf("update project error: %w", err)
+	}
+
+	return nil
+}
+
+// IsProjectDeletable checks if the project can be deleted, the reason is returned if it can not
+func (c *Client) IsProjectDeletable(projectID int64) (bool, string, error) {
+	if c.harborClient == nil {
+		return false, "", errors.New("nil harbor client")
+	}
+
+	params := project.NewGetProjectDeletableParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID)
+	res, err := c.harborClient.Client.Project.GetProjectDeletable(params, c.harborClient.Auth)
+	if err != nil {
+		return false, "", fmt.Errorf("check project deletable error: %w", err)
+	}
+
+	if res.Payload == nil {
+		return false, "", errors.New("empty response of checking project deletable")
+	}
+
+	return res.Payload.Deletable, res.Payload.Message, nil
+}
+
+// GetProject gets the project data by name, model.ErrNotFound is returned if the project does not exist
 func (c *Client) GetProject(name string) (*v2models.Project, error) {
 	if len(name) == 0 {
 		return nil, errors.New("project name is empty")
@@ -142,7 +203,6 @@ func (c *Client) GetProject(name string) (*v2models.Project, error) {
 	// Use listProject endpoint since getProject requires project id query key
 	params := project.NewListProjectsParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
 		WithName(&name)
 
 	res, err := c.harborClient.Client.Project.ListProjects(params, c.harborClient.Auth)
@@ -150,11 +210,40 @@ func (c *Client) GetProject(name string) (*v2models.Project, error) {
 		return nil, fmt.Errorf("get project error: %w", err)
 	}
 
-	if len(res.Payload) < 1 {
-		return nil, fmt.Errorf("no project with name %s exists", name)
+	for _, p := range res.Payload {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("project %s: %w", name, model.ErrNotFound)
+}
+
+// GetProjectByID gets the project data by ID, model.ErrNotFound is returned if the project does not exist
+func (c *Client) GetProjectByID(projectID int64) (*v2models.Project, error) {
+	if projectID <= 0 {
+		return nil, errors.New("invalid project id")
+	}
+
+	if c.harborClient == nil {
+		return nil, errors.New("nil harbor client")
+	}
+
+	params := project.NewGetProjectParamsWithContext(c.context).
+		WithTimeout(c.timeout).
+		WithProjectID(projectID)
+
+	res, err := c.harborClient.Client.Project.GetProject(params, c.harborClient.Auth)
+	if err != nil {
+		var apiErr *runtime.APIError
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+			return nil, fmt.Errorf("project %d: %w", projectID, model.ErrNotFound)
+		}
+
+		return nil, fmt.Errorf("get project error: %w", err)
 	}
 
-	return res.Payload[0], nil
+	return res.Payload, nil
 }
 
 // DeleteProject deletes project
@@ -175,7 +264,6 @@ func (c *Client) DeleteProject(name string) error {
 
 	params := project.NewDeleteProjectParamsWithContext(c.context).
 		WithTimeout(c.timeout).
-		WithHTTPClient(c.insecureClient).
 		WithProjectID((int64)(p.ProjectID))
 	if _, err = c.harborClient.Client.Project.DeleteProject(params, c.harborClient.Auth); err != nil {
 		return err
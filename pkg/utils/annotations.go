@@ -3,16 +3,49 @@ package utils
 const (
 	// AnnotationHarborServer is the annnotation for harbor server
 	AnnotationHarborServer = "goharbor.io/harbor"
-	// AnnotationAccount is the annnotation for service account
+	// AnnotationAccount is the annnotation for the comma separated service accounts
 	AnnotationAccount = "goharbor.io/service-account"
+	// AnnotationAccountSelector is the annotation for the label selector of the service accounts
+	AnnotationAccountSelector = "goharbor.io/service-account-selector"
 	// AnnotationProject is the annnotation for harbor project name
 	AnnotationProject = "goharbor.io/project"
-	// AnnotationRobot is the annotation for robot id
+	// AnnotationBoundProject is the annotation for the name of the harbor project the binding is bound to, the project is recreated with it if it is deleted
+	AnnotationBoundProject = "goharbor.io/bound-project"
+	// AnnotationPullProjects is the annotation for the comma separated additional projects the images are pulled from
+	AnnotationPullProjects = "goharbor.io/pull-projects"
+	// AnnotationRobot is the annotation for robot id of the namespace.
+	// Deprecated: each binding creates a robot account of its own, the annotated robot account is not used by the new bindings.
+	// It is only kept for the robot accounts shared by the bindings of the earlier versions, which are revoked with the project.
 	AnnotationRobot = "goharbor.io/robot"
+	// AnnotationRobotPermissions is the annotation for the robot id and the comma separated permissions the robot account of the binding is created with,
+	// e.g: 7:pull,push. It tells the access of the robot account when it can not be read from harbor
+	AnnotationRobotPermissions = "goharbor.io/robot-permissions"
 	// AnnotationRobotSecretRef is the annotation for robot secret reference
 	AnnotationRobotSecretRef = "goharbor.io/robot-secret"
+	// AnnotationRotatedAt is the annotation for the last time the secret of the robot was rotated
+	AnnotationRotatedAt = "goharbor.io/rotated-at"
 	// AnnotationSecOwner is the annotation for owner
 	AnnotationSecOwner = "goharbor.io/owner"
+	// AnnotationProjectPublic is the annotation for the public setting of the harbor project
+	AnnotationProjectPublic = "goharbor.io/project-public"
+	// AnnotationProjectAutoScan is the annotation for the auto scan setting of the harbor project
+	AnnotationProjectAutoScan = "goharbor.io/project-auto-scan"
+	// AnnotationProjectSeverity is the annotation for the vulnerability severity threshold of the harbor project
+	AnnotationProjectSeverity = "goharbor.io/project-severity"
+	// AnnotationProjectPreventVul is the annotation for the vulnerability prevention setting of the harbor project
+	AnnotationProjectPreventVul = "goharbor.io/project-prevent-vul"
+	// AnnotationProjectContentTrust is the annotation for the content trust setting of the harbor project
+	AnnotationProjectContentTrust = "goharbor.io/project-content-trust"
+	// AnnotationProjectCVEAllowlist is the annotation for the comma separated CVE allowlist of the harbor project
+	AnnotationProjectCVEAllowlist = "goharbor.io/project-cve-allowlist"
+	// AnnotationProjectStorageLimit is the annotation for the storage limit of the harbor project
+	AnnotationProjectStorageLimit = "goharbor.io/project-storage-limit"
+	// AnnotationProjectMemberRoles is the annotation for the comma separated mappings from the kubernetes roles to the harbor project roles
+	AnnotationProjectMemberRoles = "goharbor.io/project-member-roles"
+	// AnnotationProjectMembers is the annotation for the comma separated project members added by the operator, e.g: u:alice,g:dev
+	AnnotationProjectMembers = "goharbor.io/project-members"
+	// AnnotationNamespaceDeletionPolicy is the annotation for the deletion policy of the project of the namespace
+	AnnotationNamespaceDeletionPolicy = "goharbor.io/namespace-deletion-policy"
 	// AnnotationImageRewriteRuleConfigMapRef is the annotation for reference to configmap that stores rules
 	AnnotationImageRewriteRuleConfigMapRef = "goharbor.io/rewriting-rules"
 
@@ -0,0 +1,185 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+const (
+	// MaxProjectNameLen is the max length of the harbor project name
+	MaxProjectNameLen = 255
+	// hashLen is the length of the hash in the project name
+	hashLen = 8
+)
+
+var (
+	// projectNameRegex is the rule of the harbor project name
+	projectNameRegex = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+	// invalidProjectChars are replaced with '-'
+	invalidProjectChars = regexp.MustCompile(`[^a-z0-9._-]`)
+	// repeatedSeparators are collapsed into one '-'
+	repeatedSeparators = regexp.MustCompile(`[._-]{2,}`)
+)
+
+// ProjectNameData is the data to render the project name template
+type ProjectNameData struct {
+	// Cluster is the name of the cluster the operator is running in
+	Cluster string
+	// Namespace is the name of the namespace
+	Namespace string
+	// Labels of the namespace
+	Labels map[string]string
+	// Hash is a short hash of the cluster and namespace names
+	Hash string
+}
+
+// NewProjectNameData creates the data to render the project name of the namespace
+func NewProjectNameData(cluster, namespace string, labels map[string]string) *ProjectNameData {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	return &ProjectNameData{
+		Cluster:   cluster,
+		Namespace: namespace,
+		Labels:    labels,
+		Hash:      shortHash(cluster + "/" + namespace),
+	}
+}
+
+// RenderProjectName renders the project name template with the data, the result is normalized to follow the harbor project name rules.
+// The same template and data always produce the same name.
+func RenderProjectName(tmpl string, data *ProjectNameData) (string, error) {
+	t, err := template.New("projectName").Option("missingkey=zero").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parse project name template error: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("render project name template error: %w", err)
+	}
+
+	name := NormalizeProjectName(buf.String())
+	if !projectNameRegex.MatchString(name) {
+		return "", fmt.Errorf("project name template %q renders invalid project name %q", tmpl, name)
+	}
+
+	return name, nil
+}
+
+// ValidateProjectNameTemplate renders the template with the sample data. The labels referred by the template are rendered
+// both with sample values and missing, so the template is valid for the namespaces with or without the labels.
+func ValidateProjectNameTemplate(tmpl string) error {
+	t, err := template.New("projectName").Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("parse project name template error: %w", err)
+	}
+
+	labels := make(map[string]string)
+	for _, key := range referredLabels(t.Tree.Root) {
+		labels[key] = "sample"
+	}
+
+	for _, l := range []map[string]string{nil, labels} {
+		if _, err := RenderProjectName(tmpl, NewProjectNameData("cluster", "namespace", l)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// referredLabels returns the keys of the labels referred by the template node, with .Labels.key or index .Labels "key"
+func referredLabels(node parse.Node) []string {
+	keys := make([]string, 0)
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return keys
+		}
+		for _, c := range n.Nodes {
+			keys = append(keys, referredLabels(c)...)
+		}
+	case *parse.ActionNode:
+		keys = append(keys, referredLabels(n.Pipe)...)
+	case *parse.IfNode:
+		keys = append(keys, referredLabels(&n.BranchNode)...)
+	case *parse.RangeNode:
+		keys = append(keys, referredLabels(&n.BranchNode)...)
+	case *parse.WithNode:
+		keys = append(keys, referredLabels(&n.BranchNode)...)
+	case *parse.BranchNode:
+		keys = append(keys, referredLabels(n.Pipe)...)
+		keys = append(keys, referredLabels(n.List)...)
+		keys = append(keys, referredLabels(n.ElseList)...)
+	case *parse.TemplateNode:
+		keys = append(keys, referredLabels(n.Pipe)...)
+	case *parse.PipeNode:
+		if n == nil {
+			return keys
+		}
+		for _, c := range n.Cmds {
+			keys = append(keys, referredLabels(c)...)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) == 3 {
+			id, isIdent := n.Args[0].(*parse.IdentifierNode)
+			field, isField := n.Args[1].(*parse.FieldNode)
+			key, isString := n.Args[2].(*parse.StringNode)
+			if isIdent && id.Ident == "index" && isField && len(field.Ident) == 1 && field.Ident[0] == "Labels" && isString {
+				keys = append(keys, key.Text)
+			}
+		}
+		for _, arg := range n.Args {
+			keys = append(keys, referredLabels(arg)...)
+		}
+	case *parse.FieldNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "Labels" {
+			keys = append(keys, n.Ident[1])
+		}
+	}
+
+	return keys
+}
+
+// NormalizeProjectName lowercases the name, replaces the invalid characters and collapses the separators.
+// The name exceeding the max length is truncated and suffixed with the hash of the full name to keep it unique.
+func NormalizeProjectName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = invalidProjectChars.ReplaceAllString(name, "-")
+	name = repeatedSeparators.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "._-")
+
+	if len(name) > MaxProjectNameLen {
+		prefix := strings.TrimRight(name[:MaxProjectNameLen-hashLen-1], "._-")
+		name = fmt.Sprintf("%s-%s", prefix, shortHash(name))
+	}
+
+	return name
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:hashLen]
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderProjectName(t *testing.T) {
+	data := NewProjectNameData("Prod_EU", "team-a", map[string]string{"team": "Payments"})
+	longName := strings.Repeat("a", MaxProjectNameLen+10)
+
+	type testcase struct {
+		description string
+		template    string
+		data        *ProjectNameData
+		expected    string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "namespace and hash",
+			template:    "{{ .Namespace }}-{{ .Hash }}",
+			data:        data,
+			expected:    "team-a-" + data.Hash,
+		},
+		{
+			description: "cluster and labels are normalized",
+			template:    "{{ .Cluster }}/{{ .Labels.team }}--{{ .Namespace }}",
+			data:        data,
+			expected:    "prod_eu-payments-team-a",
+		},
+		{
+			description: "missing label renders empty",
+			template:    "{{ .Labels.missing }}.{{ .Namespace }}",
+			data:        data,
+			expected:    "team-a",
+		},
+		{
+			description: "too long name is truncated with hash",
+			template:    "{{ .Namespace }}",
+			data:        NewProjectNameData("", longName, nil),
+			expected:    strings.Repeat("a", MaxProjectNameLen-hashLen-1) + "-" + shortHash(longName),
+		},
+		{
+			description: "empty name",
+			template:    "{{ .Labels.missing }}",
+			data:        data,
+			expectedErr: true,
+		},
+		{
+			description: "invalid template",
+			template:    "{{ .Namespace ",
+			data:        data,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			actual, err := RenderProjectName(tc.template, tc.data)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, actual)
+			require.LessOrEqual(t, len(actual), MaxProjectNameLen)
+		})
+	}
+}
+
+func TestNewProjectNameData(t *testing.T) {
+	// The same namespace always gets the same hash, different clusters get different ones
+	require.Equal(t, NewProjectNameData("c1", "ns", nil).Hash, NewProjectNameData("c1", "ns", map[string]string{"a": "b"}).Hash)
+	require.NotEqual(t, NewProjectNameData("c1", "ns", nil).Hash, NewProjectNameData("c2", "ns", nil).Hash)
+}
+
+func TestValidateProjectNameTemplate(t *testing.T) {
+	type testcase struct {
+		description string
+		template    string
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "default template",
+			template:    "{{ .Namespace }}-{{ .Hash }}",
+		},
+		{
+			description: "optional label",
+			template:    "{{ .Labels.team }}-{{ .Namespace }}",
+		},
+		{
+			description: "label referred with index",
+			template:    `{{ with index .Labels "team" }}{{ . }}-{{ end }}{{ .Namespace }}`,
+		},
+		{
+			description: "invalid syntax",
+			template:    "{{ .Namespace ",
+			expectedErr: true,
+		},
+		{
+			description: "template renders empty name without the label",
+			template:    "{{ .Labels.team }}",
+			expectedErr: true,
+		},
+		{
+			description: "template fails only with the label",
+			template:    "{{ if .Labels.team }}{{ .Labels.team.name }}{{ end }}{{ .Namespace }}",
+			expectedErr: true,
+		},
+		{
+			description: "template fails only with the label referred with index",
+			template:    `{{ if index .Labels "team" }}{{ .Cluster.name }}{{ end }}{{ .Namespace }}`,
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			err := ValidateProjectNameTemplate(tc.template)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the major.minor.patch part of a semantic version
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses the version like 2.1.0 or the harbor build version like v2.1.2-2b6a5a2e.
+// The pre-release and build metadata are ignored.
+func ParseVersion(version string) (*Version, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		nums[i] = n
+	}
+
+	return &Version{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// Compare returns -1, 0 or 1 if the version is lower than, equal to or higher than the other one
+func (v *Version) Compare(other *Version) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// AtLeast checks if the version is equal to or higher than major.minor
+func (v *Version) AtLeast(major, minor int) bool {
+	return v.Compare(&Version{Major: major, Minor: minor}) >= 0
+}
+
+func (v *Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
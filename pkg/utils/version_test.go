@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	type testcase struct {
+		description string
+		version     string
+		expected    *Version
+		expectedErr bool
+	}
+	tests := []testcase{
+		{
+			description: "semantic version",
+			version:     "2.1.0",
+			expected:    &Version{Major: 2, Minor: 1, Patch: 0},
+		},
+		{
+			description: "harbor build version",
+			version:     "v2.1.2-2b6a5a2e",
+			expected:    &Version{Major: 2, Minor: 1, Patch: 2},
+		},
+		{
+			description: "version with build metadata",
+			version:     "2.2.0+build.1",
+			expected:    &Version{Major: 2, Minor: 2, Patch: 0},
+		},
+		{
+			description: "version without patch",
+			version:     "v1.10",
+			expected:    &Version{Major: 1, Minor: 10, Patch: 0},
+		},
+		{
+			description: "invalid version",
+			version:     "dev",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			v, err := ParseVersion(tc.version)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestVersion_AtLeast(t *testing.T) {
+	v := &Version{Major: 2, Minor: 1, Patch: 2}
+	require.True(t, v.AtLeast(2, 1))
+	require.True(t, v.AtLeast(1, 10))
+	require.False(t, v.AtLeast(2, 2))
+	require.False(t, v.AtLeast(3, 0))
+}
@@ -0,0 +1,74 @@
+package hsc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+)
+
+// +kubebuilder:webhook:path=/validate-hs,mutating=false,failurePolicy=fail,groups="goharbor.goharbor.io",resources=harborservers,verbs=create;update,sideEffects=None,admissionReviewVersions=v1beta1,versions=v1alpha1,name=hs.goharbor.io
+
+// HarborServerValidator validates the harbor servers created by the namespace owners
+type HarborServerValidator struct {
+	Log     logr.Logger
+	decoder *admission.Decoder
+}
+
+var _ admission.Handler = (*HarborServerValidator)(nil)
+var _ admission.DecoderInjector = (*HarborServerValidator)(nil)
+
+func (h *HarborServerValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	hs := &goharborv1alpha1.HarborServer{}
+
+	err := h.decoder.Decode(req, hs)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if msg := validateSpec(hs.Name, &hs.Spec); len(msg) > 0 {
+		return admission.ValidationResponse(false, msg)
+	}
+	if hs.Spec.Default {
+		return admission.ValidationResponse(false, fmt.Sprintf("%q can not be set as default, only harbor server configurations can be the default", hs.Name))
+	}
+	// The secrets out of the namespace are not accessible to the namespace owners
+	for _, ns := range secretNamespaces(&hs.Spec) {
+		if ns != req.Namespace {
+			return admission.ValidationResponse(false, fmt.Sprintf("%s can not be validated, the secrets must be in the namespace %s", hs.Name, req.Namespace))
+		}
+	}
+	return admission.Allowed("")
+}
+
+func (h *HarborServerValidator) InjectDecoder(decoder *admission.Decoder) error {
+	h.decoder = decoder
+	return nil
+}
+
+// secretNamespaces returns the namespaces of the secrets referred by the spec
+func secretNamespaces(spec *goharborv1alpha1.HarborServerConfigurationSpec) []string {
+	namespaces := make([]string, 0)
+	if spec.AccessCredential != nil {
+		namespaces = append(namespaces, spec.AccessCredential.Namespace)
+	}
+	if spec.CABundleRef != nil {
+		namespaces = append(namespaces, spec.CABundleRef.Namespace)
+	}
+	if spec.ClientCertificate != nil {
+		namespaces = append(namespaces, spec.ClientCertificate.Namespace)
+	}
+	if spec.Proxy != nil && spec.Proxy.Credential != nil {
+		namespaces = append(namespaces, spec.Proxy.Credential.Namespace)
+	}
+	for _, pc := range spec.ProxyCaches {
+		if pc.Credential != nil {
+			namespaces = append(namespaces, pc.Credential.Namespace)
+		}
+	}
+
+	return namespaces
+}
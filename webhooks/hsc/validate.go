@@ -13,6 +13,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
+	ghttp "github.com/szlabs/harbor-automation-4k8s/pkg/http"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/rest/model"
+	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 )
 
 // +kubebuilder:webhook:path=/validate-hsc,mutating=false,failurePolicy=fail,groups="goharbor.goharbor.io",resources=harborserverconfigurations,verbs=create;update,sideEffects=None,admissionReviewVersions=v1beta1,versions=v1alpha1,name=hsc.goharbor.io
@@ -33,11 +36,8 @@ func (h *Validator) Handle(ctx context.Context, req admission.Request) admission
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	for _, rule := range hsc.Spec.Rules {
-		registryRegex := rule[:strings.LastIndex(rule, ",")+1]
-		if _, err := regexpcache.Compile(registryRegex); err != nil {
-			return admission.ValidationResponse(false, fmt.Sprintf("%s can not be validated, %q is not a valid regular expression: %s", hsc.Name, registryRegex, err.Error()))
-		}
+	if msg := validateSpec(hsc.Name, &hsc.Spec); len(msg) > 0 {
+		return admission.ValidationResponse(false, msg)
 	}
 	// Check for duplicate default configurations
 	if hsc.Spec.Default {
@@ -54,6 +54,77 @@ func (h *Validator) Handle(ctx context.Context, req admission.Request) admission
 	return admission.Allowed("")
 }
 
+// validateSpec validates the spec shared by the harbor server configurations and the harbor servers,
+// it returns the reason if the spec is invalid
+func validateSpec(name string, spec *goharborv1alpha1.HarborServerConfigurationSpec) string {
+	for _, rule := range spec.Rules {
+		registryRegex := rule[:strings.LastIndex(rule, ",")+1]
+		if _, err := regexpcache.Compile(registryRegex); err != nil {
+			return fmt.Sprintf("%s can not be validated, %q is not a valid regular expression: %s", name, registryRegex, err.Error())
+		}
+	}
+	for _, u := range spec.SecondaryServerURLs {
+		if len(strings.TrimSpace(string(u))) == 0 {
+			return fmt.Sprintf("%s can not be validated, secondary server URL can not be empty", name)
+		}
+	}
+	if len(spec.ProjectNameTemplate) > 0 {
+		// Render with sample data to catch the invalid templates early
+		if err := utils.ValidateProjectNameTemplate(spec.ProjectNameTemplate); err != nil {
+			return fmt.Sprintf("%s can not be validated, invalid projectNameTemplate: %s", name, err.Error())
+		}
+	}
+	for role, projectRole := range spec.ProjectMemberRoles {
+		if _, _, err := goharborv1alpha1.ParseMemberRole(role); err != nil {
+			return fmt.Sprintf("%s can not be validated, invalid projectMemberRoles: %s", name, err.Error())
+		}
+		if _, ok := model.ProjectRoleID(projectRole); !ok {
+			return fmt.Sprintf("%s can not be validated, role %s is mapped to invalid project role %q", name, role, projectRole)
+		}
+	}
+	if len(spec.CABundle) > 0 {
+		if spec.CABundleRef != nil {
+			return fmt.Sprintf("%s can not be validated, only one of caBundle and caBundleRef can be set", name)
+		}
+		if err := (&ghttp.Options{CABundle: []byte(spec.CABundle)}).Validate(); err != nil {
+			return fmt.Sprintf("%s can not be validated, invalid caBundle: %s", name, err.Error())
+		}
+	}
+	if p := spec.Proxy; p != nil {
+		if err := (&ghttp.Options{Proxy: &ghttp.Proxy{URL: p.URL}}).Validate(); err != nil {
+			return fmt.Sprintf("%s can not be validated, invalid proxy: %s", name, err.Error())
+		}
+	}
+	if rc := spec.RobotCredential; rc != nil {
+		if rc.TTL != nil && rc.TTL.Duration > 0 && rc.TTL.Duration < goharborv1alpha1.MinRobotTTL {
+			return fmt.Sprintf("%s can not be validated, robot credential TTL %s is shorter than %s", name, rc.TTL.Duration, goharborv1alpha1.MinRobotTTL)
+		}
+		if rc.RotationWindow != nil && rc.RotationWindow.Duration > 0 && rc.RotationWindow.Duration < goharborv1alpha1.MinRobotRotationWindow {
+			return fmt.Sprintf("%s can not be validated, robot credential rotation window %s is shorter than %s", name, rc.RotationWindow.Duration, goharborv1alpha1.MinRobotRotationWindow)
+		}
+		if rc.GracePeriod != nil && rc.GracePeriod.Duration < 0 {
+			return fmt.Sprintf("%s can not be validated, robot credential grace period %s is negative", name, rc.GracePeriod.Duration)
+		}
+	}
+	names, projects := make(map[string]bool), make(map[string]bool)
+	for _, pc := range spec.ProxyCaches {
+		if names[pc.Name] {
+			return fmt.Sprintf("%s can not be validated, proxy cache %s is declared more than once", name, pc.Name)
+		}
+		if projects[pc.GetProject()] {
+			return fmt.Sprintf("%s can not be validated, project %s is shared by multiple proxy caches", name, pc.GetProject())
+		}
+		names[pc.Name], projects[pc.GetProject()] = true, true
+
+		if len(pc.Registry) > 0 {
+			if _, err := regexpcache.Compile(pc.Registry); err != nil {
+				return fmt.Sprintf("%s can not be validated, registry %q of proxy cache %s is not a valid regular expression: %s", name, pc.Registry, pc.Name, err.Error())
+			}
+		}
+	}
+	return ""
+}
+
 func (h *Validator) InjectDecoder(decoder *admission.Decoder) error {
 	h.decoder = decoder
 	return nil
@@ -24,6 +24,7 @@ import (
 	goharborv1alpha1 "github.com/szlabs/harbor-automation-4k8s/api/v1alpha1"
 
 	"github.com/go-logr/logr"
+	harborClient "github.com/szlabs/harbor-automation-4k8s/pkg/controllers/harbor"
 	"github.com/szlabs/harbor-automation-4k8s/pkg/utils"
 	corev1 "k8s.io/api/core/v1"
 	apierr "k8s.io/apimachinery/pkg/api/errors"
@@ -98,7 +99,7 @@ func (ipr *ImagePathRewriter) Handle(ctx context.Context, req admission.Request)
 			}
 
 			// merge rules of configMap to rules of hsc, overwrite if there is conflicts
-			allRules = mergeRules(stringToRules(hsc.Spec.Rules, hsc.Spec.ServerURL),
+			allRules = mergeRules(stringToRules(hsc.RewriteRules(), hsc.Spec.ServerURL),
 				stringToRules(strings.Split(strings.TrimSpace(cm.Data[utils.ConfigMapKeyRules]), "\n"), hsc.Spec.ServerURL))
 		} else {
 			// if there is rule in configMap but no hsc, error out
@@ -114,7 +115,7 @@ func (ipr *ImagePathRewriter) Handle(ctx context.Context, req admission.Request)
 	}
 	// check selector, if there is match, add the default rules to it. it has lowerest priority
 	if match := checkNamespaceSelector(podNS.Labels, defaultHSC.Spec.NamespaceSelector.MatchLabels); match {
-		allRules = mergeRules(stringToRules(defaultHSC.Spec.Rules, defaultHSC.Spec.ServerURL), allRules)
+		allRules = mergeRules(stringToRules(defaultHSC.RewriteRules(), defaultHSC.Spec.ServerURL), allRules)
 	} else {
 		// it's ok to not match the default hsc
 		ipr.Log.Info("default hsc ", defaultHSC.Namespace, "/", defaultHSC.Name, " doesn't match current namespace")
@@ -253,15 +254,7 @@ func (ipr *ImagePathRewriter) getPodNamespace(ctx context.Context, ns string) (*
 	return namespace, nil
 }
 
+// getHarborServerConfig gets the harbor server in the namespace or the harbor server configuration with the name
 func (ipr *ImagePathRewriter) getHarborServerConfig(ctx context.Context, ns string, issuer string) (*goharborv1alpha1.HarborServerConfiguration, error) {
-	hsc := &goharborv1alpha1.HarborServerConfiguration{}
-	nsName := types.NamespacedName{
-		Name: issuer,
-	}
-
-	if err := ipr.Client.Get(ctx, nsName, hsc); err != nil {
-		return nil, err
-	}
-
-	return hsc, nil
+	return harborClient.GetServerConfiguration(ctx, ipr.Client, ns, issuer)
 }